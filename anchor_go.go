@@ -1,33 +1,211 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
+	"fmt"
+	"maps"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 
-	"github.com/alivers/anchor-go/internal/generator"
-	"github.com/alivers/anchor-go/internal/idl"
+	"github.com/alivers/anchor-go/codegen"
+	"github.com/fatih/color"
 )
 
-var src = flag.String("src", "", "Path to source; can use multiple times.")
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+var src stringSlice
 var dst = flag.String("dst", "generated", "Destination folder, the program name will be the folder for the genrated files")
+var pkgName = flag.String("pkg", "", "Package name of the generated code, defaults to the program name; only for a single program")
+var importBase = flag.String("import-base", "", "Import path of the destination folder, used to import the packages of dependent programs; detected from go.mod if empty")
+var encoder = flag.String("encoder", string(codegen.EncoderBorsh), "Encoder of the instructions: borsh, bin or compact-u16")
 var skipOptionalFlag = flag.Bool("skip-optional-flag", false, "whether to skip optional flag when encoding or decoding optional fields")
 var generateTests = flag.Bool("tests", true, "Generate tests")
+var configPath = flag.String("config", "", "Path to the config file; anchor-go.yaml, anchor-go.yml or anchor-go.json next to the IDL is used if empty")
+var check = flag.Bool("check", false, "Check the generated code in the destination folder is up to date instead of writing it")
+
+func init() {
+	flag.Var(&src, "src", "Path to source, either an IDL or a dumped on-chain IDL account (raw or base64); can use multiple times.")
+}
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "diff" {
+		diffIdls(os.Args[2:])
+		return
+	}
 	flag.Parse()
 
-	idlFile, err := os.Open(*src)
+	programs := make([]*codegen.Idl, 0, len(src))
+	for _, path := range src {
+		program, err := loadIdl(path)
+		if err != nil {
+			exitWithError(err)
+		}
+		programs = append(programs, program)
+	}
+
+	config, err := loadConfig()
+	if err != nil {
+		exitWithError(err)
+	}
+
+	files, err := codegen.GenerateAll(codegen.Options{
+		Destination:      *dst,
+		PackageName:      *pkgName,
+		ImportBase:       *importBase,
+		GenerateTests:    *generateTests,
+		SkipOptionalFlag: *skipOptionalFlag,
+		Encoder:          codegen.Encoder(*encoder),
+		Config:           config,
+		Warn: func(warning codegen.Diagnostic) {
+			fmt.Printf("[%s] %s\n", color.YellowString("!"), warning)
+		},
+	}, programs...)
+	if err != nil {
+		exitWithError(err)
+	}
+
+	if *check {
+		checkFiles(files)
+		return
+	}
+
+	stale, err := codegen.StaleFiles(files)
+	if err != nil {
+		exitWithError(err)
+	}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if err := codegen.WriteFile(path, files[path]); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("[%s] %s\n", color.GreenString("✓"), absPath(path))
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			exitWithError(err)
+		}
+		fmt.Printf("[%s] %s\n", color.RedString("-"), absPath(path))
+	}
+}
+
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+// checkFiles prints the drifts between the generated files and the destination folder, and exits non-zero if there is any.
+func checkFiles(files map[string][]byte) {
+	drifts, err := codegen.Check(files)
+	if err != nil {
+		exitWithError(err)
+	}
+	if len(drifts) == 0 {
+		fmt.Printf("[%s] generated code is up to date\n", color.GreenString("✓"))
+		return
+	}
+
+	for _, drift := range drifts {
+		fmt.Printf("[%s] %s: %s\n", color.RedString("✗"), drift.Kind, drift.Path)
+	}
+	for _, drift := range drifts {
+		fmt.Print(drift.Diff)
+	}
+	exitWithError(fmt.Errorf("generated code is out of date: %d file(s) differ, run anchor-go again to regenerate", len(drifts)))
+}
+
+// loadConfig loads the config given by `-config`, or the one next to the IDLs.
+func loadConfig() (*codegen.Config, error) {
+	path := *configPath
+	if path == "" {
+		for _, idlPath := range src {
+			found, err := codegen.FindConfig(filepath.Dir(idlPath))
+			if err != nil {
+				return nil, err
+			}
+			if found == "" {
+				continue
+			}
+			if path != "" && filepath.Clean(found) != filepath.Clean(path) {
+				return nil, fmt.Errorf("found multiple config files: %s and %s, use -config to choose one", path, found)
+			}
+			path = found
+		}
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return codegen.LoadConfig(path)
+}
+
+// diffIdls reports the changes between two versions of an IDL, and exits non-zero if there is any breaking one.
+func diffIdls(args []string) {
+	flags := flag.NewFlagSet("diff", flag.ExitOnError)
+	breakingOnly := flags.Bool("breaking-only", false, "Only report the breaking changes")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s diff [flags] <old IDL> <new IDL>\n", filepath.Base(os.Args[0]))
+		flags.PrintDefaults()
+	}
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+
+	old, err := loadIdl(flags.Arg(0))
 	if err != nil {
-		panic(err)
+		exitWithError(err)
+	}
+	new, err := loadIdl(flags.Arg(1))
+	if err != nil {
+		exitWithError(err)
 	}
 
-	var program *idl.Idl
-	dec := json.NewDecoder(idlFile)
-	err = dec.Decode(&program)
+	changes := codegen.CompareIdl(old, new)
+	breaking := 0
+	for _, change := range changes {
+		switch change.Impact {
+		case codegen.ImpactBreaking:
+			breaking++
+			fmt.Printf("[%s] %s: %s\n", color.RedString("✗"), change.Path, change.Message)
+		case codegen.ImpactSafe:
+			if !*breakingOnly {
+				fmt.Printf("[%s] %s: %s\n", color.GreenString("+"), change.Path, change.Message)
+			}
+		}
+	}
+	if breaking > 0 {
+		exitWithError(fmt.Errorf("found %d breaking change(s)", breaking))
+	}
+	if len(changes) == 0 {
+		fmt.Printf("[%s] no changes\n", color.GreenString("✓"))
+	}
+}
+
+func loadIdl(path string) (*codegen.Idl, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	program, err := codegen.LoadIdl(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return program, nil
+}
 
-	generator.Generate(*dst, *generateTests, *skipOptionalFlag, program)
+func exitWithError(err error) {
+	fmt.Fprintln(os.Stderr, err)
+	os.Exit(1)
 }
@@ -0,0 +1,215 @@
+package codegen
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/generator"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+type DriftKind string
+
+const (
+	// DriftModified means the file exists but its content differs from the generated one.
+	DriftModified DriftKind = "modified"
+	// DriftCreated means the file would be created.
+	DriftCreated DriftKind = "created"
+	// DriftDeleted means the file was generated before but is no longer produced.
+	DriftDeleted DriftKind = "deleted"
+)
+
+// Drift is a difference between the generated files and the files on disk.
+type Drift struct {
+	Path string
+	Kind DriftKind
+	// Unified diff from the file on disk to the generated file.
+	Diff string
+}
+
+// Check compares the generated files byte-for-byte against the files on disk, and returns the drifts in the order of their path.
+// The version of the generator recorded in the manifests is left out of the comparison,
+// so that a build of another commit doesn't report drifts.
+// Files owned by the manifest of a package folder but no longer produced are reported as deleted,
+// hand-written files are ignored. For a folder without manifest, the Go files carrying
+// the `Code generated ... DO NOT EDIT.` header of anchor-go are considered as owned,
+// the files generated by other tools are ignored.
+func Check(files map[string][]byte) ([]Drift, error) {
+	var drifts []Drift
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		current, err := os.ReadFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			drifts = append(drifts, Drift{Path: path, Kind: DriftCreated, Diff: unifiedDiff(path, nil, files[path])})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.Equal(current, files[path]) && !sameManifest(path, current, files[path]) {
+			drifts = append(drifts, Drift{Path: path, Kind: DriftModified, Diff: unifiedDiff(path, current, files[path])})
+		}
+	}
+
+	deleted, err := findStaleGeneratedFiles(files, true)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range deleted {
+		current, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		drifts = append(drifts, Drift{Path: path, Kind: DriftDeleted, Diff: unifiedDiff(path, current, nil)})
+	}
+
+	slices.SortFunc(drifts, func(a, b Drift) int {
+		return strings.Compare(a.Path, b.Path)
+	})
+	return drifts, nil
+}
+
+// sameManifest reports whether both contents are the manifest of the same generation, regardless of the generator version.
+func sameManifest(path string, a, b []byte) bool {
+	if filepath.Base(path) != ManifestFileName {
+		return false
+	}
+	manifestA, err := generator.ParseManifest(a)
+	if err != nil {
+		return false
+	}
+	manifestB, err := generator.ParseManifest(b)
+	if err != nil {
+		return false
+	}
+	manifestA.Version, manifestB.Version = "", ""
+	return reflect.DeepEqual(manifestA, manifestB)
+}
+
+// findStaleGeneratedFiles returns the files in the folders of the files which were generated before but are not in the files.
+// The files owned by a folder are listed in its manifest; for a folder without manifest,
+// the generated Go files are detected by their header if `scanHeaders` is set.
+func findStaleGeneratedFiles(files map[string][]byte, scanHeaders bool) ([]string, error) {
+	folders := map[string]struct{}{}
+	for path := range files {
+		folders[filepath.Dir(path)] = struct{}{}
+	}
+
+	var stale []string
+	for _, folder := range slices.Sorted(maps.Keys(folders)) {
+		owned, err := ownedFiles(folder, scanHeaders)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range owned {
+			path := filepath.Join(folder, name)
+			if _, ok := files[path]; ok {
+				continue
+			}
+			if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			stale = append(stale, path)
+		}
+	}
+	return stale, nil
+}
+
+// ownedFiles returns the names of the files in the folder which are owned by the generator.
+func ownedFiles(folder string, scanHeaders bool) ([]string, error) {
+	manifestPath := filepath.Join(folder, ManifestFileName)
+	data, err := os.ReadFile(manifestPath)
+	switch {
+	case err == nil:
+		manifest, err := generator.ParseManifest(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", manifestPath, err)
+		}
+		// Never touch anything outside the folder.
+		return slices.DeleteFunc(manifest.Files, func(name string) bool {
+			return filepath.Base(name) != name || name == ManifestFileName
+		}), nil
+	case !errors.Is(err, fs.ErrNotExist):
+		return nil, err
+	case !scanHeaders:
+		return nil, nil
+	}
+
+	entries, err := os.ReadDir(folder)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var owned []string
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".go" {
+			continue
+		}
+		generated, err := isGeneratedFile(filepath.Join(folder, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if generated {
+			owned = append(owned, entry.Name())
+		}
+	}
+	return owned, nil
+}
+
+// isGeneratedFile reports whether the file starts with the header of the Go files generated by anchor-go,
+// see https://pkg.go.dev/cmd/go#hdr-Generate_Go_files_by_processing_source
+func isGeneratedFile(path string) (bool, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	if !scanner.Scan() {
+		return false, scanner.Err()
+	}
+	line := scanner.Text()
+	return strings.HasPrefix(line, "// Code generated ") && strings.HasSuffix(line, " DO NOT EDIT.") &&
+		strings.Contains(line, "anchor-go"), nil
+}
+
+func unifiedDiff(path string, from, to []byte) string {
+	fromFile, toFile := path, path
+	if from == nil {
+		fromFile = "/dev/null"
+	}
+	if to == nil {
+		toFile = "/dev/null"
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(from),
+		B:        splitLines(to),
+		FromFile: fromFile,
+		ToFile:   toFile,
+		Context:  3,
+	})
+	if err != nil {
+		return ""
+	}
+	return diff
+}
+
+func splitLines(content []byte) []string {
+	if len(content) == 0 {
+		return nil
+	}
+	return difflib.SplitLines(string(content))
+}
@@ -0,0 +1,239 @@
+package codegen
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeVaultFiles generates the package of testdata/vault.json in a temporary folder and writes it,
+// it returns the generated files by their path.
+func writeVaultFiles(t *testing.T) map[string][]byte {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", "vault.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := ParseIdl(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, err := Generate(Options{Destination: t.TempDir()}, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFiles(files); err != nil {
+		t.Fatal(err)
+	}
+	return files
+}
+
+func TestCheck(t *testing.T) {
+	const (
+		generatedHeader = "// Code generated by https://github.com/alivers/anchor-go/internal. DO NOT EDIT.\n\npackage vault_prog\n"
+		stringerHeader  = "// Code generated by \"stringer -type=Kind\"; DO NOT EDIT.\n\npackage vault_prog\n"
+		handWritten     = "package vault_prog\n"
+	)
+	tests := []struct {
+		name string
+		// change changes the files on disk or the generated files, the package folder is in `dir`.
+		change func(t *testing.T, files map[string][]byte, dir string)
+		// The expected drifts by the name of the file.
+		expected map[string]DriftKind
+	}{
+		{
+			name:   "up to date",
+			change: func(t *testing.T, files map[string][]byte, dir string) {},
+		},
+		{
+			name: "modified",
+			change: func(t *testing.T, files map[string][]byte, dir string) {
+				writeFile(t, filepath.Join(dir, "types.go"), "package vault_prog\n")
+			},
+			expected: map[string]DriftKind{"types.go": DriftModified},
+		},
+		{
+			name: "created",
+			change: func(t *testing.T, files map[string][]byte, dir string) {
+				removeFile(t, filepath.Join(dir, "errors.go"))
+			},
+			expected: map[string]DriftKind{"errors.go": DriftCreated},
+		},
+		{
+			name: "deleted from the manifest",
+			change: func(t *testing.T, files map[string][]byte, dir string) {
+				delete(files, filepath.Join(dir, "errors.go"))
+			},
+			expected: map[string]DriftKind{"errors.go": DriftDeleted},
+		},
+		{
+			name: "hand-written file",
+			change: func(t *testing.T, files map[string][]byte, dir string) {
+				writeFile(t, filepath.Join(dir, "helpers.go"), generatedHeader)
+			},
+		},
+		{
+			name: "deleted without manifest",
+			change: func(t *testing.T, files map[string][]byte, dir string) {
+				removeFile(t, filepath.Join(dir, ManifestFileName))
+				delete(files, filepath.Join(dir, ManifestFileName))
+				delete(files, filepath.Join(dir, "errors.go"))
+				writeFile(t, filepath.Join(dir, "old.go"), generatedHeader)
+				writeFile(t, filepath.Join(dir, "kind_string.go"), stringerHeader)
+				writeFile(t, filepath.Join(dir, "helpers.go"), handWritten)
+			},
+			expected: map[string]DriftKind{"errors.go": DriftDeleted, "old.go": DriftDeleted},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files := writeVaultFiles(t)
+			var dir string
+			for path := range files {
+				dir = filepath.Dir(path)
+			}
+			tt.change(t, files, dir)
+
+			drifts, err := Check(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := map[string]DriftKind{}
+			for _, drift := range drifts {
+				got[filepath.Base(drift.Path)] = drift.Kind
+				if drift.Diff == "" {
+					t.Errorf("no diff for the drift of %s", drift.Path)
+				}
+			}
+			if len(got) != len(tt.expected) || len(drifts) != len(tt.expected) {
+				t.Fatalf("got drifts %v, expected %v", got, tt.expected)
+			}
+			for name, kind := range tt.expected {
+				if got[name] != kind {
+					t.Errorf("got drift %q for %s, expected %q", got[name], name, kind)
+				}
+			}
+		})
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func removeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCheckIgnoresGeneratorVersion(t *testing.T) {
+	files := writeVaultFiles(t)
+	var manifestPath string
+	for path := range files {
+		if filepath.Base(path) == ManifestFileName {
+			manifestPath = path
+		}
+	}
+	manifest, err := os.ReadFile(manifestPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	version := `"version": "` + Version() + `"`
+	if !strings.Contains(string(manifest), version) {
+		t.Fatalf("the manifest doesn't contain %s:\n%s", version, manifest)
+	}
+
+	tests := []struct {
+		name    string
+		replace func(manifest string) string
+		drift   bool
+	}{
+		{
+			name: "pseudo-version",
+			replace: func(manifest string) string {
+				return strings.Replace(manifest, version, `"version": "v0.0.0-20260101000000-0123456789ab"`, 1)
+			},
+		},
+		{
+			name:    "tagged version",
+			replace: func(manifest string) string { return strings.Replace(manifest, version, `"version": "v1.0.0"`, 1) },
+		},
+		{
+			name: "options",
+			replace: func(manifest string) string {
+				return strings.Replace(manifest, `"encoder": "borsh"`, `"encoder": "bin"`, 1)
+			},
+			drift: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			changed := tt.replace(string(manifest))
+			if changed == string(manifest) {
+				t.Fatal("the manifest isn't changed")
+			}
+			if err := os.WriteFile(manifestPath, []byte(changed), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			drifts, err := Check(files)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.drift {
+				if len(drifts) != 1 || drifts[0].Path != manifestPath || drifts[0].Kind != DriftModified {
+					t.Errorf("expected the manifest to be modified, got %v", drifts)
+				}
+			} else if len(drifts) != 0 {
+				t.Errorf("expected no drift, got %v", drifts)
+			}
+		})
+	}
+}
+
+func TestCheckConfigChange(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("testdata", "vault.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := ParseIdl(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	configPath := filepath.Join(dir, "anchor-go.yaml")
+	generate := func(content string) map[string][]byte {
+		t.Helper()
+		writeFile(t, configPath, content)
+		config, err := LoadConfig(configPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files, err := Generate(Options{Destination: filepath.Join(dir, "out"), Config: config}, program)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return files
+	}
+
+	if err := WriteFiles(generate("exclude:\n  instructions: []\n")); err != nil {
+		t.Fatal(err)
+	}
+	// Only the config changes, the generated code is the same.
+	files := generate("# No instruction is excluded.\nexclude:\n  instructions: []\n")
+	drifts, err := Check(files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(drifts) != 1 || filepath.Base(drifts[0].Path) != ManifestFileName || drifts[0].Kind != DriftModified {
+		t.Fatalf("expected the manifest to be modified, got %v", drifts)
+	}
+	if !strings.Contains(drifts[0].Diff, filepath.ToSlash(configPath)) {
+		t.Errorf("the manifest doesn't record the config path:\n%s", drifts[0].Diff)
+	}
+}
@@ -0,0 +1,224 @@
+// Package codegen is the public API of anchor-go, it generates Go clients for Anchor programs.
+//
+//	program, err := codegen.ParseIdl(data)
+//	if err != nil {
+//		return err
+//	}
+//	files, err := codegen.Generate(codegen.Options{Destination: "generated", GenerateTests: true}, program)
+//	if err != nil {
+//		return err
+//	}
+//	return codegen.WriteFiles(files)
+package codegen
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/alivers/anchor-go/internal/config"
+	"github.com/alivers/anchor-go/internal/diagnostic"
+	"github.com/alivers/anchor-go/internal/generator"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	"github.com/alivers/anchor-go/internal/idl/diff"
+	"github.com/alivers/anchor-go/internal/idl/legacy"
+	"github.com/alivers/anchor-go/internal/idl/onchain"
+	"github.com/gagliardetto/solana-go"
+)
+
+// Idl is the Anchor IDL model, see https://github.com/solana-foundation/anchor/blob/v0.31.1/idl/spec/src/lib.rs
+type Idl = idl.Idl
+
+type (
+	Diagnostic  = diagnostic.Diagnostic
+	Diagnostics = diagnostic.Diagnostics
+	Severity    = diagnostic.Severity
+)
+
+const (
+	SeverityError   = diagnostic.SeverityError
+	SeverityWarning = diagnostic.SeverityWarning
+)
+
+// Config is the project-level config of the generator, see `LoadConfig`.
+type Config = config.Config
+
+// Encoder is the binary encoding of the generated instructions.
+type Encoder = model.EncoderType
+
+const (
+	EncoderBorsh      = model.EncoderTypeBorsh
+	EncoderBin        = model.EncoderTypeBin
+	EncoderCompactU16 = model.EncoderTypeCompactU16
+)
+
+type Options struct {
+	// Destination folder, the files of each program are put in `<Destination>/<package name>`.
+	Destination string
+	// Import path of `Destination`, it's used to import the packages of dependent programs.
+	// It's detected from the nearest `go.mod` if empty.
+	ImportBase string
+	// Package name of the generated code, defaults to the snake case name of the program.
+	// It can only be set when generating a single program.
+	PackageName string
+	// Whether to generate the encode/decode tests of the instructions.
+	GenerateTests bool
+	// Whether to skip the optional flag when encoding or decoding optional fields.
+	SkipOptionalFlag bool
+	// Encoder of the instructions, defaults to borsh.
+	Encoder Encoder
+	// Config applied to all the programs, it's optional.
+	Config *Config
+	// Warn is called for each warning found while generating if there is no error, it's optional.
+	Warn func(Diagnostic)
+}
+
+// ParseIdl parses the JSON of an IDL.
+// The legacy IDLs (before Anchor 0.30) are detected and converted into the current spec.
+func ParseIdl(data []byte) (*Idl, error) {
+	if !legacy.IsLegacy(data) {
+		return idl.Parse(data)
+	}
+	program, err := legacy.Convert(data)
+	if err != nil {
+		return nil, err
+	}
+	program.Checksum = idl.Checksum(data)
+	return program, nil
+}
+
+// DecodeIdlAccount decodes the IDL account which Anchor stores on chain, the data is either raw or encoded in base64
+// (as returned by `getAccountInfo`).
+func DecodeIdlAccount(data []byte) (*Idl, error) {
+	account, err := onchain.Decode(data)
+	if err != nil {
+		return nil, err
+	}
+	return ParseIdl(account.Data)
+}
+
+// IdlAddress returns the address of the IDL account which Anchor stores on chain for the program.
+func IdlAddress(programID solana.PublicKey) (solana.PublicKey, error) {
+	return onchain.IdlAddress(programID)
+}
+
+// LoadIdl parses the JSON of an IDL, or decodes a dumped on-chain IDL account (see `DecodeIdlAccount`).
+func LoadIdl(data []byte) (*Idl, error) {
+	var objMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objMap); err == nil {
+		if _, ok := objMap["instructions"]; ok {
+			return ParseIdl(data)
+		}
+	}
+	return DecodeIdlAccount(data)
+}
+
+type (
+	IdlChange  = diff.Change
+	IdlChanges = diff.Changes
+	Impact     = diff.Impact
+)
+
+const (
+	ImpactBreaking = diff.ImpactBreaking
+	ImpactSafe     = diff.ImpactSafe
+)
+
+// CompareIdl reports the changes from the old version of the IDL of a program to the new one,
+// each change is either breaking (for the existing clients or the data on-chain) or safe.
+func CompareIdl(old, new *Idl) IdlChanges {
+	return diff.Compare(old, new)
+}
+
+// LoadConfig loads the config file of the generator, the format is decided by the extension (`.yaml`, `.yml` or `.json`).
+func LoadConfig(path string) (*Config, error) {
+	return config.Load(path)
+}
+
+// FindConfig returns the path of the config file (`anchor-go.yaml`, `anchor-go.yml` or `anchor-go.json`) in the folder,
+// it's empty if there is none.
+func FindConfig(dir string) (string, error) {
+	return config.Find(dir)
+}
+
+// Generate generates the Go client of the program in memory, and returns the content of the files by their path.
+// The error is `Diagnostics` if there is any problem in the IDL.
+func Generate(opts Options, program *Idl) (map[string][]byte, error) {
+	return GenerateAll(opts, program)
+}
+
+// GenerateAll generates one package for each program in memory, and returns the content of the files by their path.
+// If a program declares another program of the same run in `metadata.dependencies`,
+// the types of the dependency are imported from its package instead of being declared again.
+func GenerateAll(opts Options, programs ...*Idl) (map[string][]byte, error) {
+	files, diags, err := generator.Generate(generator.Options{
+		DstFolder:        opts.Destination,
+		ImportBase:       opts.ImportBase,
+		PkgName:          opts.PackageName,
+		GenerateTests:    opts.GenerateTests,
+		SkipOptionalFlag: opts.SkipOptionalFlag,
+		Encoder:          opts.Encoder,
+		Config:           opts.Config,
+	}, programs...)
+	if err != nil {
+		// The warnings are part of the error.
+		return nil, err
+	}
+	if opts.Warn != nil {
+		for _, warning := range diags.Warnings() {
+			opts.Warn(warning)
+		}
+	}
+	return files, nil
+}
+
+// ManifestFileName is the name of the manifest in the folder of each generated package,
+// it lists the files produced for the package, the SHA-256 of the IDL, the generator version, the options and the config file.
+const ManifestFileName = generator.ManifestFileName
+
+// Version returns the version of the generator.
+func Version() string {
+	return generator.Version()
+}
+
+// WriteFiles writes the generated files in the order of their path,
+// then removes the stale files (see `StaleFiles`).
+func WriteFiles(files map[string][]byte) error {
+	stale, err := StaleFiles(files)
+	if err != nil {
+		return err
+	}
+	for _, path := range slices.Sorted(maps.Keys(files)) {
+		if err := WriteFile(path, files[path]); err != nil {
+			return err
+		}
+	}
+	for _, path := range stale {
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// StaleFiles returns the files which are owned by the manifests on disk but no longer produced, e.g. the files of a removed instruction.
+// Files not listed in a manifest (hand-written ones) are never returned.
+// It must be called before writing the files since the manifests get overwritten.
+func StaleFiles(files map[string][]byte) ([]string, error) {
+	return findStaleGeneratedFiles(files, false)
+}
+
+// WriteFile writes one generated file, the folder is created if it doesn't exist.
+func WriteFile(path string, content []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package codegen
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGenerateCompilesWithEachEncoder(t *testing.T) {
+	goBin := lookupGo(t)
+	for _, encoder := range []Encoder{EncoderBorsh, EncoderBin, EncoderCompactU16} {
+		t.Run(encoder.String(), func(t *testing.T) {
+			pkgDir := generateVaultPackage(t, encoder)
+
+			// The generated tests encode and decode every instruction.
+			if out, err := goTest(goBin, pkgDir); err != nil {
+				t.Fatalf("generated code with the %s encoder doesn't pass its tests: %v\n%s", encoder, err, out)
+			}
+		})
+	}
+}
+
+// The keys of different variants of a complex enum are ordered by the index of the variants,
+// and the equal variants (different pointers) are rejected when encoding.
+const complexEnumKeysTest = `package vault_prog
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+
+	ag_binary "github.com/gagliardetto/binary"
+)
+
+func TestComplexEnumKeysOrder(t *testing.T) {
+	kinds := OrderedSet[Kind]{
+		&KindC{Elem0: 1, Elem1: "c"}: {},
+		&KindB{X: 2}:                 {},
+		&KindA{}:                     {},
+		&KindC{Elem0: 0, Elem1: "d"}: {},
+	}
+	var variants []string
+	for _, kind := range kinds.Elems() {
+		variants = append(variants, reflect.TypeOf(kind).Elem().Name())
+	}
+	if !reflect.DeepEqual(variants, []string{"KindA", "KindB", "KindC", "KindC"}) {
+		t.Errorf("got variants %v", variants)
+	}
+	if c := kinds.Elems()[2].(*KindC); c.Elem0 != 0 {
+		t.Errorf("the variants with the same index are not ordered by their fields: %v", c)
+	}
+
+	limits := OrderedMap[KindKey, uint64]{
+		{Kind: &KindB{X: 1}, Rank: 0}: 1,
+		{Kind: &KindA{}, Rank: 9}:     2,
+		{Kind: &KindB{X: 0}, Rank: 5}: 3,
+		{Kind: nil, Rank: 1}:          4,
+	}
+	var values []uint64
+	for _, key := range limits.Keys() {
+		values = append(values, limits[key])
+	}
+	if !reflect.DeepEqual(values, []uint64{4, 2, 3, 1}) {
+		t.Errorf("got values %v in the order of the keys", values)
+	}
+
+	// The pointers to the variants without fields may be equal (zero-size values), so the duplicates have fields.
+	duplicates := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{"set", OrderedSet[Kind]{&KindB{X: 1}: {}, &KindA{}: {}, &KindB{X: 1}: {}}, "key 2 of the set is equal to the previous one"},
+		{"map", OrderedMap[KindKey, uint64]{{Kind: &KindC{Elem0: 1}, Rank: 1}: 1, {Kind: &KindC{Elem0: 1}, Rank: 1}: 2}, "key 1 of the map is equal to the previous one"},
+	}
+	for _, tt := range duplicates {
+		err := ag_binary.NewBorshEncoder(new(bytes.Buffer)).Encode(tt.value)
+		if err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: got error %v, expected %q", tt.name, err, tt.expected)
+		}
+	}
+	distinct := OrderedSet[Kind]{&KindC{Elem0: 1, Elem1: "c"}: {}, &KindC{Elem0: 1, Elem1: "d"}: {}}
+	if err := ag_binary.NewBorshEncoder(new(bytes.Buffer)).Encode(distinct); err != nil {
+		t.Errorf("the variants with different fields are rejected: %v", err)
+	}
+}
+`
+
+func TestCompareComplexEnumKeys(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generateVaultPackage(t, EncoderBorsh)
+	runGeneratedTest(t, goBin, pkgDir, "keys_test.go", complexEnumKeysTest, "TestComplexEnumKeysOrder")
+}
+
+// lookupGo returns the path of the go command, the test is skipped if it isn't available.
+func lookupGo(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the generated code")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	return goBin
+}
+
+// generateVaultPackage generates the package of testdata/vault.json, and returns its folder.
+func generateVaultPackage(t *testing.T, encoder Encoder) string {
+	t.Helper()
+	return generatePackage(t, "vault.json", Options{GenerateTests: true, Encoder: encoder})
+}
+
+// generatePackage generates the package of the IDL file in testdata, and returns its folder.
+func generatePackage(t *testing.T, idlFile string, opts Options) string {
+	t.Helper()
+	data, err := os.ReadFile(filepath.Join("testdata", idlFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	program, err := ParseIdl(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The output is in the module, so that it's built with the dependencies of the generator.
+	dst, err := os.MkdirTemp("testdata", "out_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dst) })
+
+	opts.Destination = dst
+	files, err := Generate(opts, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteFiles(files); err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dst, program.Metadata.Name)
+}
+
+// runGeneratedTest adds the test file to the generated package, and runs the test.
+func runGeneratedTest(t *testing.T, goBin, pkgDir, fileName, source, testName string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(pkgDir, fileName), []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	out, err := goTest(goBin, pkgDir, "-run", "^"+testName+"$")
+	if err != nil {
+		t.Fatalf("%v\n%s", err, out)
+	}
+	if bytes.Contains(out, []byte("no tests to run")) {
+		t.Fatalf("test %s not found in %s", testName, fileName)
+	}
+}
+
+func goTest(goBin, pkgDir string, args ...string) ([]byte, error) {
+	args = append([]string{"test", "./" + filepath.ToSlash(pkgDir)}, args...)
+	return exec.Command(goBin, args...).CombinedOutput()
+}
@@ -0,0 +1,96 @@
+package codegen
+
+import "testing"
+
+// The registered codec of the type with custom serialization is called in both directions,
+// for the type, for the structs which contain it and for its account.
+const customCodecTest = `package custom_prog
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	ag_binary "github.com/gagliardetto/binary"
+)
+
+// bigEndianPriceCodec encodes the value in big-endian, which Borsh never does.
+type bigEndianPriceCodec struct {
+	marshaled, unmarshaled int
+}
+
+func (c *bigEndianPriceCodec) Marshal(obj Price, encoder *ag_binary.Encoder) error {
+	c.marshaled++
+	if err := encoder.WriteUint64(obj.Value, ag_binary.BE); err != nil {
+		return err
+	}
+	return encoder.WriteInt32(obj.Expo, ag_binary.BE)
+}
+
+func (c *bigEndianPriceCodec) Unmarshal(obj *Price, decoder *ag_binary.Decoder) (err error) {
+	c.unmarshaled++
+	if obj.Value, err = decoder.ReadUint64(ag_binary.BE); err != nil {
+		return err
+	}
+	obj.Expo, err = decoder.ReadInt32(ag_binary.BE)
+	return err
+}
+
+func encode(t *testing.T, value any) []byte {
+	t.Helper()
+	buf := new(bytes.Buffer)
+	if err := ag_binary.NewBorshEncoder(buf).Encode(value); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestCustomCodec(t *testing.T) {
+	order := Order{Price: Price{Value: 0x0102030405060708, Expo: -2}, Size: 0x0a0b}
+	err := ag_binary.NewBorshEncoder(new(bytes.Buffer)).Encode(order)
+	if err == nil || !strings.Contains(err.Error(), "RegisterPriceCodec") {
+		t.Fatalf("got error %v without codec", err)
+	}
+
+	codec := &bigEndianPriceCodec{}
+	RegisterPriceCodec(codec)
+	defer RegisterPriceCodec(nil)
+
+	price := []byte{1, 2, 3, 4, 5, 6, 7, 8, 0xff, 0xff, 0xff, 0xfe}
+	data := encode(t, order)
+	if expected := append(bytes.Clone(price), 0x0b, 0x0a); !bytes.Equal(data, expected) {
+		t.Errorf("got order %x, expected %x", data, expected)
+	}
+	var decodedOrder Order
+	if err := ag_binary.NewBorshDecoder(data).Decode(&decodedOrder); err != nil {
+		t.Fatal(err)
+	}
+	if decodedOrder != order {
+		t.Errorf("decoded order %+v, expected %+v", decodedOrder, order)
+	}
+
+	account := PriceAccount(order.Price)
+	data = encode(t, account)
+	if expected := append(PriceAccountDiscriminator[:], price...); !bytes.Equal(data, expected) {
+		t.Errorf("got account %x, expected %x", data, expected)
+	}
+	var decodedAccount PriceAccount
+	if err := ag_binary.NewBorshDecoder(data).Decode(&decodedAccount); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decodedAccount, account) {
+		t.Errorf("decoded account %+v, expected %+v", decodedAccount, account)
+	}
+
+	if codec.marshaled != 2 || codec.unmarshaled != 2 {
+		t.Errorf("the codec is called %d times to encode and %d times to decode, expected 2 and 2", codec.marshaled, codec.unmarshaled)
+	}
+}
+`
+
+func TestCustomCodec(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generatePackage(t, "custom.json", Options{})
+	runGeneratedTest(t, goBin, pkgDir, "custom_test.go", customCodecTest, "TestCustomCodec")
+}
@@ -0,0 +1,100 @@
+package codegen
+
+import "testing"
+
+// The instructions with discriminators of different lengths, which prefix each other, are decoded
+// by the longest discriminator which prefixes the data.
+const customDiscriminatorsTest = `package discriminators_prog
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCustomDiscriminators(t *testing.T) {
+	tests := []struct {
+		name        string
+		instruction *Instruction
+		// The data after the discriminator.
+		args []byte
+	}{
+		// The value makes the data start like the discriminator of Long.
+		{"Short", NewShortInstruction(1).Build(), []byte{1}},
+		{"Long", NewLongInstruction(0x0807060504030201).Build(), []byte{1, 2, 3, 4, 5, 6, 7, 8}},
+		{"Other", NewOtherInstruction().Build(), nil},
+	}
+	for _, tt := range tests {
+		data, err := tt.instruction.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		discriminator := data[:len(data)-len(tt.args)]
+		if !bytes.Equal(data[len(discriminator):], tt.args) {
+			t.Errorf("%s: got data %v", tt.name, data)
+		}
+		if name := InstructionIDToName(discriminator); name != tt.name {
+			t.Errorf("%s: got name %q of the discriminator %v", tt.name, name, discriminator)
+		}
+		if name := InstructionIDToName(data); name != tt.name {
+			t.Errorf("%s: got name %q of the data %v", tt.name, name, data)
+		}
+
+		decoded, err := DecodeInstruction(nil, data)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if name := reflect.TypeOf(decoded.Impl).Elem().Name(); name != tt.name {
+			t.Errorf("%s: decoded instruction %s", tt.name, name)
+		}
+		// The type ID is the discriminator padded to 8 bytes.
+		if name := InstructionIDToName(decoded.TypeID.Bytes()); name != tt.name {
+			t.Errorf("%s: got name %q of the type ID %v", tt.name, name, decoded.TypeID.Bytes())
+		}
+		encoded, err := decoded.Data()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(encoded, data) {
+			t.Errorf("%s: encoded the decoded instruction as %v, expected %v", tt.name, encoded, data)
+		}
+	}
+
+	if name := InstructionIDToName([]byte{9}); name != "" {
+		t.Errorf("got name %q of a part of a discriminator", name)
+	}
+	if _, err := DecodeInstruction(nil, []byte{9, 1}); err == nil || !strings.Contains(err.Error(), "no known instruction") {
+		t.Errorf("got error %v for unknown data", err)
+	}
+}
+`
+
+// The IDs of the instructions are the 8-byte type IDs with the discriminators of Anchor.
+const anchorInstructionIDTest = `package vault_prog
+
+import (
+	"testing"
+
+	ag_binary "github.com/gagliardetto/binary"
+)
+
+func TestAnchorInstructionID(t *testing.T) {
+	var idToName func(ag_binary.TypeID) string = InstructionIDToName
+	if name := idToName(Instruction_Initialize); name != "Initialize" {
+		t.Errorf("got name %q", name)
+	}
+}
+`
+
+func TestInstructionDiscriminators(t *testing.T) {
+	goBin := lookupGo(t)
+	t.Run("custom", func(t *testing.T) {
+		pkgDir := generatePackage(t, "custom_discriminators.json", Options{})
+		runGeneratedTest(t, goBin, pkgDir, "discriminators_test.go", customDiscriminatorsTest, "TestCustomDiscriminators")
+	})
+	t.Run("anchor", func(t *testing.T) {
+		pkgDir := generateVaultPackage(t, EncoderBorsh)
+		runGeneratedTest(t, goBin, pkgDir, "discriminators_test.go", anchorInstructionIDTest, "TestAnchorInstructionID")
+	})
+}
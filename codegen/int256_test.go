@@ -0,0 +1,122 @@
+package codegen
+
+import "testing"
+
+// The boundary values of the 256-bit integers are converted from and to big integers, Borsh and JSON.
+const int256RoundTripTest = `package int256_prog
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/big"
+	"strconv"
+	"testing"
+
+	ag_binary "github.com/gagliardetto/binary"
+)
+
+func littleEndian(last byte, fill byte) []byte {
+	data := bytes.Repeat([]byte{fill}, 32)
+	data[31] = last
+	return data
+}
+
+func TestInt256RoundTrip(t *testing.T) {
+	one := big.NewInt(1)
+	i256Max := new(big.Int).Sub(new(big.Int).Lsh(one, 255), one)
+	i256Min := new(big.Int).Neg(new(big.Int).Lsh(one, 255))
+	u256Max := new(big.Int).Sub(new(big.Int).Lsh(one, 256), one)
+
+	signed := []struct {
+		value    *big.Int
+		expected []byte
+	}{
+		{i256Min, littleEndian(0x80, 0x00)},
+		{i256Max, littleEndian(0x7f, 0xff)},
+		{big.NewInt(-1), littleEndian(0xff, 0xff)},
+		{big.NewInt(0), littleEndian(0x00, 0x00)},
+	}
+	for _, tt := range signed {
+		value, err := NewInt256(tt.value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := (Amounts{Signed: value}).MarshalWithEncoder(ag_binary.NewBorshEncoder(buf)); err != nil {
+			t.Fatal(err)
+		}
+		data := buf.Bytes()
+		if !bytes.Equal(data[32:], tt.expected) {
+			t.Errorf("%s: got encoding %x, expected %x", tt.value, data[32:], tt.expected)
+		}
+		var decoded Amounts
+		if err := decoded.UnmarshalWithDecoder(ag_binary.NewBorshDecoder(data)); err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Signed.BigInt().Cmp(tt.value) != 0 {
+			t.Errorf("%s: decoded %s", tt.value, decoded.Signed)
+		}
+
+		text, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(text) != strconv.Quote(tt.value.String()) {
+			t.Errorf("%s: got JSON %s", tt.value, text)
+		}
+		var parsed Int256
+		if err := json.Unmarshal(text, &parsed); err != nil || parsed != value {
+			t.Errorf("%s: parsed %s from JSON: %v", tt.value, parsed, err)
+		}
+	}
+
+	u256, err := NewUint256(u256Max)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(u256[:], littleEndian(0xff, 0xff)) {
+		t.Errorf("got u256 max %x", u256[:])
+	}
+	data, err := NewSetInstruction(Amounts{Unsigned: u256}, u256).Build().Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The data starts with the discriminator.
+	decoded := new(Set)
+	if err := decoded.UnmarshalWithDecoder(ag_binary.NewBorshDecoder(data[8:])); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Limit.BigInt().Cmp(u256Max) != 0 || decoded.Amounts.Unsigned.BigInt().Cmp(u256Max) != 0 {
+		t.Errorf("decoded %s and %s, expected %s", decoded.Limit, decoded.Amounts.Unsigned, u256Max)
+	}
+	var parsed Uint256
+	if err := parsed.UnmarshalText([]byte("0x" + u256Max.Text(16))); err != nil || parsed != u256 {
+		t.Errorf("parsed %s from hex: %v", parsed, err)
+	}
+
+	overflows := []struct {
+		name string
+		err  error
+	}{
+		{"i256 max + 1", second(NewInt256(new(big.Int).Add(i256Max, one)))},
+		{"i256 min - 1", second(NewInt256(new(big.Int).Sub(i256Min, one)))},
+		{"u256 max + 1", second(NewUint256(new(big.Int).Add(u256Max, one)))},
+		{"-1 as u256", second(NewUint256(big.NewInt(-1)))},
+	}
+	for _, tt := range overflows {
+		if tt.err == nil {
+			t.Errorf("%s doesn't overflow", tt.name)
+		}
+	}
+}
+
+func second[T any](_ T, err error) error {
+	return err
+}
+`
+
+func TestInt256RoundTrip(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generatePackage(t, "int256.json", Options{})
+	runGeneratedTest(t, goBin, pkgDir, "int256_test.go", int256RoundTripTest, "TestInt256RoundTrip")
+}
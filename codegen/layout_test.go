@@ -0,0 +1,82 @@
+package codegen
+
+import "testing"
+
+// The zero-copy account is encoded with the layout of this Rust struct (u128 is 8-byte aligned on SBF):
+//
+//	#[repr(C)] struct Pool { flag: u8, amount: u64, fee: u16, inner: Inner, reward: u128, packed: Packed, aligned: Aligned, tail: u8 }
+//	#[repr(C)] struct Inner { a: u32, b: u8 }          // size 8, align 4
+//	#[repr(C, packed)] struct Packed { a: u8, b: u64 } // size 9, align 1
+//	#[repr(C, align(16))] struct Aligned { x: u8 }     // size 16, align 16
+const zeroCopyLayoutTest = `package zero_copy_prog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"reflect"
+	"testing"
+
+	ag_binary "github.com/gagliardetto/binary"
+)
+
+func TestZeroCopyLayout(t *testing.T) {
+	pool := PoolAccount{
+		Flag:    0x01,
+		Amount:  0x0203040506070809,
+		Fee:     0x0a0b,
+		Inner:   Inner{A: 0x0c0d0e0f, B: 0x10},
+		Reward:  ag_binary.Uint128{Lo: 0x1112131415161718, Hi: 0x191a1b1c1d1e1f20},
+		Packed:  Packed{A: 0x21, B: 0x2223242526272829},
+		Aligned: Aligned{X: 0x2a},
+		Tail:    0x2b,
+	}
+
+	// The offsets of the fields of the struct, after the discriminator.
+	expected := make([]byte, 96)
+	expected[0] = 0x01
+	binary.LittleEndian.PutUint64(expected[8:], 0x0203040506070809)
+	binary.LittleEndian.PutUint16(expected[16:], 0x0a0b)
+	binary.LittleEndian.PutUint32(expected[20:], 0x0c0d0e0f)
+	expected[24] = 0x10
+	binary.LittleEndian.PutUint64(expected[32:], 0x1112131415161718)
+	binary.LittleEndian.PutUint64(expected[40:], 0x191a1b1c1d1e1f20)
+	expected[48] = 0x21
+	binary.LittleEndian.PutUint64(expected[49:], 0x2223242526272829)
+	expected[64] = 0x2a
+	expected[80] = 0x2b
+	expected = append(PoolAccountDiscriminator[:], expected...)
+
+	buf := new(bytes.Buffer)
+	if err := pool.MarshalWithEncoder(ag_binary.NewBorshEncoder(buf)); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), expected) {
+		t.Errorf("got\n%x\nexpected\n%x", buf.Bytes(), expected)
+	}
+
+	// The padding is skipped whatever its content.
+	padded := bytes.Clone(expected)
+	for _, padding := range [][2]int{{1, 8}, {18, 20}, {25, 28}, {28, 32}, {57, 64}, {65, 80}, {81, 96}} {
+		for i := padding[0]; i < padding[1]; i++ {
+			padded[8+i] = 0xff
+		}
+	}
+	var decoded PoolAccount
+	decoder := ag_binary.NewBorshDecoder(padded)
+	if err := decoded.UnmarshalWithDecoder(decoder); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, pool) {
+		t.Errorf("decoded %+v, expected %+v", decoded, pool)
+	}
+	if decoder.Remaining() != 0 {
+		t.Errorf("%d bytes are left after the struct", decoder.Remaining())
+	}
+}
+`
+
+func TestZeroCopyLayout(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generatePackage(t, "zero_copy.json", Options{})
+	runGeneratedTest(t, goBin, pkgDir, "layout_test.go", zeroCopyLayoutTest, "TestZeroCopyLayout")
+}
@@ -0,0 +1,70 @@
+package codegen
+
+import "testing"
+
+// The unset optional payer is passed as the program ID, and decoded back as unset.
+const optionalAccountTest = `package pda_prog
+
+import (
+	"testing"
+
+	ag_solanago "github.com/gagliardetto/solana-go"
+)
+
+func TestOptionalAccount(t *testing.T) {
+	owner := ag_solanago.NewWallet().PublicKey()
+	builder := NewOpenInstructionBuilder().
+		SetIndex(1).
+		SetOwnerAccount(owner).
+		SetPositionAccount(ag_solanago.NewWallet().PublicKey()).
+		SetConfigAccount(ag_solanago.NewWallet().PublicKey()).
+		SetVaultAccount(ag_solanago.NewWallet().PublicKey())
+
+	instruction := builder.Build()
+	if payer := instruction.Accounts()[4]; payer == nil || *payer != *ag_solanago.Meta(ProgramID) {
+		t.Errorf("got payer %+v, expected the read-only program ID", payer)
+	}
+	if builder.GetPayerAccount() != nil {
+		t.Error("the placeholder is set on the builder")
+	}
+	built, err := builder.ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payer := built.Accounts()[4]; payer == nil || *payer != *ag_solanago.Meta(ProgramID) {
+		t.Errorf("got payer %+v from ValidateAndBuild, expected the read-only program ID", payer)
+	}
+
+	data, err := instruction.Data()
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeInstruction(instruction.Accounts(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	open := decoded.Impl.(*Open)
+	if payer := open.GetPayerAccount(); payer != nil {
+		t.Errorf("decoded payer %+v, expected none", payer)
+	}
+	if !open.GetOwnerAccount().PublicKey.Equals(owner) {
+		t.Errorf("decoded owner %s, expected %s", open.GetOwnerAccount().PublicKey, owner)
+	}
+
+	// A set payer is decoded as it is.
+	payerKey := ag_solanago.NewWallet().PublicKey()
+	instruction = builder.SetPayerAccount(payerKey).Build()
+	if decoded, err = DecodeInstruction(instruction.Accounts(), data); err != nil {
+		t.Fatal(err)
+	}
+	if payer := decoded.Impl.(*Open).GetPayerAccount(); payer == nil || *payer != *ag_solanago.Meta(payerKey).SIGNER() {
+		t.Errorf("decoded payer %+v, expected the signer %s", payer, payerKey)
+	}
+}
+`
+
+func TestOptionalAccount(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generatePackage(t, "pda.json", Options{})
+	runGeneratedTest(t, goBin, pkgDir, "optional_test.go", optionalAccountTest, "TestOptionalAccount")
+}
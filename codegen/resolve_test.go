@@ -0,0 +1,74 @@
+package codegen
+
+import "testing"
+
+// The position PDA is derived from the config PDA which is listed after it, and the vault PDA
+// from the optional payer.
+const resolvePdaTest = `package pda_prog
+
+import (
+	"strings"
+	"testing"
+
+	ag_solanago "github.com/gagliardetto/solana-go"
+)
+
+func findAddress(t *testing.T, seeds ...[]byte) ag_solanago.PublicKey {
+	t.Helper()
+	address, _, err := ag_solanago.FindProgramAddress(seeds, ProgramID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return address
+}
+
+func TestResolvePdaAccounts(t *testing.T) {
+	owner := ag_solanago.NewWallet().PublicKey()
+	payer := ag_solanago.NewWallet().PublicKey()
+	builder := NewOpenInstructionBuilder().SetIndex(0x0102).SetOwnerAccount(owner)
+
+	// The vault can't be derived without the payer.
+	_, err := builder.ValidateAndBuild()
+	if err == nil || !strings.Contains(err.Error(), "accounts.Vault: account payer is not set") {
+		t.Fatalf("got error %v without the payer", err)
+	}
+	for _, account := range []string{"Config", "Position"} {
+		if strings.Contains(err.Error(), "accounts."+account) {
+			t.Errorf("%s is reported as unresolved: %v", account, err)
+		}
+	}
+
+	instruction, err := builder.SetPayerAccount(payer).ValidateAndBuild()
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := instruction.Accounts()
+	config := findAddress(t, []byte("config"), owner.Bytes())
+	expected := []*ag_solanago.AccountMeta{
+		ag_solanago.Meta(owner).SIGNER(),
+		ag_solanago.Meta(findAddress(t, []byte("pos"), config.Bytes(), []byte{0x02, 0x01})).WRITE(),
+		ag_solanago.Meta(config),
+		ag_solanago.Meta(findAddress(t, payer.Bytes())).WRITE(),
+		ag_solanago.Meta(payer).SIGNER(),
+	}
+	if len(accounts) != len(expected) {
+		t.Fatalf("got %d accounts, expected %d", len(accounts), len(expected))
+	}
+	for i, account := range accounts {
+		if *account != *expected[i] {
+			t.Errorf("account %d: got %+v, expected %+v", i, account, expected[i])
+		}
+	}
+
+	// The PDAs are resolved on a copy, the builder is unchanged.
+	if builder.GetConfigAccount() != nil || builder.GetPositionAccount() != nil || builder.GetVaultAccount() != nil {
+		t.Error("the PDAs are set on the builder")
+	}
+}
+`
+
+func TestResolvePdaAccounts(t *testing.T) {
+	goBin := lookupGo(t)
+	pkgDir := generatePackage(t, "pda.json", Options{})
+	runGeneratedTest(t, goBin, pkgDir, "resolve_test.go", resolvePdaTest, "TestResolvePdaAccounts")
+}
@@ -0,0 +1,177 @@
+package config
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileNames are the names of the config file which is looked up next to the IDL, in order of priority.
+var FileNames = []string{"anchor-go.yaml", "anchor-go.yml", "anchor-go.json"}
+
+// Config is the project-level config of the generator.
+//
+//	renames:
+//	  instructions: {initialize: Setup}
+//	  accounts: {Vault: VaultState}
+//	  types: {FeeTier: Tier}
+//	  fields: {"Vault.amount": Lamports, "deposit.amount": Lamports}
+//	overrides:
+//	  u64: github.com/me/mypkg.Lamports
+//	  Decimal: github.com/me/mypkg.Decimal
+//	exclude:
+//	  instructions: [deprecated_withdraw]
+//	skipOptionalFlag: {"Vault.delegate": true}
+//
+// Items are referred by their names in the IDL. A field is referred as `<owner>.<field>`,
+// the owner is the name of a type, an account, an event or an instruction (for the args).
+type Config struct {
+	Renames Renames `json:"renames" yaml:"renames"`
+	// Maps a simple IDL type (e.g. `u64`) or a defined type to a Go type `<import path>.<name>`.
+	// The Go type is used as is, it must implement the codec of the IDL type,
+	// the definition of an overridden defined type is not generated.
+	Overrides map[string]string `json:"overrides" yaml:"overrides"`
+	Exclude   Exclude           `json:"exclude" yaml:"exclude"`
+	// Whether to skip the optional flag when encoding or decoding the optional field, it overrides the global option.
+	SkipOptionalFlag map[string]bool `json:"skipOptionalFlag" yaml:"skipOptionalFlag"`
+
+	// Path of the file which the config is loaded from, it's empty if the config isn't loaded by `Load`.
+	Path string `json:"-" yaml:"-"`
+	// Hex SHA-256 of the file which the config is loaded from.
+	Checksum string `json:"-" yaml:"-"`
+}
+
+// Renames maps the names in the IDL to the Go identifiers.
+type Renames struct {
+	Instructions map[string]string `json:"instructions" yaml:"instructions"`
+	Accounts     map[string]string `json:"accounts" yaml:"accounts"`
+	Types        map[string]string `json:"types" yaml:"types"`
+	Fields       map[string]string `json:"fields" yaml:"fields"`
+}
+
+type Exclude struct {
+	Instructions []string `json:"instructions" yaml:"instructions"`
+}
+
+// GoType is a Go type declared in another package.
+type GoType struct {
+	ImportPath string
+	Name       string
+}
+
+// Find returns the path of the config file in the folder, it's empty if there is none.
+func Find(dir string) (string, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+	}
+	return "", nil
+}
+
+// Load loads the config file, the format is decided by the extension (`.yaml`, `.yml` or `.json`).
+func Load(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config Config
+	switch ext := filepath.Ext(path); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(&config); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&config); err != nil {
+			return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unknown config format: %s", ext)
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	sum := sha256.Sum256(data)
+	config.Path, config.Checksum = path, hex.EncodeToString(sum[:])
+	return &config, nil
+}
+
+// Validate checks the renames are valid Go identifiers and the overrides are valid Go types.
+func (config *Config) Validate() error {
+	var errs []error
+	for _, item := range []struct {
+		kind    string
+		renames map[string]string
+	}{
+		{"instructions", config.Renames.Instructions},
+		{"accounts", config.Renames.Accounts},
+		{"types", config.Renames.Types},
+		{"fields", config.Renames.Fields},
+	} {
+		kind := item.kind
+		for _, name := range slices.Sorted(maps.Keys(item.renames)) {
+			ident := item.renames[name]
+			if !token.IsIdentifier(ident) {
+				errs = append(errs, fmt.Errorf("renames.%s.%s: %q is not a valid Go identifier", kind, name, ident))
+			}
+			if kind == "fields" {
+				if _, _, ok := SplitFieldKey(name); !ok {
+					errs = append(errs, fmt.Errorf("renames.fields.%s: field must be referred as <owner>.<field>", name))
+				}
+			}
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.Overrides)) {
+		if _, err := ParseGoType(config.Overrides[name]); err != nil {
+			errs = append(errs, fmt.Errorf("overrides.%s: %w", name, err))
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(config.SkipOptionalFlag)) {
+		if _, _, ok := SplitFieldKey(name); !ok {
+			errs = append(errs, fmt.Errorf("skipOptionalFlag.%s: field must be referred as <owner>.<field>", name))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// SplitFieldKey splits `<owner>.<field>` into the owner and the field.
+func SplitFieldKey(key string) (owner string, field string, ok bool) {
+	owner, field, ok = strings.Cut(key, ".")
+	return owner, field, ok && owner != "" && field != "" && !strings.Contains(field, ".")
+}
+
+// ParseGoType parses `<import path>.<name>`, e.g. `github.com/me/mypkg.Lamports`.
+func ParseGoType(s string) (GoType, error) {
+	slash := strings.LastIndex(s, "/")
+	dot := strings.LastIndex(s, ".")
+	if dot <= slash+1 || dot == len(s)-1 {
+		return GoType{}, fmt.Errorf("%q is not a Go type like <import path>.<name>", s)
+	}
+	goType := GoType{ImportPath: s[:dot], Name: s[dot+1:]}
+	if !token.IsIdentifier(goType.Name) || !token.IsExported(goType.Name) {
+		return GoType{}, fmt.Errorf("%q is not an exported Go identifier", goType.Name)
+	}
+	return goType, nil
+}
@@ -0,0 +1,101 @@
+package diagnostic
+
+import (
+	"fmt"
+	"strings"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+func (s Severity) String() string {
+	return string(s)
+}
+
+// Diagnostic is a problem found in an IDL.
+type Diagnostic struct {
+	// Name of the program which the IDL belongs to.
+	Program string
+	// Location in the IDL, e.g. `instructions[3].accounts[2].pda.seeds[1]`.
+	Path     string
+	Message  string
+	Severity Severity
+}
+
+func (d Diagnostic) String() string {
+	location := d.Path
+	if d.Program != "" {
+		location = d.Program + ": " + d.Path
+	}
+	return fmt.Sprintf("[%s] %s: %s", d.Severity, location, d.Message)
+}
+
+// Diagnostics is the list of problems found in one pass.
+// It implements `error`, so it can be returned as an aggregated error.
+type Diagnostics []Diagnostic
+
+func (d *Diagnostics) Errorf(program, path, format string, args ...any) {
+	d.add(SeverityError, program, path, format, args...)
+}
+
+func (d *Diagnostics) Warnf(program, path, format string, args ...any) {
+	d.add(SeverityWarning, program, path, format, args...)
+}
+
+func (d *Diagnostics) add(severity Severity, program, path, format string, args ...any) {
+	*d = append(*d, Diagnostic{
+		Program:  program,
+		Path:     path,
+		Message:  fmt.Sprintf(format, args...),
+		Severity: severity,
+	})
+}
+
+func (d Diagnostics) HasErrors() bool {
+	for _, item := range d {
+		if item.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// Errors returns the diagnostics of `error` severity.
+func (d Diagnostics) Errors() Diagnostics {
+	return d.filter(SeverityError)
+}
+
+// Warnings returns the diagnostics of `warning` severity.
+func (d Diagnostics) Warnings() Diagnostics {
+	return d.filter(SeverityWarning)
+}
+
+func (d Diagnostics) filter(severity Severity) Diagnostics {
+	var result Diagnostics
+	for _, item := range d {
+		if item.Severity == severity {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// Err returns the diagnostics as an error if there is any diagnostic of `error` severity, otherwise nil.
+func (d Diagnostics) Err() error {
+	if d.HasErrors() {
+		return d
+	}
+	return nil
+}
+
+func (d Diagnostics) Error() string {
+	lines := make([]string, len(d))
+	for i, item := range d {
+		lines[i] = item.String()
+	}
+	return strings.Join(lines, "\n")
+}
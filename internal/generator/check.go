@@ -0,0 +1,33 @@
+package generator
+
+import (
+	"fmt"
+
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// checkSupportedTypes reports the types which can't be generated,
+// so the code generators don't need to deal with them.
+func checkSupportedTypes(ctx *model.GenerateCtx, program *idl.Idl) {
+	for i, typ := range program.Types {
+		if !typ.Type.IsStruct() && !typ.Type.IsEnum() && !typ.Type.IsType() {
+			ctx.Errorf(fmt.Sprintf("types[%d].type", i), "missing type definition of %s", typ.Name)
+		}
+	}
+
+	program.VisitTypes(func(path string, typ *idl.IdlType) {
+		switch {
+		case typ.IsEmpty():
+			ctx.Errorf(path, "missing type")
+		case typ.IsArray():
+			if arrayLen := typ.GetArray().Len; arrayLen.IsGeneric() {
+				if _, ok := ctx.GetArrayLen(arrayLen.GetGeneric().Value); !ok {
+					ctx.Errorf(path, "unresolved array length %s, it's neither a const generic param nor an integer constant of the program", arrayLen.GetGeneric().Value)
+				}
+			} else if !typ.GetArray().Len.IsValue() {
+				ctx.Errorf(path, "missing array length")
+			}
+		}
+	})
+}
@@ -0,0 +1,369 @@
+package generator
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/config"
+	"github.com/alivers/anchor-go/internal/diagnostic"
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// configApplier applies the generator config to the programs, and tracks the config items which are used,
+// as the config is shared by all the programs of a run.
+type configApplier struct {
+	config *config.Config
+	used   map[string]bool
+	diags  diagnostic.Diagnostics
+}
+
+func newConfigApplier(cfg *config.Config) *configApplier {
+	return &configApplier{config: cfg, used: map[string]bool{}}
+}
+
+// apply rewrites the program (which must be a copy) with the config, and returns the options of its fields
+// by `<owner>.<field>` with the names after the renames, see `model.GenerateCtx.FieldOptionRegistry`.
+// The items are referred by their names in the original IDL, so the renames are applied at last.
+func (a *configApplier) apply(program *idl.Idl) map[string]model.FieldOption {
+	a.excludeInstructions(program)
+	a.removeOverriddenTypes(program)
+	// The renames don't change the order of the fields, so the options are matched by their position.
+	var options []model.FieldOption
+	visitFields(program, func(owner string, field *idl.IdlField) {
+		options = append(options, a.fieldOption(owner, field))
+	})
+	a.renameFields(program)
+	a.renameInstructions(program)
+	a.renameTypes(program, "accounts", a.config.Renames.Accounts, func(name string) bool {
+		return slices.ContainsFunc(program.Accounts, func(acc idl.IdlAccount) bool { return acc.Name == name })
+	})
+	a.renameTypes(program, "types", a.config.Renames.Types, func(name string) bool {
+		return program.FindTypeByName(name) != nil
+	})
+
+	fieldOptions := map[string]model.FieldOption{}
+	i := 0
+	visitFields(program, func(owner string, field *idl.IdlField) {
+		option := options[i]
+		if option.IdlName == field.Name {
+			option.IdlName = ""
+		}
+		if option != (model.FieldOption{}) {
+			fieldOptions[owner+"."+field.Name] = option
+		}
+		i++
+	})
+	return fieldOptions
+}
+
+// warnUnused reports the config items which don't match anything in any program.
+func (a *configApplier) warnUnused() {
+	for _, item := range []struct {
+		kind  string
+		names []string
+	}{
+		{"renames.instructions", slices.Collect(maps.Keys(a.config.Renames.Instructions))},
+		{"renames.accounts", slices.Collect(maps.Keys(a.config.Renames.Accounts))},
+		{"renames.types", slices.Collect(maps.Keys(a.config.Renames.Types))},
+		{"renames.fields", slices.Collect(maps.Keys(a.config.Renames.Fields))},
+		{"overrides", slices.Collect(maps.Keys(a.config.Overrides))},
+		{"exclude.instructions", a.config.Exclude.Instructions},
+		{"skipOptionalFlag", slices.Collect(maps.Keys(a.config.SkipOptionalFlag))},
+	} {
+		slices.Sort(item.names)
+		for _, name := range item.names {
+			if !a.used[item.kind+"."+name] {
+				a.diags.Warnf("", "config: "+item.kind+"."+name, "%s doesn't match anything in the IDLs", name)
+			}
+		}
+	}
+}
+
+func (a *configApplier) use(kind, name string) {
+	a.used[kind+"."+name] = true
+}
+
+func (a *configApplier) excludeInstructions(program *idl.Idl) {
+	program.Instructions = slices.DeleteFunc(program.Instructions, func(inst idl.IdlInstruction) bool {
+		if slices.Contains(a.config.Exclude.Instructions, inst.Name) {
+			a.use("exclude.instructions", inst.Name)
+			return true
+		}
+		return false
+	})
+}
+
+// removeOverriddenTypes removes the definitions of the overridden defined types, as the Go types are declared by the user.
+func (a *configApplier) removeOverriddenTypes(program *idl.Idl) {
+	for name := range a.config.Overrides {
+		if idl.IdlTypeSimple(name).IsValid() {
+			program.VisitTypes(func(_ string, typ *idl.IdlType) {
+				if typ.IsSimple() && typ.GetSimple().String() == name {
+					a.use("overrides", name)
+				}
+			})
+		}
+	}
+
+	program.Types = slices.DeleteFunc(program.Types, func(typ idl.IdlTypeDef) bool {
+		if _, ok := a.config.Overrides[typ.Name]; !ok {
+			return false
+		}
+		a.use("overrides", typ.Name)
+		for i, acc := range program.Accounts {
+			if acc.Name == typ.Name {
+				a.diags.Errorf(program.Metadata.Name, fmt.Sprintf("accounts[%d]", i), "type of account %s can't be overridden", acc.Name)
+				return false
+			}
+		}
+		for i, evt := range program.Events {
+			if evt.Name == typ.Name {
+				a.diags.Errorf(program.Metadata.Name, fmt.Sprintf("events[%d]", i), "type of event %s can't be overridden", evt.Name)
+				return false
+			}
+		}
+		return true
+	})
+}
+
+// visitFields calls fn for the named fields of the types and the args of the instructions, with the name of their owner.
+func visitFields(program *idl.Idl, fn func(owner string, field *idl.IdlField)) {
+	for i := range program.Types {
+		typ := &program.Types[i]
+		if typ.Type.IsStruct() && typ.Type.GetStruct().Fields != nil && typ.Type.GetStruct().Fields.IsNamed() {
+			for j := range typ.Type.GetStruct().Fields.GetNamed().Fields {
+				fn(typ.Name, &typ.Type.GetStruct().Fields.GetNamed().Fields[j])
+			}
+		}
+	}
+	for i := range program.Accounts {
+		acc := &program.Accounts[i]
+		// The account type of the old IDL spec, which is not shared with `types`.
+		if acc.Type.IsStruct() && acc.Type.GetStruct().Fields != nil && acc.Type.GetStruct().Fields.IsNamed() && program.FindTypeByName(acc.Name) == nil {
+			for j := range acc.Type.GetStruct().Fields.GetNamed().Fields {
+				fn(acc.Name, &acc.Type.GetStruct().Fields.GetNamed().Fields[j])
+			}
+		}
+	}
+	for i := range program.Instructions {
+		inst := &program.Instructions[i]
+		for j := range inst.Args {
+			fn(inst.Name, &inst.Args[j])
+		}
+	}
+}
+
+// fieldOption returns the option of the field of the owner, with its name in the IDL.
+func (a *configApplier) fieldOption(owner string, field *idl.IdlField) model.FieldOption {
+	key := owner + "." + field.Name
+	option := model.FieldOption{IdlName: field.Name}
+	if skip, ok := a.config.SkipOptionalFlag[key]; ok {
+		a.use("skipOptionalFlag", key)
+		option.SkipOptionalFlag = &skip
+	}
+	return option
+}
+
+func (a *configApplier) renameFields(program *idl.Idl) {
+	visitFields(program, func(owner string, field *idl.IdlField) {
+		key := owner + "." + field.Name
+		goName, ok := a.config.Renames.Fields[key]
+		if !ok {
+			return
+		}
+		a.use("renames.fields", key)
+		// The Go identifier is the camel case of the name, which stays in the case of the IDL
+		// as it's also the name of the parameters, e.g. `SetLamports(lamports uint64)`.
+		newName := helper.ToLowerCamelCase(goName)
+
+		// The seeds refer to the args and the fields of the accounts by their names, at any depth of the path.
+		visitSeeds(program, func(inst *idl.IdlInstruction, seed *idl.IdlSeed) {
+			var path *string
+			switch {
+			case seed.IsArg():
+				path = &seed.GetArg().Path
+			case seed.IsAccount():
+				path = &seed.GetAccount().Path
+			default:
+				return
+			}
+			segments := strings.Split(*path, ".")
+			for i, segmentOwner := range seedPathOwners(program, inst, seed) {
+				if segmentOwner == owner && segments[i] == field.Name {
+					segments[i] = newName
+				}
+			}
+			*path = strings.Join(segments, ".")
+		})
+		field.Name = newName
+	})
+}
+
+func (a *configApplier) renameInstructions(program *idl.Idl) {
+	for i := range program.Instructions {
+		inst := &program.Instructions[i]
+		if newName, ok := a.config.Renames.Instructions[inst.Name]; ok {
+			a.use("renames.instructions", inst.Name)
+			inst.Name = newName
+		}
+	}
+}
+
+// renameTypes renames the defined types, together with the accounts and events sharing the name and all the references.
+func (a *configApplier) renameTypes(program *idl.Idl, kind string, renames map[string]string, exists func(name string) bool) {
+	referenced := program.ReferencedTypeNames()
+	for _, name := range slices.Sorted(maps.Keys(renames)) {
+		if exists(name) {
+			a.use("renames."+kind, name)
+		} else if _, ok := referenced[name]; !ok {
+			// The types of the dependencies are referred without being declared.
+			continue
+		}
+		newName := renames[name]
+
+		for i := range program.Types {
+			if program.Types[i].Name == name {
+				program.Types[i].Name = newName
+			}
+		}
+		for i := range program.Accounts {
+			if program.Accounts[i].Name == name {
+				program.Accounts[i].Name = newName
+			}
+		}
+		for i := range program.Events {
+			if program.Events[i].Name == name {
+				program.Events[i].Name = newName
+			}
+		}
+		program.VisitTypes(func(_ string, typ *idl.IdlType) {
+			if typ.IsDefined() && typ.GetDefined().Name == name {
+				typ.GetDefined().Name = newName
+			}
+		})
+		visitSeeds(program, func(_ *idl.IdlInstruction, seed *idl.IdlSeed) {
+			if seed.IsAccount() && seed.GetAccount().Account != nil && *seed.GetAccount().Account == name {
+				seed.GetAccount().Account = &newName
+			}
+		})
+	}
+}
+
+func visitSeeds(program *idl.Idl, fn func(inst *idl.IdlInstruction, seed *idl.IdlSeed)) {
+	for i := range program.Instructions {
+		inst := &program.Instructions[i]
+		for _, account := range inst.GetAccounts() {
+			if account.Pda == nil {
+				continue
+			}
+			for j := range account.Pda.Seeds {
+				fn(inst, &account.Pda.Seeds[j])
+			}
+			if account.Pda.Program != nil {
+				fn(inst, account.Pda.Program)
+			}
+		}
+	}
+}
+
+// seedPathOwners returns the owner of each segment of the seed path like `pool.fees.authority`, in the sense of `visitFields`:
+// the instruction for the arg, then the struct type declaring the field. It's empty for the segments which aren't fields,
+// i.e. the account of an account seed, or the fields of the types which can't be resolved.
+func seedPathOwners(program *idl.Idl, inst *idl.IdlInstruction, seed *idl.IdlSeed) []string {
+	var (
+		segments []string
+		owner    string
+	)
+	switch {
+	case seed.IsArg():
+		segments = strings.Split(seed.GetArg().Path, ".")
+		owner = inst.Name
+	case seed.IsAccount():
+		segments = strings.Split(seed.GetAccount().Path, ".")
+	default:
+		return nil
+	}
+
+	owners := make([]string, len(segments))
+	for i, segment := range segments {
+		owners[i] = owner
+		switch {
+		case i == 0 && seed.IsArg():
+			owner = structTypeName(program, argType(inst, segment))
+		case i == 0:
+			// The data of the account is of the account type.
+			owner = ""
+			if seed.GetAccount().Account != nil {
+				owner = structTypeName(program, &idl.IdlType{IdlTypeDefined: &idl.IdlTypeDefined{Name: *seed.GetAccount().Account}})
+			}
+		default:
+			owner = structTypeName(program, structFieldType(program, owner, segment))
+		}
+	}
+	return owners
+}
+
+// argType returns the type of the instruction arg, it's nil if there is no such arg.
+func argType(inst *idl.IdlInstruction, argName string) *idl.IdlType {
+	for i := range inst.Args {
+		if inst.Args[i].Name == argName {
+			return &inst.Args[i].Type
+		}
+	}
+	return nil
+}
+
+// structFieldType returns the type of the named field of the struct type, or of the old spec account type.
+// It's nil if there is no such field.
+func structFieldType(program *idl.Idl, typeName, fieldName string) *idl.IdlType {
+	if typeName == "" {
+		return nil
+	}
+	var def *idl.IdlTypeDefTy
+	if typeDef := program.FindTypeByName(typeName); typeDef != nil {
+		def = &typeDef.Type
+	} else if i := slices.IndexFunc(program.Accounts, func(acc idl.IdlAccount) bool { return acc.Name == typeName }); i >= 0 {
+		def = &program.Accounts[i].Type
+	}
+	if def == nil || !def.IsStruct() || def.GetStruct().Fields == nil || !def.GetStruct().Fields.IsNamed() {
+		return nil
+	}
+	for i, field := range def.GetStruct().Fields.GetNamed().Fields {
+		if field.Name == fieldName {
+			return &def.GetStruct().Fields.GetNamed().Fields[i].Type
+		}
+	}
+	return nil
+}
+
+// structTypeName returns the name of the defined type, following the aliases. It's empty if the type isn't defined.
+func structTypeName(program *idl.Idl, typ *idl.IdlType) string {
+	if typ == nil || !typ.IsDefined() {
+		return ""
+	}
+	name := typ.GetDefined().Name
+	// A cycle of aliases ends up with an alias, which has no fields.
+	for range len(program.Types) {
+		def := program.FindTypeByName(name)
+		if def == nil || !def.Type.IsType() || !def.Type.GetType().Alias.IsDefined() {
+			break
+		}
+		name = def.Type.GetType().Alias.GetDefined().Name
+	}
+	return name
+}
+
+func registerTypeOverrides(ctx *model.GenerateCtx, cfg *config.Config) {
+	if cfg == nil {
+		return
+	}
+	for name, goType := range cfg.Overrides {
+		// Validated when loading the config.
+		override, _ := config.ParseGoType(goType)
+		ctx.SetTypeOverride(name, model.TypeOverride{ImportPath: override.ImportPath, Name: override.Name})
+	}
+}
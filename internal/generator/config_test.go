@@ -0,0 +1,225 @@
+package generator
+
+import (
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/config"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+const renameSeedsIdl = `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "swap",
+      "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+      "accounts": [
+        {"name": "config"},
+        {"name": "vault", "pda": {"seeds": [
+          {"kind": "account", "path": "config.fees.authority", "account": "Config"},
+          {"kind": "arg", "path": "args.route.hop"},
+          {"kind": "arg", "path": "args.extra.tag"},
+          {"kind": "arg", "path": "amount"}
+        ]}}
+      ],
+      "args": [
+        {"name": "amount", "type": "u64"},
+        {"name": "args", "type": {"defined": {"name": "SwapArgs"}}}
+      ]
+    }
+  ],
+  "accounts": [{"name": "Config", "discriminator": [1, 1, 1, 1, 1, 1, 1, 1]}],
+  "types": [
+    {"name": "Fees", "type": {"kind": "struct", "fields": [{"name": "authority", "type": "pubkey"}]}},
+    {"name": "Config", "type": {"kind": "struct", "fields": [{"name": "fees", "type": {"defined": {"name": "Fees"}}}]}},
+    {"name": "Route", "type": {"kind": "struct", "fields": [{"name": "hop", "type": "u8"}]}},
+    {"name": "Extra", "type": {"kind": "struct", "fields": [{"name": "tag", "type": "u64"}]}},
+    {"name": "ExtraAlias", "type": {"kind": "type", "alias": {"defined": {"name": "Extra"}}}},
+    {"name": "SwapArgs", "type": {"kind": "struct", "fields": [
+      {"name": "route", "type": {"defined": {"name": "Route"}}},
+      {"name": "extra", "type": {"defined": {"name": "ExtraAlias"}}}
+    ]}}
+  ]
+}`
+
+func TestRenameFieldsInSeedPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		renames  map[string]string
+		expected []string
+	}{
+		{
+			name:     "no rename",
+			expected: []string{"config.fees.authority", "args.route.hop", "args.extra.tag", "amount"},
+		},
+		{
+			name:     "nested account field",
+			renames:  map[string]string{"Fees.authority": "Admin"},
+			expected: []string{"config.fees.admin", "args.route.hop", "args.extra.tag", "amount"},
+		},
+		{
+			name:     "every depth",
+			renames:  map[string]string{"Config.fees": "FeeConfig", "Fees.authority": "Admin", "SwapArgs.route": "Path", "Route.hop": "Hops"},
+			expected: []string{"config.feeConfig.admin", "args.path.hops", "args.extra.tag", "amount"},
+		},
+		{
+			name:     "field of aliased type",
+			renames:  map[string]string{"Extra.tag": "Label"},
+			expected: []string{"config.fees.authority", "args.route.hop", "args.extra.label", "amount"},
+		},
+		{
+			name:     "instruction args",
+			renames:  map[string]string{"swap.args": "SwapParams", "swap.amount": "Lamports"},
+			expected: []string{"config.fees.authority", "swapParams.route.hop", "swapParams.extra.tag", "lamports"},
+		},
+		{
+			name:     "same field name of another type",
+			renames:  map[string]string{"Route.tag": "Label"},
+			expected: []string{"config.fees.authority", "args.route.hop", "args.extra.tag", "amount"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := idl.Parse([]byte(renameSeedsIdl))
+			if err != nil {
+				t.Fatal(err)
+			}
+			applier := newConfigApplier(&config.Config{Renames: config.Renames{Fields: tt.renames}})
+			applier.apply(program)
+
+			seeds := program.Instructions[0].Accounts[1].IdlInstructionAccount.Pda.Seeds
+			if len(seeds) != len(tt.expected) {
+				t.Fatalf("got %d seeds, expected %d", len(seeds), len(tt.expected))
+			}
+			for i, seed := range seeds {
+				var path string
+				if seed.IsArg() {
+					path = seed.GetArg().Path
+				} else {
+					path = seed.GetAccount().Path
+				}
+				if path != tt.expected[i] {
+					t.Errorf("seeds[%d]: got path %s, expected %s", i, path, tt.expected[i])
+				}
+			}
+			if diags := program.Validate(idl.ValidateOptions{}); diags.Err() != nil {
+				t.Errorf("renamed IDL is invalid: %v", diags.Err())
+			}
+		})
+	}
+}
+
+func TestRenamedFieldsKeepLowerCamelParams(t *testing.T) {
+	program, err := idl.Parse([]byte(renameSeedsIdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _, err := Generate(Options{
+		DstFolder:  t.TempDir(),
+		ImportBase: "example.com/out",
+		Config:     &config.Config{Renames: config.Renames{Fields: map[string]string{"swap.amount": "Lamports"}}},
+	}, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var swap string
+	for path, content := range files {
+		if filepath.Base(path) == "swap.go" {
+			swap = string(content)
+		}
+	}
+	for _, expected := range []string{
+		"\tLamports *uint64\n",
+		"func (inst *Swap) SetLamports(lamports uint64) *Swap {",
+		"\tlamports uint64,\n",
+	} {
+		if !strings.Contains(swap, expected) {
+			t.Errorf("swap.go doesn't contain %q", expected)
+		}
+	}
+}
+
+func TestFieldOptionsAfterRenames(t *testing.T) {
+	program, err := idl.Parse([]byte(renameSeedsIdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	skip, keep := true, false
+	applier := newConfigApplier(&config.Config{
+		Renames: config.Renames{
+			Instructions: map[string]string{"swap": "exchange"},
+			Types:        map[string]string{"Config": "Settings"},
+			Fields:       map[string]string{"Fees.authority": "Admin", "Config.fees": "FeeConfig"},
+		},
+		SkipOptionalFlag: map[string]bool{"Config.fees": true, "swap.amount": false},
+	})
+	options := applier.apply(program)
+
+	expected := map[string]model.FieldOption{
+		"Fees.admin":         {IdlName: "authority"},
+		"Settings.feeConfig": {IdlName: "fees", SkipOptionalFlag: &skip},
+		"exchange.amount":    {SkipOptionalFlag: &keep},
+	}
+	if !reflect.DeepEqual(options, expected) {
+		t.Errorf("got options %v, expected %v", options, expected)
+	}
+	if diags := applier.diags; len(diags) > 0 {
+		t.Errorf("unexpected diagnostics %v", diags)
+	}
+}
+
+func TestSkipOptionalFlagOfRenamedFields(t *testing.T) {
+	program, err := idl.Parse([]byte(`{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "deposit",
+      "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+      "accounts": [],
+      "args": [{"name": "memo", "type": {"option": "u64"}}]
+    }
+  ],
+  "types": [
+    {"name": "Vault", "type": {"kind": "struct", "fields": [
+      {"name": "delegate", "type": {"option": "pubkey"}},
+      {"name": "note", "type": {"option": "u8"}}
+    ]}}
+  ]
+}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, _, err := Generate(Options{
+		DstFolder:  t.TempDir(),
+		ImportBase: "example.com/out",
+		Config: &config.Config{
+			Renames: config.Renames{
+				Instructions: map[string]string{"deposit": "fund"},
+				Types:        map[string]string{"Vault": "VaultState"},
+				Fields:       map[string]string{"Vault.delegate": "Owner"},
+			},
+			SkipOptionalFlag: map[string]bool{"Vault.delegate": true, "deposit.memo": true},
+		},
+	}, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := map[string]string{}
+	for path, content := range files {
+		contents[filepath.Base(path)] = string(content)
+	}
+	// Only the flag of `note` is written (either false or true) and read.
+	for name, expected := range map[string]int{"types.go": 3, "fund.go": 0} {
+		if got := strings.Count(contents[name], "Bool("); got != expected {
+			t.Errorf("%s writes or reads %d optional flags, expected %d", name, got, expected)
+		}
+	}
+}
@@ -1,11 +1,16 @@
 package generator
 
 import (
+	"bytes"
+	stderrors "errors"
 	"fmt"
-	"os"
 	"path"
 	"path/filepath"
+	"reflect"
+	"strings"
 
+	"github.com/alivers/anchor-go/internal/config"
+	"github.com/alivers/anchor-go/internal/diagnostic"
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/generator/program/accounts"
@@ -18,119 +23,207 @@ import (
 	"github.com/alivers/anchor-go/internal/generator/program/tests"
 	"github.com/alivers/anchor-go/internal/generator/program/types"
 	"github.com/alivers/anchor-go/internal/idl"
-	"github.com/fatih/color"
-	ag_utilz "github.com/gagliardetto/utilz"
 
 	"github.com/dave/jennifer/jen"
 )
 
-func Generate(dstFolder string, generateTests, skipOptionalFlag bool, program *idl.Idl) {
-	pkgName := helper.ToRustSnakeCase(program.Metadata.Name)
-	dstFolder = path.Join(dstFolder, pkgName)
-
-	ctx := model.NewGenerateCtx(
-		pkgName,
-		program.Metadata.Name,
-		deriveDiscriminatorType(program),
-		model.EncoderTypeBorsh,
-		skipOptionalFlag,
-	)
-
-	registerIdentifiers(ctx, program)
-	registerComplexEnum(ctx, program)
+// Options of the generation.
+type Options struct {
+	// Destination folder, the files of each program are put in `<DstFolder>/<package name>`.
+	DstFolder string
+	// Import path of `DstFolder`, it's detected from the nearest `go.mod` if empty.
+	ImportBase string
+	// Package name of the generated code, defaults to the snake case name of the program.
+	// It can only be set when generating a single program.
+	PkgName          string
+	GenerateTests    bool
+	SkipOptionalFlag bool
+	Encoder          model.EncoderType
+	// Config shared by all the programs, it's optional.
+	Config *config.Config
+}
 
-	files := make([]*jen.File, 0, 8+2*len(program.Instructions))
-	fileName := make([]string, 0, 8+2*len(program.Instructions))
+type generatedFile struct {
+	name string
+	file *jen.File
+}
 
-	{
-		file := instructions.GenerateInstructions(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "instructions.go")
+// Generate generates one package for each program, and returns the content of the files by their path.
+// Each package folder also gets a manifest (see `ManifestFileName`) listing the files it owns.
+// If a program declares another program of the same run in `metadata.dependencies`,
+// the types of the dependency are imported from its package instead of being declared again.
+//
+// All the problems of the programs are collected in one pass and returned as diagnostics,
+// the returned error is not nil if there is any diagnostic of `error` severity.
+func Generate(opts Options, programs ...*idl.Idl) (map[string][]byte, diagnostic.Diagnostics, error) {
+	switch opts.Encoder {
+	case "":
+		opts.Encoder = model.EncoderTypeBorsh
+	case model.EncoderTypeBorsh, model.EncoderTypeBin, model.EncoderTypeCompactU16:
+	default:
+		return nil, nil, fmt.Errorf("unknown encoder: %s", opts.Encoder)
+	}
+	if opts.PkgName != "" && len(programs) > 1 {
+		return nil, nil, stderrors.New("package name can only be set when generating a single program")
+	}
+	if opts.ImportBase == "" {
+		opts.ImportBase = detectImportBase(opts.DstFolder)
 	}
 
-	if generateTests {
-		file := tests.GenerateTestUtils(ctx)
-		files = append(files, file)
-		fileName = append(fileName, "test_utils.go")
+	var diags diagnostic.Diagnostics
+	fieldOptions := map[*idl.Idl]map[string]model.FieldOption{}
+	if opts.Config != nil {
+		applier := newConfigApplier(opts.Config)
+		configured := make([]*idl.Idl, len(programs))
+		for i, program := range programs {
+			configured[i] = program.Clone()
+			fieldOptions[configured[i]] = applier.apply(configured[i])
+		}
+		applier.warnUnused()
+		diags = append(diags, applier.diags...)
+		programs = configured
 	}
 
-	for _, inst := range program.Instructions {
-		instName, _, file := instruction.GenerateInstruction(ctx, program, &inst)
+	files := map[string][]byte{}
+	for _, program := range programs {
+		pkgName := packageName(opts, program)
+		generated, programDiags := generateProgram(opts, pkgName, program, programs, fieldOptions[program])
+		diags = append(diags, programDiags...)
+		if programDiags.HasErrors() {
+			continue
+		}
 
-		files = append(files, file)
-		fileName = append(fileName, helper.ToRustSnakeCase(instName)+".go")
+		names := make([]string, 0, len(generated))
+		for _, item := range generated {
+			var buf bytes.Buffer
+			if err := item.file.Render(&buf); err != nil {
+				diags.Errorf(program.Metadata.Name, "", "failed to render %s: %s", item.name, err)
+				continue
+			}
+			files[filepath.Join(opts.DstFolder, pkgName, item.name)] = buf.Bytes()
+			names = append(names, item.name)
+		}
 
-		if generateTests {
-			testFile := tests.GenerateTests(ctx, program, &inst)
-			files = append(files, testFile)
-			fileName = append(fileName, helper.ToRustSnakeCase(instName)+"_test.go")
+		manifest, err := generateManifest(opts, pkgName, program, names)
+		if err != nil {
+			diags.Errorf(program.Metadata.Name, "", "failed to generate %s: %s", ManifestFileName, err)
+			continue
 		}
+		files[filepath.Join(opts.DstFolder, pkgName, ManifestFileName)] = manifest
 	}
 
-	{
-		file := accounts.GenerateAccounts(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "accounts.go")
+	if err := diags.Err(); err != nil {
+		return nil, diags, err
 	}
+	return files, diags, nil
+}
 
-	{
-		file := addresses.GenerateAddresses(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "addresses.go")
+func packageName(opts Options, program *idl.Idl) string {
+	if opts.PkgName != "" {
+		return opts.PkgName
 	}
+	return helper.ToRustSnakeCase(program.Metadata.Name)
+}
 
-	{
-		file := events.GenerateEvents(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "events.go")
-	}
+func generateProgram(opts Options, pkgName string, source *idl.Idl, programs []*idl.Idl, fieldOptions map[string]model.FieldOption) ([]generatedFile, diagnostic.Diagnostics) {
+	// The code generators only deal with the concrete types.
+	program, instances, diags := monomorphize(source)
+
+	ctx := model.NewGenerateCtx(
+		pkgName,
+		program.Metadata.Name,
+		model.DiscriminatorTypeDefault,
+		opts.Encoder,
+		opts.SkipOptionalFlag,
+	)
+	ctx.DiscriminatorType = deriveDiscriminatorType(ctx, program)
+	ctx.Diagnostics = append(ctx.Diagnostics, diags...)
 
-	{
-		file := types.GenerateTypes(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "types.go")
+	registerIdentifiers(ctx, program)
+	registerComplexEnum(ctx, program)
+	registerExternalTypes(ctx, opts, program, programs)
+	registerTypeOverrides(ctx, opts.Config)
+	registerArrayLengths(ctx, program)
+	registerCustomSerialization(ctx, program)
+	registerFieldOptions(ctx, fieldOptions, instances)
+
+	// The source is validated, so the problems are reported at their locations in the IDL.
+	ctx.Diagnostics = append(ctx.Diagnostics, source.Validate(idl.ValidateOptions{
+		IsKnownType: func(name string) bool {
+			_, overridden := ctx.GetTypeOverride(name)
+			return overridden || ctx.IsExternalType(name)
+		},
+	})...)
+	if ctx.Diagnostics.HasErrors() {
+		// The code generators rely on a valid IDL.
+		return nil, ctx.Diagnostics
 	}
+	checkSupportedTypes(ctx, program)
+	registerLayouts(ctx, program)
 
-	{
-		file := constants.GenerateConstants(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "constants.go")
+	files := make([]generatedFile, 0, 8+2*len(program.Instructions))
+	addFile := func(name string, file *jen.File) {
+		files = append(files, generatedFile{name: name, file: file})
 	}
 
-	{
-		file := errors.GenerateErrors(ctx, program)
-		files = append(files, file)
-		fileName = append(fileName, "errors.go")
+	addFile("instructions.go", instructions.GenerateInstructions(ctx, program))
+
+	if opts.GenerateTests {
+		addFile("test_utils.go", tests.GenerateTestUtils(ctx))
 	}
 
-	ag_utilz.MustCreateFolderIfNotExists(dstFolder, os.ModePerm)
-	for i, file := range files {
-		dst, err := filepath.Abs(path.Join(dstFolder, fileName[i]))
-		if err != nil {
-			fmt.Printf("Failed to get absolute path for %s: %v\n", fileName[i], err)
-		}
-		err = file.Save(dst)
-		if err != nil {
-			fmt.Printf("%v", err)
-		} else {
-			fmt.Printf(
-				"[%s] %s\n",
-				color.GreenString("✓"),
-				dst,
-			)
+	for i := range program.Instructions {
+		inst := &program.Instructions[i]
+		instName, _, file := instruction.GenerateInstruction(ctx, program, fmt.Sprintf("instructions[%d]", i), inst)
+		addFile(helper.ToRustSnakeCase(instName)+".go", file)
+
+		if opts.GenerateTests {
+			testFile := tests.GenerateTests(ctx, program, inst)
+			instruction.GeneratePdaSeedsTest(ctx, testFile, inst, program)
+			addFile(helper.ToRustSnakeCase(instName)+"_test.go", testFile)
 		}
 	}
+
+	addFile("accounts.go", accounts.GenerateAccounts(ctx, program))
+	if accounts.UsesRelations(program) {
+		addFile("fetcher.go", accounts.GenerateAccountFetcher(ctx))
+	}
+	addFile("addresses.go", addresses.GenerateAddresses(ctx, program))
+	addFile("events.go", events.GenerateEvents(ctx, program))
+	addFile("types.go", types.GenerateTypes(ctx, program))
+	if types.UsesInt256(ctx, program) {
+		addFile("int256.go", types.GenerateInt256(ctx))
+	}
+	if types.UsesCollections(program) {
+		addFile("collections.go", types.GenerateCollections(ctx))
+	}
+	addFile("constants.go", constants.GenerateConstants(ctx, program))
+	addFile("errors.go", errors.GenerateErrors(ctx, program))
+
+	return files, ctx.Diagnostics
 }
 
-func deriveDiscriminatorType(program *idl.Idl) model.DiscriminatorType {
+func deriveDiscriminatorType(ctx *model.GenerateCtx, program *idl.Idl) model.DiscriminatorType {
 	if len(program.Instructions) == 0 {
 		return model.DiscriminatorTypeDefault
 	}
 
-	instruction := program.Instructions[0]
-	if instruction.Discriminator != nil {
+	// The Anchor discriminators are 8 bytes by default, but they can be customized per instruction with any length.
+	anchor := false
+	for _, instruction := range program.Instructions {
+		if instruction.Discriminator != nil {
+			if len(instruction.Discriminator) != 8 {
+				return model.DiscriminatorTypeCustom
+			}
+			anchor = true
+		}
+	}
+	if anchor {
 		return model.DiscriminatorTypeAnchor
-	} else if instruction.Discriminant != nil {
+	}
+
+	instruction := program.Instructions[0]
+	if instruction.Discriminant != nil {
 		if instruction.Discriminant.Type == model.DiscriminatorTypeUint8.String() {
 			return model.DiscriminatorTypeUint8
 		} else if instruction.Discriminant.Type == model.DiscriminatorTypeUint32.String() {
@@ -138,7 +231,8 @@ func deriveDiscriminatorType(program *idl.Idl) model.DiscriminatorType {
 		} else if instruction.Discriminant.Type == model.DiscriminatorTypeUvarint32.String() {
 			return model.DiscriminatorTypeUvarint32
 		} else {
-			panic(fmt.Sprintf("Unsupported discriminant type(%s) in instruction(%s)", instruction.Discriminant.Type, instruction.Name))
+			ctx.Errorf("instructions[0].discriminant.type", "unsupported discriminant type(%s) in instruction(%s)", instruction.Discriminant.Type, instruction.Name)
+			return model.DiscriminatorTypeDefault
 		}
 	} else {
 		return model.DiscriminatorTypeDefault
@@ -175,3 +269,166 @@ func registerComplexEnum(ctx *model.GenerateCtx, program *idl.Idl) {
 		}
 	}
 }
+
+// registerArrayLengths registers the integer constants, which can be used as the lengths of the arrays.
+// The invalid values are reported when generating the constants.
+func registerArrayLengths(ctx *model.GenerateCtx, program *idl.Idl) {
+	for _, c := range program.Constants {
+		if !c.Type.IsSimple() {
+			continue
+		}
+		switch c.Type.GetSimple() {
+		case idl.IdlTypeSimpleU8, idl.IdlTypeSimpleU16, idl.IdlTypeSimpleU32, idl.IdlTypeSimpleU64,
+			idl.IdlTypeSimpleI8, idl.IdlTypeSimpleI16, idl.IdlTypeSimpleI32, idl.IdlTypeSimpleI64:
+			if value, err := helper.ParseRustUint(c.Value); err == nil {
+				ctx.SetArrayLen(c.Name, uint(value))
+			}
+		}
+	}
+}
+
+// registerCustomSerialization registers the types with custom serialization,
+// their encoding is unknown so the generated codecs call the ones registered by hand.
+func registerCustomSerialization(ctx *model.GenerateCtx, program *idl.Idl) {
+	for i, typ := range program.Types {
+		if typ.Serialization != idl.IdlSerializationCustom {
+			continue
+		}
+		if !typ.Type.IsStruct() {
+			ctx.Errorf(fmt.Sprintf("types[%d].serialization", i), "custom serialization is only supported for structs, %s is not a struct", typ.Name)
+			continue
+		}
+		ctx.SetCustomSerialization(typ.Name)
+	}
+}
+
+// registerFieldOptions registers the options of the fields set by the generator config,
+// the options of the fields of a generic type apply to all its instantiations.
+func registerFieldOptions(ctx *model.GenerateCtx, fieldOptions map[string]model.FieldOption, instances map[string]string) {
+	for key, option := range fieldOptions {
+		owner, field, _ := strings.Cut(key, ".")
+		ctx.SetFieldOption(owner, field, option)
+		for instance, generic := range instances {
+			if generic == owner {
+				ctx.SetFieldOption(instance, field, option)
+			}
+		}
+	}
+}
+
+// registerExternalTypes registers the types of the dependencies (generated in the same run) as shared types.
+// A type declared by the program self is still generated locally if it differs from the one of the dependency.
+func registerExternalTypes(ctx *model.GenerateCtx, opts Options, program *idl.Idl, programs []*idl.Idl) {
+	for i, dependency := range program.Metadata.Dependencies {
+		depProgram := findProgramByName(dependency.Name, programs)
+		if depProgram == nil || depProgram == program {
+			continue
+		}
+		if opts.ImportBase == "" {
+			ctx.Errorf(fmt.Sprintf("metadata.dependencies[%d]", i), "unable to import the package of dependency(%s): the import path of the destination folder is unknown", dependency.Name)
+			continue
+		}
+		if chain := dependencyChain(depProgram, program, programs); chain != nil {
+			cycle := strings.Join(append([]string{program.Metadata.Name}, chain...), " -> ")
+			ctx.Errorf(fmt.Sprintf("metadata.dependencies[%d]", i), "import cycle between the program and its dependency(%s): %s", dependency.Name, cycle)
+		}
+	}
+	if opts.ImportBase == "" {
+		return
+	}
+
+	for _, shared := range sharedTypes(program, programs) {
+		ctx.SetExternalType(shared.def.Name, path.Join(opts.ImportBase, packageName(opts, shared.program)))
+		ctx.SetIdentifier(shared.def.Name, &shared.def.Type)
+		if shared.def.Type.IsEnum() && !shared.def.Type.GetEnum().IsUint8Enum() {
+			ctx.SetComplexEnum(shared.def.Name)
+		}
+	}
+}
+
+// sharedType is a type imported from the package of another program.
+type sharedType struct {
+	def idl.IdlTypeDef
+	// The program whose package declares the type.
+	program *idl.Idl
+}
+
+// sharedTypes returns the types which the program imports from its dependencies, in the order of the dependencies.
+// A dependency may import the type from its own dependencies, so the type is imported from the program
+// which actually declares it, e.g. from c if a depends on b which depends on c, and all of them have the type.
+// The dependencies in an import cycle are skipped, the cycle is reported by `registerExternalTypes`.
+func sharedTypes(program *idl.Idl, programs []*idl.Idl) []sharedType {
+	var shared []sharedType
+	seen := map[string]bool{}
+	for _, dependency := range program.Metadata.Dependencies {
+		depProgram := findProgramByName(dependency.Name, programs)
+		if depProgram == nil || depProgram == program || dependencyChain(depProgram, program, programs) != nil {
+			continue
+		}
+
+		imported := map[string]*idl.Idl{}
+		for _, depShared := range sharedTypes(depProgram, programs) {
+			imported[depShared.def.Name] = depShared.program
+		}
+		for _, typ := range depProgram.Types {
+			// The generic types are instantiated by each program.
+			if len(typ.Generics) > 0 || seen[typ.Name] {
+				continue
+			}
+			if own := program.FindTypeByName(typ.Name); own != nil && !isSameTypeDef(own, &typ) {
+				continue
+			}
+			declaring := depProgram
+			if imported[typ.Name] != nil {
+				declaring = imported[typ.Name]
+			}
+			seen[typ.Name] = true
+			shared = append(shared, sharedType{def: typ, program: declaring})
+		}
+	}
+	return shared
+}
+
+func findProgramByName(name string, programs []*idl.Idl) *idl.Idl {
+	for _, program := range programs {
+		if helper.ToRustSnakeCase(program.Metadata.Name) == helper.ToRustSnakeCase(name) {
+			return program
+		}
+	}
+	return nil
+}
+
+// dependencyChain returns the names of the programs from `from` to `to` following the dependencies
+// generated in the same run, e.g. `[b c a]` if b depends on c which depends on a.
+// It's nil if `from` doesn't depend on `to`, directly or not.
+func dependencyChain(from, to *idl.Idl, programs []*idl.Idl) []string {
+	visited := map[*idl.Idl]bool{}
+	var visit func(program *idl.Idl) []string
+	visit = func(program *idl.Idl) []string {
+		if program == to {
+			return []string{program.Metadata.Name}
+		}
+		if visited[program] {
+			return nil
+		}
+		visited[program] = true
+		for _, dependency := range program.Metadata.Dependencies {
+			depProgram := findProgramByName(dependency.Name, programs)
+			if depProgram == nil || depProgram == program {
+				continue
+			}
+			if chain := visit(depProgram); chain != nil {
+				return append([]string{program.Metadata.Name}, chain...)
+			}
+		}
+		return nil
+	}
+	return visit(from)
+}
+
+// isSameTypeDef compares two type definitions, ignoring the docs.
+func isSameTypeDef(a, b *idl.IdlTypeDef) bool {
+	x, y := *a, *b
+	x.Docs, y.Docs = nil, nil
+	return reflect.DeepEqual(x, y)
+}
@@ -0,0 +1,187 @@
+package generator
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// dependentIdl returns the IDL of a program which declares the `Shared` type, uses it in an instruction
+// and depends on the programs.
+func dependentIdl(t *testing.T, name string, dependencies ...string) *idl.Idl {
+	t.Helper()
+	deps := make([]string, len(dependencies))
+	for i, dependency := range dependencies {
+		deps[i] = fmt.Sprintf(`{"name": %q, "version": "0.1.0"}`, dependency)
+	}
+	program, err := idl.Parse(fmt.Appendf(nil, `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": %q, "version": "0.1.0", "spec": "0.1.0", "dependencies": [%s]},
+  "instructions": [{
+    "name": "useShared",
+    "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+    "accounts": [],
+    "args": [{"name": "shared", "type": {"defined": {"name": "Shared"}}}]
+  }],
+  "types": [{"name": "Shared", "type": {"kind": "struct", "fields": [{"name": "value", "type": "u64"}]}}]
+}`, name, strings.Join(deps, ", ")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestDependencies(t *testing.T) {
+	tests := []struct {
+		name         string
+		dependencies map[string][]string
+		// The expected errors as `<program>: <message>`.
+		expected []string
+		// The programs whose package declares the Shared type, the others import it.
+		declaredBy []string
+	}{
+		{
+			name:         "chain",
+			dependencies: map[string][]string{"a": {"b"}, "b": {"c"}, "c": nil},
+			declaredBy:   []string{"c"},
+		},
+		{
+			name:         "dependency of two programs",
+			dependencies: map[string][]string{"a": {"b", "c"}, "b": {"c"}, "c": nil},
+			declaredBy:   []string{"c"},
+		},
+		{
+			name:         "dependency outside the run",
+			dependencies: map[string][]string{"a": {"b", "other"}, "b": {"other"}},
+			declaredBy:   []string{"b"},
+		},
+		{
+			name:         "self dependency",
+			dependencies: map[string][]string{"a": {"a"}},
+			declaredBy:   []string{"a"},
+		},
+		{
+			name:         "direct cycle",
+			dependencies: map[string][]string{"a": {"b"}, "b": {"a"}},
+			expected: []string{
+				"a: import cycle between the program and its dependency(b): a -> b -> a",
+				"b: import cycle between the program and its dependency(a): b -> a -> b",
+			},
+		},
+		{
+			name:         "indirect cycle",
+			dependencies: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"a"}},
+			expected: []string{
+				"a: import cycle between the program and its dependency(b): a -> b -> c -> a",
+				"b: import cycle between the program and its dependency(c): b -> c -> a -> b",
+				"c: import cycle between the program and its dependency(a): c -> a -> b -> c",
+			},
+		},
+		{
+			name:         "cycle behind a dependency",
+			dependencies: map[string][]string{"a": {"b"}, "b": {"c"}, "c": {"d"}, "d": {"b"}},
+			expected: []string{
+				"b: import cycle between the program and its dependency(c): b -> c -> d -> b",
+				"c: import cycle between the program and its dependency(d): c -> d -> b -> c",
+				"d: import cycle between the program and its dependency(b): d -> b -> c -> d",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var programs []*idl.Idl
+			for _, name := range []string{"a", "b", "c", "d"} {
+				if dependencies, ok := tt.dependencies[name]; ok {
+					programs = append(programs, dependentIdl(t, name, dependencies...))
+				}
+			}
+			if len(tt.expected) > 0 {
+				_, diags, err := Generate(Options{DstFolder: t.TempDir(), ImportBase: "example.com/out"}, programs...)
+				errs := diags.Errors()
+				if len(errs) != len(tt.expected) {
+					t.Fatalf("got errors %v, expected %v", errs, tt.expected)
+				}
+				for i, diag := range errs {
+					if got := diag.Program + ": " + diag.Message; got != tt.expected[i] {
+						t.Errorf("errors[%d]: got %s, expected %s", i, got, tt.expected[i])
+					}
+				}
+				if err == nil {
+					t.Error("expected an error")
+				}
+				return
+			}
+
+			goBin := lookupGo(t)
+			// The packages import each other with the import path detected from the module.
+			dst := testOutputFolder(t)
+			files, _, err := Generate(Options{DstFolder: dst}, programs...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var declaredBy []string
+			for _, program := range programs {
+				name := program.Metadata.Name
+				if strings.Contains(string(files[filepath.Join(dst, name, "types.go")]), "type Shared struct") {
+					declaredBy = append(declaredBy, name)
+				}
+			}
+			if strings.Join(declaredBy, " ") != strings.Join(tt.declaredBy, " ") {
+				t.Errorf("got Shared declared by %v, expected %v", declaredBy, tt.declaredBy)
+			}
+
+			writeGeneratedFiles(t, files)
+			if out, err := exec.Command(goBin, "build", "./"+filepath.ToSlash(dst)+"/...").CombinedOutput(); err != nil {
+				t.Fatalf("the generated packages don't build: %v\n%s", err, out)
+			}
+		})
+	}
+}
+
+// lookupGo returns the path of the go command, the test is skipped if it isn't available.
+func lookupGo(t *testing.T) string {
+	t.Helper()
+	if testing.Short() {
+		t.Skip("builds the generated code")
+	}
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go is not installed")
+	}
+	return goBin
+}
+
+// testOutputFolder returns a new folder in testdata, so that the generated code is built with the module.
+func testOutputFolder(t *testing.T) string {
+	t.Helper()
+	if err := os.MkdirAll("testdata", 0o755); err != nil {
+		t.Fatal(err)
+	}
+	dst, err := os.MkdirTemp("testdata", "out_")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		os.RemoveAll(dst)
+		// Only removed if there is nothing else.
+		os.Remove("testdata")
+	})
+	return dst
+}
+
+func writeGeneratedFiles(t *testing.T, files map[string][]byte) {
+	t.Helper()
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(name, content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
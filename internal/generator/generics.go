@@ -0,0 +1,375 @@
+package generator
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/alivers/anchor-go/internal/diagnostic"
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// maxInstantiationDepth limits the nested instantiations, e.g. `Foo<T>` containing `Foo<Vec<T>>` never ends.
+const maxInstantiationDepth = 32
+
+// monomorphizer replaces the generic type definitions with one named type for each concrete instantiation
+// found in the program, e.g. `Foo<u64, 32>` becomes the type `FooU64N32`, so each one gets its own codec.
+type monomorphizer struct {
+	program *idl.Idl
+	// Generic type definitions by name, along with their location in the IDL.
+	generics     map[string]*idl.IdlTypeDef
+	genericPaths map[string]string
+	// Names of the instantiated types by their key, see `instantiate`.
+	instances map[string]string
+	// Keys of the instantiated types by their names, to detect the name collisions.
+	instanceKeys map[string]string
+	// Names of the generic type definitions which are used, even if their instantiation fails.
+	used map[string]bool
+	// Names of the generic type definitions by the names of their instantiations.
+	origins map[string]string
+	// The substituted nodes, which are skipped when their parents are visited since `substitute` is recursive.
+	substituted map[*idl.IdlType]bool
+	added       []idl.IdlTypeDef
+	depth       int
+	diags       diagnostic.Diagnostics
+}
+
+// monomorphize returns the program with the generic type definitions replaced by their instantiations,
+// along with the names of the generic type definitions by the names of their instantiations.
+// The program is returned as is if it doesn't use generics, otherwise a copy is returned.
+func monomorphize(program *idl.Idl) (*idl.Idl, map[string]string, diagnostic.Diagnostics) {
+	if !usesGenerics(program) {
+		return program, nil, nil
+	}
+
+	m := &monomorphizer{
+		program:      program.Clone(),
+		generics:     map[string]*idl.IdlTypeDef{},
+		genericPaths: map[string]string{},
+		instances:    map[string]string{},
+		instanceKeys: map[string]string{},
+		used:         map[string]bool{},
+		origins:      map[string]string{},
+		substituted:  map[*idl.IdlType]bool{},
+	}
+	for i := range m.program.Types {
+		if def := &m.program.Types[i]; len(def.Generics) > 0 {
+			m.generics[def.Name] = def
+			m.genericPaths[def.Name] = fmt.Sprintf("types[%d]", i)
+		}
+	}
+
+	concrete := func(path string, typ *idl.IdlType) {
+		m.substituteOnce(path, typ, nil)
+	}
+	for i := range m.program.Instructions {
+		inst := &m.program.Instructions[i]
+		for j := range inst.Args {
+			concrete(fmt.Sprintf("instructions[%d].args[%d].type", i, j), &inst.Args[j].Type)
+		}
+		if !inst.Returns.IsEmpty() {
+			concrete(fmt.Sprintf("instructions[%d].returns", i), &inst.Returns)
+		}
+	}
+	for i := range m.program.Accounts {
+		m.program.Accounts[i].Type.VisitTypes(fmt.Sprintf("accounts[%d].type", i), concrete)
+	}
+	for i := range m.program.Types {
+		if len(m.program.Types[i].Generics) == 0 {
+			m.program.Types[i].Type.VisitTypes(fmt.Sprintf("types[%d].type", i), concrete)
+		}
+	}
+	for i := range m.program.Constants {
+		concrete(fmt.Sprintf("constants[%d].type", i), &m.program.Constants[i].Type)
+	}
+
+	types := make([]idl.IdlTypeDef, 0, len(m.program.Types)+len(m.added))
+	for _, def := range m.program.Types {
+		if len(def.Generics) == 0 {
+			types = append(types, def)
+		} else if !m.used[def.Name] {
+			m.diags.Warnf(program.Metadata.Name, m.genericPaths[def.Name], "generic type %s is not generated since it's never instantiated", def.Name)
+		}
+	}
+	m.program.Types = append(types, m.added...)
+	return m.program, m.origins, m.diags
+}
+
+func usesGenerics(program *idl.Idl) bool {
+	for _, def := range program.Types {
+		if len(def.Generics) > 0 {
+			return true
+		}
+	}
+	found := false
+	program.VisitTypes(func(_ string, typ *idl.IdlType) {
+		found = found || typ.IsGeneric() || (typ.IsDefined() && len(typ.GetDefined().Generics) > 0)
+	})
+	return found
+}
+
+func (m *monomorphizer) errorf(path string, format string, args ...any) {
+	m.diags.Errorf(m.program.Metadata.Name, path, format, args...)
+}
+
+// substituteOnce substitutes the type unless it's already substituted, e.g. as a nested type of a visited type.
+func (m *monomorphizer) substituteOnce(path string, typ *idl.IdlType, args map[string]idl.IdlGenericArg) {
+	if !m.substituted[typ] {
+		m.substitute(path, typ, args)
+	}
+}
+
+// markSubstituted marks the type and its nested types as substituted.
+func (m *monomorphizer) markSubstituted(typ *idl.IdlType) {
+	typ.VisitTypes("", func(_ string, nested *idl.IdlType) {
+		m.substituted[nested] = true
+	})
+}
+
+// substitute replaces the generic params in the type with the arguments of the instantiation,
+// and the instantiations of the generic types with the instantiated types.
+// The arguments are nil outside of a generic type definition.
+func (m *monomorphizer) substitute(path string, typ *idl.IdlType, args map[string]idl.IdlGenericArg) {
+	m.substituted[typ] = true
+	switch {
+	case typ.IsGeneric():
+		name := typ.GetGeneric().Name
+		arg, ok := args[name]
+		if !ok || !arg.IsType() {
+			m.errorf(path, "undeclared generic type %s", name)
+			return
+		}
+		*typ = *arg.GetType().Type.Clone()
+		// The argument is already concrete.
+		m.markSubstituted(typ)
+	case typ.IsOption():
+		m.substitute(path+".option", &typ.GetOption().Option, args)
+	case typ.IsVec():
+		m.substitute(path+".vec", &typ.GetVec().Vec, args)
+	case typ.IsArray():
+		m.substitute(path+".array[0]", &typ.GetArray().Elem, args)
+		if arrayLen := &typ.GetArray().Len; arrayLen.IsGeneric() {
+			// The lengths which are not const generic params are left to the generator.
+			if arg, ok := args[arrayLen.GetGeneric().Value]; ok {
+				if !arg.IsConst() {
+					m.errorf(path+".array[1]", "generic %s is not a const", arrayLen.GetGeneric().Value)
+					return
+				}
+				value := arg.GetConst().Value
+				if n, err := helper.ParseRustUint(value); err == nil {
+					*arrayLen = idl.IdlArrayLen{IdlArrayLenValue: &idl.IdlArrayLenValue{Value: uint(n)}}
+				} else if isIdentifier(value) {
+					// A constant of the program, e.g. `Foo<MAX_LEN>`.
+					*arrayLen = idl.IdlArrayLen{IdlArrayLenGeneric: &idl.IdlArrayLenGeneric{Value: value}}
+				} else {
+					m.errorf(path+".array[1]", "invalid array length %s of generic %s", value, arrayLen.GetGeneric().Value)
+				}
+			}
+		}
+	case typ.IsHashMap():
+		kind := typ.GetHashMap().Kind()
+		m.substitute(path+"."+kind+"[0]", &typ.GetHashMap().Key, args)
+		m.substitute(path+"."+kind+"[1]", &typ.GetHashMap().Val, args)
+	case typ.IsHashSet():
+		m.substitute(path+"."+typ.GetHashSet().Kind(), &typ.GetHashSet().Elem, args)
+	case typ.IsDefined():
+		defined := typ.GetDefined()
+		def, ok := m.generics[defined.Name]
+		if !ok {
+			if len(defined.Generics) > 0 && m.program.FindTypeByName(defined.Name) != nil {
+				m.errorf(path, "type %s is not generic", defined.Name)
+			}
+			// Otherwise the type is provided elsewhere (e.g. overridden), or it's reported as undefined.
+			return
+		}
+		m.used[def.Name] = true
+		if len(defined.Generics) != len(def.Generics) {
+			m.errorf(path, "type %s expects %d generic arguments, got %d", defined.Name, len(def.Generics), len(defined.Generics))
+			return
+		}
+
+		// The arguments are substituted on copies, the instantiation replaces them.
+		for i := range defined.Generics {
+			if arg := &defined.Generics[i]; arg.IsType() {
+				m.markSubstituted(&arg.GetType().Type)
+			}
+		}
+		concreteArgs := make([]idl.IdlGenericArg, len(defined.Generics))
+		for i, arg := range defined.Generics {
+			argPath := fmt.Sprintf("%s.defined.generics[%d]", path, i)
+			param := def.Generics[i]
+			switch {
+			case param.IsType() && arg.IsType():
+				argType := *arg.GetType().Type.Clone()
+				diagsCount := len(m.diags)
+				m.substitute(argPath+".type", &argType, args)
+				if len(m.diags) > diagsCount {
+					// The argument is already reported, the instantiation would only fail again.
+					return
+				}
+				concreteArgs[i] = idl.IdlGenericArg{IdlGenericArgType: &idl.IdlGenericArgType{Kind: "type", Type: argType}}
+			case param.IsConst() && arg.IsConst():
+				value := arg.GetConst().Value
+				// The value is a const generic param of the enclosing type definition.
+				if outer, ok := args[value]; ok && outer.IsConst() {
+					value = outer.GetConst().Value
+				}
+				concreteArgs[i] = idl.IdlGenericArg{IdlGenericArgConst: &idl.IdlGenericArgConst{Kind: "const", Value: value}}
+			default:
+				m.errorf(argPath, "generic argument kind doesn't match the param %s of type %s", genericParamName(param), defined.Name)
+				return
+			}
+		}
+
+		if name, ok := m.instantiate(path, def, concreteArgs); ok {
+			*typ = idl.IdlType{IdlTypeDefined: &idl.IdlTypeDefined{Name: name}}
+		}
+	}
+}
+
+// instantiate returns the name of the type instantiated from the generic type definition with the concrete arguments.
+func (m *monomorphizer) instantiate(path string, def *idl.IdlTypeDef, args []idl.IdlGenericArg) (string, bool) {
+	argsJSON, err := json.Marshal(args)
+	if err != nil {
+		m.errorf(path, "invalid generic arguments of type %s: %s", def.Name, err)
+		return "", false
+	}
+	key := def.Name + "<" + string(argsJSON) + ">"
+	if name, ok := m.instances[key]; ok {
+		return name, true
+	}
+
+	name, err := instanceName(def.Name, args)
+	if err != nil {
+		m.errorf(path, "unable to name the instantiation of type %s: %s", def.Name, err)
+		return "", false
+	}
+	if other, ok := m.instanceKeys[name]; ok || m.program.FindTypeByName(name) != nil {
+		if !ok {
+			other = "the type declared in the IDL"
+		}
+		m.errorf(path, "instantiated type name %s of %s collides with %s", name, key, other)
+		return "", false
+	}
+	if m.depth >= maxInstantiationDepth {
+		m.errorf(path, "too deep instantiation of type %s", def.Name)
+		return "", false
+	}
+	m.instances[key] = name
+	m.instanceKeys[name] = key
+	m.origins[name] = def.Name
+
+	params := make(map[string]idl.IdlGenericArg, len(args))
+	for i, param := range def.Generics {
+		params[genericParamName(param)] = args[i]
+	}
+
+	// The definition is shared by all the instantiations.
+	instance := *def.Clone()
+	instance.Name = name
+	instance.Generics = nil
+
+	m.depth++
+	instance.Type.VisitTypes(m.genericPaths[def.Name]+".type", func(path string, typ *idl.IdlType) {
+		m.substituteOnce(path, typ, params)
+	})
+	m.depth--
+
+	m.added = append(m.added, instance)
+	return name, true
+}
+
+func genericParamName(param idl.IdlTypeDefGeneric) string {
+	if param.IsConst() {
+		return param.GetConst().Name
+	}
+	return param.GetType().Name
+}
+
+// instanceName returns the Go friendly name of the instantiation, e.g. `FooU64N32` for `Foo<u64, 32>`.
+func instanceName(name string, args []idl.IdlGenericArg) (string, error) {
+	var builder strings.Builder
+	builder.WriteString(name)
+	for _, arg := range args {
+		if arg.IsConst() {
+			value := arg.GetConst().Value
+			if isIdentifier(value) {
+				// A constant of the program.
+				builder.WriteString(constantTypeName(value))
+				continue
+			}
+			if strings.HasPrefix(value, "-") {
+				builder.WriteString("Neg")
+				value = value[1:]
+			}
+			n, err := helper.ParseRustUint(value)
+			if err != nil {
+				return "", fmt.Errorf("const argument %s is neither an integer nor a constant", arg.GetConst().Value)
+			}
+			builder.WriteString("N" + strconv.FormatUint(n, 10))
+			continue
+		}
+		typeName, err := instanceTypeName(arg.GetType().Type)
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(typeName)
+	}
+	return builder.String(), nil
+}
+
+func instanceTypeName(typ idl.IdlType) (string, error) {
+	switch {
+	case typ.IsSimple():
+		return helper.ToCamelCase(typ.GetSimple().String()), nil
+	case typ.IsOption():
+		inner, err := instanceTypeName(typ.GetOption().Option)
+		return "Option" + inner, err
+	case typ.IsVec():
+		inner, err := instanceTypeName(typ.GetVec().Vec)
+		return "Vec" + inner, err
+	case typ.IsArray():
+		inner, err := instanceTypeName(typ.GetArray().Elem)
+		if err != nil {
+			return "", err
+		}
+		if typ.GetArray().Len.IsGeneric() {
+			return "Array" + inner + constantTypeName(typ.GetArray().Len.GetGeneric().Value), nil
+		}
+		return fmt.Sprintf("Array%sN%d", inner, typ.GetArray().Len.GetValue().Value), nil
+	case typ.IsDefined():
+		return typ.GetDefined().Name, nil
+	case typ.IsHashMap():
+		key, err := instanceTypeName(typ.GetHashMap().Key)
+		if err != nil {
+			return "", err
+		}
+		val, err := instanceTypeName(typ.GetHashMap().Val)
+		return "Map" + key + val, err
+	case typ.IsHashSet():
+		inner, err := instanceTypeName(typ.GetHashSet().Elem)
+		return "Set" + inner, err
+	default:
+		return "", fmt.Errorf("unresolved generic type")
+	}
+}
+
+func isIdentifier(s string) bool {
+	for i, r := range s {
+		if !(r == '_' || unicode.IsLetter(r) || (i > 0 && unicode.IsDigit(r))) {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// constantTypeName returns the camel case name of the constant, e.g. `MaxLen` for `MAX_LEN`.
+func constantTypeName(name string) string {
+	if strings.ToUpper(name) == name {
+		name = strings.ToLower(name)
+	}
+	return helper.ToCamelCase(name)
+}
@@ -0,0 +1,228 @@
+package generator
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+const wrapperTypeJson = `{"name": "Wrapper", "generics": [{"kind": "type", "name": "T"}, {"kind": "const", "name": "N", "type": "usize"}],
+  "type": {"kind": "struct", "fields": [{"name": "items", "type": {"array": [{"generic": "T"}, {"generic": "N"}]}}]}}`
+
+// genericIdl returns the IDL of a program which declares the types, and uses the arg types in an instruction.
+func genericIdl(t *testing.T, types []string, argTypes ...string) *idl.Idl {
+	t.Helper()
+	args := make([]string, len(argTypes))
+	for i, argType := range argTypes {
+		args[i] = fmt.Sprintf(`{"name": "arg%d", "type": %s}`, i, argType)
+	}
+	program, err := idl.Parse(fmt.Appendf(nil, `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "generic_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [{
+    "name": "run",
+    "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+    "accounts": [],
+    "args": [%s]
+  }],
+  "constants": [{"name": "MAX_LEN", "type": "u64", "value": "5"}],
+  "types": [%s]
+}`, strings.Join(args, ", "), strings.Join(types, ", ")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return program
+}
+
+func TestMonomorphize(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		argTypes []string
+		// The names of the type definitions after the monomorphization.
+		expected []string
+		// The expected diagnostics as `<path>: <message>`.
+		diags []string
+	}{
+		{
+			name:     "type and const arguments",
+			types:    []string{wrapperTypeJson},
+			argTypes: []string{`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "const", "value": "3"}]}}`},
+			expected: []string{"WrapperU32N3"},
+		},
+		{
+			name:  "same instantiation used twice",
+			types: []string{wrapperTypeJson},
+			argTypes: []string{
+				`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "const", "value": "3"}]}}`,
+				`{"vec": {"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "const", "value": "3"}]}}}`,
+			},
+			expected: []string{"WrapperU32N3"},
+		},
+		{
+			name:     "nested instantiation",
+			types:    []string{wrapperTypeJson},
+			argTypes: []string{`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": {"option": {"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "bool"}, {"kind": "const", "value": "2"}]}}}}, {"kind": "const", "value": "MAX_LEN"}]}}`},
+			expected: []string{"WrapperBoolN2", "WrapperOptionWrapperBoolN2MaxLen"},
+		},
+		{
+			name: "never instantiated",
+			types: []string{
+				wrapperTypeJson,
+				`{"name": "Plain", "type": {"kind": "struct", "fields": [{"name": "value", "type": "u8"}]}}`,
+			},
+			argTypes: []string{`{"defined": {"name": "Plain"}}`},
+			expected: []string{"Plain"},
+			diags:    []string{"types[0]: generic type Wrapper is not generated since it's never instantiated"},
+		},
+		{
+			name: "infinite instantiation",
+			types: []string{`{"name": "Nested", "generics": [{"kind": "type", "name": "T"}],
+  "type": {"kind": "struct", "fields": [{"name": "next", "type": {"option": {"defined": {"name": "Nested", "generics": [{"kind": "type", "type": {"vec": {"generic": "T"}}}]}}}}]}}`},
+			argTypes: []string{`{"defined": {"name": "Nested", "generics": [{"kind": "type", "type": "u8"}]}}`},
+			diags:    []string{"types[0].type.fields[0].type.option: too deep instantiation of type Nested"},
+		},
+		{
+			name:     "undeclared generic argument",
+			types:    []string{wrapperTypeJson},
+			argTypes: []string{`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": {"vec": {"generic": "U"}}}, {"kind": "const", "value": "3"}]}}`},
+			diags:    []string{"instructions[0].args[0].type.defined.generics[0].type.vec: undeclared generic type U"},
+		},
+		{
+			name: "undeclared generic param in a generic type",
+			types: []string{`{"name": "Outer", "generics": [{"kind": "type", "name": "T"}],
+  "type": {"kind": "struct", "fields": [{"name": "values", "type": {"vec": {"option": {"generic": "U"}}}}]}}`},
+			argTypes: []string{`{"defined": {"name": "Outer", "generics": [{"kind": "type", "type": "u8"}]}}`},
+			diags:    []string{"types[0].type.fields[0].type.vec.option: undeclared generic type U"},
+		},
+		{
+			name:     "wrong argument kind",
+			types:    []string{wrapperTypeJson},
+			argTypes: []string{`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "type", "type": "u8"}]}}`},
+			diags:    []string{"instructions[0].args[0].type.defined.generics[1]: generic argument kind doesn't match the param N of type Wrapper"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program := genericIdl(t, tt.types, tt.argTypes...)
+			monomorphized, origins, diags := monomorphize(program)
+
+			var got []string
+			for _, diag := range diags {
+				got = append(got, diag.Path+": "+diag.Message)
+			}
+			if !slices.Equal(got, tt.diags) {
+				t.Errorf("got diagnostics %v, expected %v", got, tt.diags)
+			}
+			if diags.HasErrors() {
+				return
+			}
+
+			var names []string
+			for _, def := range monomorphized.Types {
+				names = append(names, def.Name)
+				if len(def.Generics) > 0 {
+					t.Errorf("type %s is still generic", def.Name)
+				}
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("got types %v, expected %v", names, tt.expected)
+			}
+			for _, name := range names {
+				if origin, ok := origins[name]; ok && !strings.HasPrefix(name, origin) {
+					t.Errorf("got origin %s of type %s", origin, name)
+				}
+			}
+			if len(program.Types) != len(tt.types) {
+				t.Error("the source program is modified")
+			}
+		})
+	}
+}
+
+func TestMonomorphizeArrayLength(t *testing.T) {
+	program := genericIdl(t, []string{wrapperTypeJson},
+		`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "const", "value": "3"}]}}`)
+	monomorphized, origins, diags := monomorphize(program)
+	if len(diags) > 0 {
+		t.Fatal(diags)
+	}
+	if origins["WrapperU32N3"] != "Wrapper" {
+		t.Errorf("got origins %v", origins)
+	}
+	def := monomorphized.FindTypeByName("WrapperU32N3")
+	if def == nil {
+		t.Fatal("WrapperU32N3 isn't declared")
+	}
+	items := def.Type.GetStruct().Fields.GetNamed().Fields[0].Type
+	if !items.IsArray() || !items.GetArray().Len.IsValue() || items.GetArray().Len.GetValue().Value != 3 {
+		t.Errorf("got items of type %v, expected an array of 3 elements", items)
+	}
+	if elem := items.GetArray().Elem; !elem.IsSimple() || elem.GetSimple().String() != "u32" {
+		t.Errorf("got elements of type %v, expected u32", elem)
+	}
+}
+
+func TestGenericsBuild(t *testing.T) {
+	tests := []struct {
+		name     string
+		types    []string
+		argTypes []string
+		// A declaration of the generated package, which is expected once per instantiation.
+		expected []string
+	}{
+		{
+			name: "struct",
+			types: []string{`{"name": "Pair", "generics": [{"kind": "type", "name": "A"}, {"kind": "type", "name": "B"}],
+  "type": {"kind": "struct", "fields": [{"name": "first", "type": {"generic": "A"}}, {"name": "second", "type": {"option": {"generic": "B"}}}]}}`},
+			argTypes: []string{
+				`{"defined": {"name": "Pair", "generics": [{"kind": "type", "type": "u64"}, {"kind": "type", "type": "string"}]}}`,
+				`{"defined": {"name": "Pair", "generics": [{"kind": "type", "type": "pubkey"}, {"kind": "type", "type": {"vec": "u8"}}]}}`,
+			},
+			expected: []string{"type PairU64String struct", "type PairPubkeyVecU8 struct"},
+		},
+		{
+			name: "enum",
+			types: []string{`{"name": "Either", "generics": [{"kind": "type", "name": "L"}, {"kind": "type", "name": "R"}],
+  "type": {"kind": "enum", "variants": [{"name": "Left", "fields": [{"generic": "L"}]}, {"name": "Right", "fields": [{"name": "value", "type": {"generic": "R"}}]}]}}`},
+			argTypes: []string{`{"defined": {"name": "Either", "generics": [{"kind": "type", "type": "u8"}, {"kind": "type", "type": "i64"}]}}`},
+			expected: []string{"type EitherU8I64 interface"},
+		},
+		{
+			name:  "array length",
+			types: []string{wrapperTypeJson},
+			argTypes: []string{
+				`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u32"}, {"kind": "const", "value": "3"}]}}`,
+				`{"defined": {"name": "Wrapper", "generics": [{"kind": "type", "type": "u16"}, {"kind": "const", "value": "MAX_LEN"}]}}`,
+			},
+			expected: []string{"Items [3]uint32", "Items [5]uint16"},
+		},
+	}
+	goBin := lookupGo(t)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := testOutputFolder(t)
+			files, _, err := Generate(Options{DstFolder: dst, GenerateTests: true}, genericIdl(t, tt.types, tt.argTypes...))
+			if err != nil {
+				t.Fatal(err)
+			}
+			types := string(files[filepath.Join(dst, "generic_prog", "types.go")])
+			for _, declaration := range tt.expected {
+				if count := strings.Count(types, declaration); count != 1 {
+					t.Errorf("got %d declarations %q in types.go, expected 1", count, declaration)
+				}
+			}
+
+			writeGeneratedFiles(t, files)
+			// The generated tests encode and decode the instruction with the instantiated types.
+			if out, err := exec.Command(goBin, "test", "./"+filepath.ToSlash(dst)+"/...").CombinedOutput(); err != nil {
+				t.Fatalf("the generated package doesn't pass its tests: %v\n%s", err, out)
+			}
+		})
+	}
+}
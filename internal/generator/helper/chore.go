@@ -2,11 +2,11 @@ package helper
 
 import (
 	"fmt"
+	"math/big"
 	"strconv"
 	"strings"
 
 	. "github.com/dave/jennifer/jen"
-	"github.com/davecgh/go-spew/spew"
 )
 
 func IntToStr(i int) string {
@@ -38,16 +38,61 @@ func CodeIf(condition bool, code Code) Code {
 	return Null()
 }
 
-func BytesStrToBytes(str string) []byte {
+func BytesStrToBytes(str string) ([]byte, error) {
 	values := strings.Split(strings.TrimSuffix(strings.TrimPrefix(str, "["), "]"), ",")
 	bytes := make([]byte, len(values))
 	for i, v := range values {
 		v = strings.Trim(v, " ")
 		b, err := strconv.ParseUint(v, 10, 8)
 		if err != nil {
-			panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(err, str)))
+			return nil, fmt.Errorf("invalid byte %q in %s: %w", v, str, err)
 		}
 		bytes[i] = byte(b)
 	}
-	return bytes
+	return bytes, nil
+}
+
+// ParseRustInt parses an integer literal of Rust, e.g. `-32`, `1_000`, `0xff` or `32usize`.
+func ParseRustInt(str string) (*big.Int, error) {
+	literal := strings.TrimSpace(str)
+	negative := strings.HasPrefix(literal, "-")
+	literal = strings.TrimPrefix(literal, "-")
+	for _, suffix := range []string{"u8", "u16", "u32", "u64", "u128", "usize", "i8", "i16", "i32", "i64", "i128", "isize"} {
+		if strings.HasSuffix(literal, suffix) {
+			literal = strings.TrimSuffix(literal, suffix)
+			break
+		}
+	}
+	literal = strings.ReplaceAll(literal, "_", "")
+	base := 10
+	if len(literal) > 2 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x':
+			base = 16
+		case 'o':
+			base = 8
+		case 'b':
+			base = 2
+		}
+		if base != 10 {
+			literal = literal[2:]
+		}
+	}
+	value, ok := new(big.Int).SetString(literal, base)
+	if !ok {
+		return nil, fmt.Errorf("invalid integer %q", str)
+	}
+	if negative {
+		value.Neg(value)
+	}
+	return value, nil
+}
+
+// ParseRustUint parses an unsigned integer literal of Rust which fits in 64 bits, e.g. `32`, `1_000`, `0xff` or `32usize`.
+func ParseRustUint(str string) (uint64, error) {
+	value, err := ParseRustInt(str)
+	if err != nil || value.Sign() < 0 || !value.IsUint64() {
+		return 0, fmt.Errorf("invalid unsigned integer %q", str)
+	}
+	return value.Uint64(), nil
 }
@@ -2,6 +2,7 @@ package idlcode
 
 import (
 	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
 )
@@ -11,7 +12,7 @@ type FieldCodeOption struct {
 	ComplexEnum bool
 }
 
-func IdlFieldToCode(field idl.IdlField, options FieldCodeOption) Code {
+func IdlFieldToCode(ctx *model.GenerateCtx, field idl.IdlField, options FieldCodeOption) Code {
 	code := Id(helper.ToCamelCase(field.Name)).
 		Add(func() Code {
 			if options.ComplexEnum {
@@ -22,11 +23,15 @@ func IdlFieldToCode(field idl.IdlField, options FieldCodeOption) Code {
 			}
 			return nil
 		}()).
-		Add(IdlTypeToCode(field.Type))
+		Add(IdlTypeToCode(ctx, field.Type))
 
 	if field.Type.IsOption() {
+		tag := "optional"
+		if field.Type.GetOption().COption {
+			tag = "coption"
+		}
 		code.Add(Tag(map[string]string{
-			"bin": "optional",
+			"bin": tag,
 		}))
 	}
 
@@ -6,7 +6,18 @@ import (
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
-	"github.com/davecgh/go-spew/spew"
+)
+
+// Names of the 256-bit integers, they are declared in the generated package since there is no common Go type.
+const (
+	Uint256TypeName = "Uint256"
+	Int256TypeName  = "Int256"
+)
+
+// Names of the maps and the sets, they are declared in the generated package with the deterministic Borsh codecs.
+const (
+	OrderedMapTypeName = "OrderedMap"
+	OrderedSetTypeName = "OrderedSet"
 )
 
 func IdlTypeSimpleToCode(typ idl.IdlTypeSimple) Code {
@@ -38,9 +49,9 @@ func IdlTypeSimpleToCode(typ idl.IdlTypeSimple) Code {
 	case idl.IdlTypeSimpleI128:
 		return Qual(model.PkgDfuseBinary, "Int128")
 	case idl.IdlTypeSimpleU256:
-		panic("Uint256 is not supported yet")
+		return Id(Uint256TypeName)
 	case idl.IdlTypeSimpleI256:
-		panic("Int256 is not supported yet")
+		return Id(Int256TypeName)
 	case idl.IdlTypeSimpleBytes:
 		return Index().Byte()
 	case idl.IdlTypeSimpleString:
@@ -48,45 +59,76 @@ func IdlTypeSimpleToCode(typ idl.IdlTypeSimple) Code {
 	case idl.IdlTypeSimplePubkey:
 		return Qual(model.PkgSolanaGo, "PublicKey")
 	default:
-		panic("unknown type: " + typ)
+		// Unknown types are rejected when unmarshalling the IDL.
+		return Null()
 	}
 }
 
-func IdlTypeToCode(typ idl.IdlType) Code {
+func IdlTypeToCode(ctx *model.GenerateCtx, typ idl.IdlType) Code {
 	code := Empty()
+	if override, ok := typeOverride(ctx, typ); ok {
+		return code.Qual(override.ImportPath, override.Name)
+	}
+
 	switch {
 	case typ.IsSimple():
 		code.Add(IdlTypeSimpleToCode(typ.GetSimple()))
 	case typ.IsOption():
 		opt := typ.GetOption()
-		code.Add(IdlTypeToCode(opt.Option))
+		code.Add(IdlTypeToCode(ctx, opt.Option))
 	case typ.IsVec():
 		vec := typ.GetVec()
-		code.Index().Add(IdlTypeToCode(vec.Vec))
+		code.Index().Add(IdlTypeToCode(ctx, vec.Vec))
 	case typ.IsArray():
 		arr := typ.GetArray()
 		switch {
 		case arr.Len.IsValue():
 			len := strconv.Itoa(int(arr.Len.GetValue().Value))
-			code.Index(Id(len)).Add(IdlTypeToCode(arr.Elem))
+			code.Index(Id(len)).Add(IdlTypeToCode(ctx, arr.Elem))
 		case arr.Len.IsGeneric():
+			// The const generic params are substituted when instantiating the generic types,
+			// the remaining lengths are constants, the unresolved ones are reported before generating code.
+			if len, ok := ctx.GetArrayLen(arr.Len.GetGeneric().Value); ok {
+				code.Index(Id(strconv.Itoa(int(len)))).Add(IdlTypeToCode(ctx, arr.Elem))
+			}
 		}
 	case typ.IsDefined():
-		/// !!! Notice: Generic params are not supported yet
-		code.Add(Id(typ.GetDefined().Name))
-	case typ.IsGeneric():
-		/// !!! Notice: Generic type are not supported yet
-		_ = typ.GetGeneric()
+		// The generic types are instantiated into concrete ones before generating code.
+		name := typ.GetDefined().Name
+		code.Add(DefinedTypeIdentCode(ctx, name, name))
 	case typ.IsHashMap():
 		hashMap := typ.GetHashMap()
-		code.Map(IdlTypeToCode(hashMap.Key)).Add(IdlTypeToCode(hashMap.Val))
+		code.Id(OrderedMapTypeName).Types(IdlTypeToCode(ctx, hashMap.Key), IdlTypeToCode(ctx, hashMap.Val))
+	case typ.IsHashSet():
+		code.Id(OrderedSetTypeName).Types(IdlTypeToCode(ctx, typ.GetHashSet().Elem))
 	default:
-		panic("unknown type: " + spew.Sdump(typ))
+		// Empty types are reported by the generator before generating code,
+		// and the generic params are substituted when instantiating the generic types.
 	}
 
 	return code
 }
 
+func typeOverride(ctx *model.GenerateCtx, typ idl.IdlType) (model.TypeOverride, bool) {
+	switch {
+	case typ.IsSimple():
+		return ctx.GetTypeOverride(typ.GetSimple().String())
+	case typ.IsDefined():
+		return ctx.GetTypeOverride(typ.GetDefined().Name)
+	default:
+		return model.TypeOverride{}, false
+	}
+}
+
+// DefinedTypeIdentCode returns the identifier `ident` which is declared along with the defined type `typeName`.
+// The identifier is qualified with the owner package if the type is shared from another program.
+func DefinedTypeIdentCode(ctx *model.GenerateCtx, typeName string, ident string) *Statement {
+	if importPath, ok := ctx.GetExternalTypeImportPath(typeName); ok {
+		return Qual(importPath, ident)
+	}
+	return Id(ident)
+}
+
 func IdlBytesToValuesCode(bytes []byte) []Code {
 	code := make([]Code, 0, len(bytes))
 	for _, b := range bytes {
@@ -0,0 +1,205 @@
+package generator
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// registerLayouts computes the C layouts of the zero-copy types (`bytemuck` or `bytemuckunsafe` serialization).
+// The zero-copy types are stored as their memory representation, so they can only contain plain old data (`Pod`),
+// the other types are reported as errors instead of being decoded incorrectly.
+func registerLayouts(ctx *model.GenerateCtx, program *idl.Idl) {
+	resolver := &layoutResolver{
+		ctx:      ctx,
+		program:  program,
+		resolved: map[string]*typeLayout{},
+	}
+	for i := range program.Types {
+		typ := &program.Types[i]
+		if !isZeroCopy(typ) || ctx.IsExternalType(typ.Name) {
+			continue
+		}
+		path := fmt.Sprintf("types[%d]", i)
+		if !typ.Type.IsStruct() {
+			ctx.Errorf(path+".type", "zero-copy type %s must be a struct", typ.Name)
+			continue
+		}
+		if layout := resolver.resolve(i).layout; layout != nil {
+			ctx.SetLayout(typ.Name, layout)
+		}
+	}
+}
+
+func isZeroCopy(typ *idl.IdlTypeDef) bool {
+	return typ.Serialization == idl.IdlSerializationBytemuck || typ.Serialization == idl.IdlSerializationBytemuckUnsafe
+}
+
+type typeLayout struct {
+	size  uint
+	align uint
+	// The layout of the fields, it's nil for the aliases.
+	layout *model.Layout
+	ok     bool
+}
+
+type layoutResolver struct {
+	ctx      *model.GenerateCtx
+	program  *idl.Idl
+	resolved map[string]*typeLayout
+	// Names of the types being resolved, to detect the recursive types.
+	resolving []string
+}
+
+// resolve computes the layout of `program.Types[index]`, the problems are reported once at the type definition.
+func (r *layoutResolver) resolve(index int) *typeLayout {
+	def := &r.program.Types[index]
+	if resolved, ok := r.resolved[def.Name]; ok {
+		return resolved
+	}
+	path := fmt.Sprintf("types[%d]", index)
+	if slices.Contains(r.resolving, def.Name) {
+		r.ctx.Errorf(path, "zero-copy type %s is recursive", def.Name)
+		return &typeLayout{}
+	}
+	r.resolving = append(r.resolving, def.Name)
+	defer func() { r.resolving = r.resolving[:len(r.resolving)-1] }()
+
+	resolved := &typeLayout{}
+	switch {
+	case def.Type.IsType():
+		resolved.size, resolved.align, resolved.ok = r.typeLayout(path+".type.alias", &def.Type.GetType().Alias)
+	case def.Type.IsStruct():
+		if layout := r.structLayout(path, def); layout != nil {
+			resolved.size, resolved.align, resolved.layout, resolved.ok = layout.Size, layout.Align, layout, true
+		}
+	}
+	r.resolved[def.Name] = resolved
+	return resolved
+}
+
+func (r *layoutResolver) structLayout(path string, def *idl.IdlTypeDef) *model.Layout {
+	var modifier idl.IdlReprModifier
+	switch {
+	case def.Repr == nil, def.Repr.IsTransparent():
+		// `#[zero_copy]` implies `repr(C)`, and `repr(transparent)` has the layout of its only field.
+	case def.Repr.IsRust():
+		r.ctx.Errorf(path+".repr", "the layout of repr(Rust) is unspecified, type %s must be repr(C) to be stored as zero-copy", def.Name)
+		return nil
+	case def.Repr.IsC():
+		modifier = def.Repr.GetC().Modifier
+	}
+
+	var fields []idl.IdlType
+	var fieldPaths []string
+	switch structDef := def.Type.GetStruct(); {
+	case structDef.Fields.IsNamed():
+		for i, field := range structDef.Fields.GetNamed().Fields {
+			fields = append(fields, field.Type)
+			fieldPaths = append(fieldPaths, fmt.Sprintf("%s.type.fields[%d].type", path, i))
+		}
+	case structDef.Fields.IsTuple():
+		for i, typ := range structDef.Fields.GetTuple().Types {
+			fields = append(fields, typ)
+			fieldPaths = append(fieldPaths, fmt.Sprintf("%s.type.fields[%d]", path, i))
+		}
+	}
+
+	layout := &model.Layout{Align: 1}
+	offset := uint(0)
+	ok := true
+	for i := range fields {
+		size, align, fieldOk := r.typeLayout(fieldPaths[i], &fields[i])
+		if !fieldOk {
+			ok = false
+			continue
+		}
+		if modifier.Packed {
+			align = 1
+		}
+		padding := (align - offset%align) % align
+		layout.Padding = append(layout.Padding, padding)
+		offset += padding + size
+		layout.Align = max(layout.Align, align)
+	}
+	if !ok {
+		return nil
+	}
+	if modifier.Align != nil {
+		layout.Align = max(layout.Align, *modifier.Align)
+	}
+	layout.TrailingPadding = (layout.Align - offset%layout.Align) % layout.Align
+	layout.Size = offset + layout.TrailingPadding
+	return layout
+}
+
+// typeLayout returns the size and the alignment of the type, the type must be plain old data.
+func (r *layoutResolver) typeLayout(path string, typ *idl.IdlType) (size uint, align uint, ok bool) {
+	switch {
+	case typ.IsSimple():
+		switch simple := typ.GetSimple(); simple {
+		case idl.IdlTypeSimpleU8, idl.IdlTypeSimpleI8:
+			return 1, 1, true
+		case idl.IdlTypeSimpleU16, idl.IdlTypeSimpleI16:
+			return 2, 2, true
+		case idl.IdlTypeSimpleU32, idl.IdlTypeSimpleI32, idl.IdlTypeSimpleF32:
+			return 4, 4, true
+		case idl.IdlTypeSimpleU64, idl.IdlTypeSimpleI64, idl.IdlTypeSimpleF64:
+			return 8, 8, true
+		case idl.IdlTypeSimpleU128, idl.IdlTypeSimpleI128:
+			// The 128-bit integers are 8-byte aligned on the SBF target, like on x86_64 before Rust 1.77.
+			return 16, 8, true
+		case idl.IdlTypeSimplePubkey:
+			return 32, 1, true
+		case idl.IdlTypeSimpleBool:
+			r.ctx.Errorf(path, "bool is not plain old data (Pod), use u8 in zero-copy types")
+		default:
+			r.ctx.Errorf(path, "%s is not plain old data (Pod), it can't be stored in zero-copy types", simple)
+		}
+	case typ.IsArray():
+		arrayLen := typ.GetArray().Len
+		var length uint
+		switch {
+		case arrayLen.IsValue():
+			length = arrayLen.GetValue().Value
+		case arrayLen.IsGeneric():
+			var resolved bool
+			if length, resolved = r.ctx.GetArrayLen(arrayLen.GetGeneric().Value); !resolved {
+				// The unresolved lengths are reported by `checkSupportedTypes`.
+				return 0, 0, false
+			}
+		}
+		elemSize, elemAlign, elemOk := r.typeLayout(path+".array[0]", &typ.GetArray().Elem)
+		return length * elemSize, elemAlign, elemOk
+	case typ.IsDefined():
+		name := typ.GetDefined().Name
+		index := slices.IndexFunc(r.program.Types, func(def idl.IdlTypeDef) bool { return def.Name == name })
+		if index < 0 {
+			r.ctx.Errorf(path, "the layout of type %s is unknown, it must be declared in the types of the program", name)
+			return 0, 0, false
+		}
+		def := &r.program.Types[index]
+		if def.Type.IsEnum() {
+			r.ctx.Errorf(path, "enum %s is not plain old data (Pod), it can't be stored in zero-copy types", name)
+			return 0, 0, false
+		}
+		// A nested struct is encoded by its own codecs, which only follow the layout for the zero-copy types.
+		if def.Type.IsStruct() && !isZeroCopy(def) {
+			r.ctx.Errorf(path, "type %s is not plain old data (Pod), it must be a zero-copy type too", name)
+			return 0, 0, false
+		}
+		resolved := r.resolve(index)
+		return resolved.size, resolved.align, resolved.ok
+	case typ.IsOption():
+		r.ctx.Errorf(path, "option is not plain old data (Pod), it can't be stored in zero-copy types")
+	case typ.IsVec():
+		r.ctx.Errorf(path, "vec is not plain old data (Pod), it can't be stored in zero-copy types")
+	case typ.IsHashMap():
+		r.ctx.Errorf(path, "hash map is not plain old data (Pod), it can't be stored in zero-copy types")
+	case typ.IsHashSet():
+		r.ctx.Errorf(path, "hash set is not plain old data (Pod), it can't be stored in zero-copy types")
+	}
+	return 0, 0, false
+}
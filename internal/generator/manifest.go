@@ -0,0 +1,106 @@
+package generator
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+// ManifestFileName is the name of the manifest in the folder of each generated package.
+const ManifestFileName = "anchor-go.manifest.json"
+
+const modulePath = "github.com/alivers/anchor-go"
+
+// Manifest records how a package was generated, the files listed in it are owned by the generator.
+type Manifest struct {
+	Generator string          `json:"generator"`
+	Version   string          `json:"version"`
+	Idl       ManifestIdl     `json:"idl"`
+	Options   ManifestOptions `json:"options"`
+	// Files produced in the package folder, relative to the folder, sorted.
+	Files []string `json:"files"`
+}
+
+type ManifestIdl struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Hex SHA-256 of the IDL JSON, it's empty if the IDL wasn't parsed from JSON.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+type ManifestOptions struct {
+	PkgName          string `json:"pkgName"`
+	ImportBase       string `json:"importBase,omitempty"`
+	GenerateTests    bool   `json:"generateTests"`
+	SkipOptionalFlag bool   `json:"skipOptionalFlag"`
+	Encoder          string `json:"encoder"`
+	// Config applied to the program, it's nil if there is none.
+	Config *ManifestConfig `json:"config,omitempty"`
+}
+
+type ManifestConfig struct {
+	// Path of the config file, it's empty if the config wasn't loaded from a file.
+	Path string `json:"path,omitempty"`
+	// Hex SHA-256 of the config file.
+	Sha256 string `json:"sha256,omitempty"`
+}
+
+// Version returns the version of the generator, it's `(devel)` if not built from a tagged module.
+func Version() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "(devel)"
+	}
+	if info.Main.Path == modulePath && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	for _, dep := range info.Deps {
+		if dep.Path == modulePath {
+			return dep.Version
+		}
+	}
+	return "(devel)"
+}
+
+// ParseManifest parses the content of a manifest.
+func ParseManifest(data []byte) (*Manifest, error) {
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+func generateManifest(opts Options, pkgName string, program *idl.Idl, files []string) ([]byte, error) {
+	manifest := Manifest{
+		Generator: modulePath,
+		Version:   Version(),
+		Idl: ManifestIdl{
+			Name:    program.Metadata.Name,
+			Version: program.Metadata.Version,
+			Sha256:  program.Checksum,
+		},
+		Options: ManifestOptions{
+			PkgName:          pkgName,
+			ImportBase:       opts.ImportBase,
+			GenerateTests:    opts.GenerateTests,
+			SkipOptionalFlag: opts.SkipOptionalFlag,
+			Encoder:          opts.Encoder.String(),
+		},
+		Files: slices.Sorted(slices.Values(files)),
+	}
+	if opts.Config != nil {
+		manifest.Options.Config = &ManifestConfig{
+			Path:   filepath.ToSlash(opts.Config.Path),
+			Sha256: opts.Config.Checksum,
+		}
+	}
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(data, '\n'), nil
+}
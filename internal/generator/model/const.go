@@ -24,7 +24,9 @@ const (
 	DiscriminatorTypeUint32    DiscriminatorType = "u32"
 	DiscriminatorTypeUint8     DiscriminatorType = "u8"
 	DiscriminatorTypeAnchor    DiscriminatorType = "anchor"
-	DiscriminatorTypeDefault   DiscriminatorType = "default"
+	// Anchor discriminators of any length (`#[instruction(discriminator = ...)]`), which don't fit in a `TypeID`.
+	DiscriminatorTypeCustom  DiscriminatorType = "custom"
+	DiscriminatorTypeDefault DiscriminatorType = "default"
 )
 
 func (d DiscriminatorType) String() string {
@@ -53,7 +55,7 @@ func (name EncoderType) GetNewEncoderName() string {
 	case EncoderTypeBorsh:
 		return "NewBorshEncoder"
 	case EncoderTypeCompactU16:
-		return "NewCompact16Encoder"
+		return "NewCompactU16Encoder"
 	default:
 		panic(name)
 	}
@@ -66,7 +68,7 @@ func (name EncoderType) GetNewDecoderName() string {
 	case EncoderTypeBorsh:
 		return "NewBorshDecoder"
 	case EncoderTypeCompactU16:
-		return "NewCompact16Decoder"
+		return "NewCompactU16Decoder"
 	default:
 		panic(name)
 	}
@@ -1,10 +1,35 @@
 package model
 
 import (
+	"github.com/alivers/anchor-go/internal/diagnostic"
 	"github.com/alivers/anchor-go/internal/idl"
 	mapset "github.com/deckarep/golang-set/v2"
 )
 
+// TypeOverride is a Go type declared in another package.
+type TypeOverride struct {
+	ImportPath string
+	Name       string
+}
+
+// Layout is the C layout of a zero-copy type, the type is stored as its memory representation instead of Borsh.
+type Layout struct {
+	Size  uint
+	Align uint
+	// Padding bytes before each field, by the index of the fields.
+	Padding []uint
+	// Padding bytes after the last field, which round the size up to the alignment.
+	TrailingPadding uint
+}
+
+// FieldOption is the option of a field set by the generator config.
+type FieldOption struct {
+	// Whether to skip the optional flag when encoding or decoding the field, it overrides the global option if not nil.
+	SkipOptionalFlag *bool
+	// Name of the field in the IDL, it's empty if the field isn't renamed.
+	IdlName string
+}
+
 type GenerateCtx struct {
 	PkgName           string
 	ProgramName       string
@@ -19,6 +44,24 @@ type GenerateCtx struct {
 	// e.g. enum Foo { Bar(u8), Baz(Struct) }
 	// These enums will be generated into `interface` which have various variants in Go.
 	ComplexEnumRegistry mapset.Set[string]
+	// Types which are owned by another program generated in the same run (declared in `metadata.dependencies`).
+	// Maps the type name to the import path of the package generated for that program.
+	ExternalTypeRegistry map[string]string
+	// Go types which replace the simple types (e.g. `u64`) or the defined types, set by the generator config.
+	TypeOverrideRegistry map[string]TypeOverride
+	// Lengths of the arrays which are given by the integer constants of the program, by the names of the constants.
+	// e.g. `[u8; MAX_LEN]`
+	ArrayLenRegistry map[string]uint
+	// Fixed layouts of the zero-copy types (`bytemuck` or `bytemuckunsafe` serialization), by the names of the types.
+	LayoutRegistry map[string]*Layout
+	// Types with custom serialization, they are encoded by the codecs registered by hand.
+	CustomSerializationRegistry mapset.Set[string]
+	// Options of the fields set by the generator config, by `<owner>.<field>` with the names after the renames.
+	// The owner is the name of the type, the account, the event or the instruction (for the args).
+	FieldOptionRegistry map[string]FieldOption
+
+	// Problems found while generating, the files are not written if there is any error.
+	Diagnostics diagnostic.Diagnostics
 }
 
 func NewGenerateCtx(packageName, programName string, discriminatorType DiscriminatorType, encoder EncoderType, skipOptionalFlag bool) *GenerateCtx {
@@ -32,6 +75,12 @@ func NewGenerateCtx(packageName, programName string, discriminatorType Discrimin
 		IdentifierTypeRegistry:      map[string]*idl.IdlTypeDefTy{},
 		GeneratedIdentifierRegistry: mapset.NewSet[string](),
 		ComplexEnumRegistry:         mapset.NewSet[string](),
+		ExternalTypeRegistry:        map[string]string{},
+		TypeOverrideRegistry:        map[string]TypeOverride{},
+		ArrayLenRegistry:            map[string]uint{},
+		LayoutRegistry:              map[string]*Layout{},
+		CustomSerializationRegistry: mapset.NewSet[string](),
+		FieldOptionRegistry:         map[string]FieldOption{},
 	}
 
 	return ctx
@@ -74,3 +123,82 @@ func (ctx *GenerateCtx) IsGeneratedIdentifier(identName string) bool {
 func (ctx *GenerateCtx) AddGeneratedIdentifier(identName string) {
 	ctx.GeneratedIdentifierRegistry.Add(identName)
 }
+
+func (ctx *GenerateCtx) SetExternalType(name string, importPath string) {
+	ctx.ExternalTypeRegistry[name] = importPath
+}
+
+func (ctx *GenerateCtx) GetExternalTypeImportPath(name string) (string, bool) {
+	importPath, ok := ctx.ExternalTypeRegistry[name]
+	return importPath, ok
+}
+
+func (ctx *GenerateCtx) IsExternalType(name string) bool {
+	_, ok := ctx.ExternalTypeRegistry[name]
+	return ok
+}
+
+func (ctx *GenerateCtx) SetTypeOverride(name string, override TypeOverride) {
+	ctx.TypeOverrideRegistry[name] = override
+}
+
+func (ctx *GenerateCtx) GetTypeOverride(name string) (TypeOverride, bool) {
+	override, ok := ctx.TypeOverrideRegistry[name]
+	return override, ok
+}
+
+func (ctx *GenerateCtx) SetArrayLen(name string, len uint) {
+	ctx.ArrayLenRegistry[name] = len
+}
+
+func (ctx *GenerateCtx) GetArrayLen(name string) (uint, bool) {
+	len, ok := ctx.ArrayLenRegistry[name]
+	return len, ok
+}
+
+func (ctx *GenerateCtx) SetLayout(name string, layout *Layout) {
+	ctx.LayoutRegistry[name] = layout
+}
+
+// GetLayout returns the fixed layout of the type, it's nil if the type is not a zero-copy type.
+func (ctx *GenerateCtx) GetLayout(name string) *Layout {
+	return ctx.LayoutRegistry[name]
+}
+
+func (ctx *GenerateCtx) SetCustomSerialization(name string) {
+	ctx.CustomSerializationRegistry.Add(name)
+}
+
+func (ctx *GenerateCtx) IsCustomSerialization(name string) bool {
+	return ctx.CustomSerializationRegistry.Contains(name)
+}
+
+func (ctx *GenerateCtx) SetFieldOption(owner, field string, option FieldOption) {
+	ctx.FieldOptionRegistry[owner+"."+field] = option
+}
+
+// IsSkipOptionalFlag reports whether to skip the optional flag when encoding or decoding the field of the owner.
+func (ctx *GenerateCtx) IsSkipOptionalFlag(owner string, field idl.IdlField) bool {
+	if skip := ctx.FieldOptionRegistry[owner+"."+field.Name].SkipOptionalFlag; skip != nil {
+		return *skip
+	}
+	return ctx.SkipOptionalFlag
+}
+
+// FieldIdlName returns the name in the IDL of the field of the owner, before being renamed by the generator config.
+func (ctx *GenerateCtx) FieldIdlName(owner string, field idl.IdlField) string {
+	if name := ctx.FieldOptionRegistry[owner+"."+field.Name].IdlName; name != "" {
+		return name
+	}
+	return field.Name
+}
+
+// Errorf records an error found at `path` of the IDL.
+func (ctx *GenerateCtx) Errorf(path string, format string, args ...any) {
+	ctx.Diagnostics.Errorf(ctx.ProgramName, path, format, args...)
+}
+
+// Warnf records a warning found at `path` of the IDL.
+func (ctx *GenerateCtx) Warnf(path string, format string, args ...any) {
+	ctx.Diagnostics.Warnf(ctx.ProgramName, path, format, args...)
+}
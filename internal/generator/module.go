@@ -0,0 +1,44 @@
+package generator
+
+import (
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// detectImportBase returns the import path of `dstFolder` according to the nearest `go.mod`.
+// It returns empty if there is no `go.mod` in the folder or its parents.
+func detectImportBase(dstFolder string) string {
+	dir, err := filepath.Abs(dstFolder)
+	if err != nil {
+		return ""
+	}
+
+	for current := dir; ; current = filepath.Dir(current) {
+		if data, err := os.ReadFile(filepath.Join(current, "go.mod")); err == nil {
+			modulePath := parseModulePath(data)
+			if modulePath == "" {
+				return ""
+			}
+			rel, err := filepath.Rel(current, dir)
+			if err != nil {
+				return ""
+			}
+			return path.Join(modulePath, filepath.ToSlash(rel))
+		}
+		if filepath.Dir(current) == current {
+			return ""
+		}
+	}
+}
+
+func parseModulePath(goMod []byte) string {
+	for _, line := range strings.Split(string(goMod), "\n") {
+		line = strings.TrimSpace(line)
+		if modulePath, ok := strings.CutPrefix(line, "module "); ok {
+			return strings.Trim(strings.TrimSpace(modulePath), `"`)
+		}
+	}
+	return ""
+}
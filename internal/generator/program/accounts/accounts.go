@@ -1,6 +1,8 @@
 package accounts
 
 import (
+	"fmt"
+
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/generator/program/common"
@@ -11,23 +13,22 @@ import (
 func GenerateAccounts(ctx *model.GenerateCtx, program *idl.Idl) *File {
 	file := helper.NewGoFile(ctx)
 
-	for _, acc := range program.Accounts {
+	for i, acc := range program.Accounts {
 		identType := ctx.GetIdentifierTy(acc.Name)
 		if identType == nil {
-			panic("account " + acc.Name + " not found in IDL types")
-		}
-
-		var discriminator *[8]byte
-		if acc.Discriminator != nil {
-			discriminator = (*[8]byte)(acc.Discriminator)
+			ctx.Errorf(fmt.Sprintf("accounts[%d]", i), "account %s not found in IDL types", acc.Name)
+			continue
 		}
 
 		file.Add(
 			common.GenerateTypeDefCode(
 				ctx,
+				acc.Name,
 				acc.Name+"Account",
 				identType,
-				discriminator,
+				acc.Discriminator,
+				ctx.GetLayout(acc.Name),
+				helper.StrIf(ctx.IsCustomSerialization(acc.Name), acc.Name),
 				program,
 			),
 		)
@@ -0,0 +1,49 @@
+package accounts
+
+import (
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// UsesRelations reports whether any instruction account has `relations` (`has_one` of other accounts),
+// which are resolved with the `AccountFetcher` declared by `GenerateAccountFetcher`.
+func UsesRelations(program *idl.Idl) bool {
+	for i := range program.Instructions {
+		for _, account := range program.Instructions[i].GetAccounts() {
+			if len(account.Relations) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// GenerateAccountFetcher declares the `AccountFetcher`, which loads the raw data of the accounts for resolving the relations.
+func GenerateAccountFetcher(ctx *model.GenerateCtx) *File {
+	file := helper.NewGoFile(ctx)
+
+	fetchParams := func() *Statement {
+		return Params(Id("ctx").Qual("context", "Context"), Id("address").Qual(model.PkgSolanaGo, "PublicKey"))
+	}
+	fetchResults := func() *Statement {
+		return Params(Index().Byte(), Error())
+	}
+
+	file.Comment("AccountFetcher fetches the raw data of the accounts, e.g. with `GetAccountInfo` of the RPC client.")
+	file.Type().Id("AccountFetcher").Interface(
+		Id("FetchAccountData").Add(fetchParams()).Add(fetchResults()),
+	)
+	file.Line()
+
+	file.Comment("AccountFetcherFunc is a function implementing `AccountFetcher`.")
+	file.Type().Id("AccountFetcherFunc").Func().Add(fetchParams()).Add(fetchResults())
+	file.Line()
+
+	file.Func().Params(Id("f").Id("AccountFetcherFunc")).Id("FetchAccountData").Add(fetchParams()).Add(fetchResults()).Block(
+		Return(Id("f").Call(Id("ctx"), Id("address"))),
+	)
+
+	return file
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"fmt"
+
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	. "github.com/dave/jennifer/jen"
+)
+
+// generateCustomCodecCode generates the codecs of a struct of the type with custom serialization.
+// The encoding is unknown to the IDL, so the type declares a codec interface which is implemented by hand
+// (in a file which is kept when regenerating) and registered at runtime, instead of guessing Borsh.
+// The other structs of the type (e.g. the account) are encoded as the type after their discriminator.
+func generateCustomCodecCode(ctx *model.GenerateCtx, exportedStructName string, customType string, discriminatorName *string, discriminator []byte) Code {
+	if exportedStructName == helper.ToCamelCase(customType) {
+		return generateCustomCodecHookCode(exportedStructName)
+	}
+
+	typeCode := func() *Statement { return idlcode.DefinedTypeIdentCode(ctx, customType, customType) }
+	code := Empty()
+	code.Func().Params(Id("obj").Id(exportedStructName)).Id("MarshalWithEncoder").
+		Params(
+			Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		BlockFunc(func(body *Group) {
+			if discriminatorName != nil && *discriminatorName != "" {
+				body.Comment("Write account discriminator:")
+				body.Err().Op("=").Id("encoder").Dot("WriteBytes").Call(Id(*discriminatorName).Index(Op(":")), False())
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+			body.Commentf("The data is encoded by the codec of `%s`:", customType)
+			body.Return(Id("encoder").Dot("Encode").Call(typeCode().Call(Id("obj"))))
+		})
+
+	code.Line().Line()
+	code.Func().Params(Id("obj").Op("*").Id(exportedStructName)).Id("UnmarshalWithDecoder").
+		Params(
+			Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		BlockFunc(func(body *Group) {
+			if discriminatorName != nil && *discriminatorName != "" {
+				body.Comment("Read and check account discriminator:")
+				body.Add(readDiscriminatorCode(*discriminatorName, discriminator))
+			}
+			body.Commentf("The data is decoded by the codec of `%s`:", customType)
+			body.Var().Id("value").Add(typeCode())
+			body.Err().Op("=").Id("decoder").Dot("Decode").Call(Op("&").Id("value"))
+			body.If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			)
+			body.Op("*").Id("obj").Op("=").Id(exportedStructName).Call(Id("value"))
+			body.Return(Nil())
+		})
+	return code
+}
+
+func generateCustomCodecHookCode(typeName string) Code {
+	interfaceName := GetCustomCodecInterfaceName(typeName)
+	varName := GetCustomCodecVarName(typeName)
+	registerName := GetCustomCodecRegisterName(typeName)
+	missingCodecMessage := fmt.Sprintf("%s has a custom serialization, its codec must be registered by %s", typeName, registerName)
+
+	code := Empty()
+	code.Commentf("%s encodes and decodes `%s`, which has a custom serialization in the program.", interfaceName, typeName).Line()
+	code.Commentf("Implement it in a hand-written file of this package (it's kept when regenerating), and register it by `%s`.", registerName).Line()
+	code.Type().Id(interfaceName).Interface(
+		Id("Marshal").Params(Id("obj").Id(typeName), Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder")).Error(),
+		Id("Unmarshal").Params(Id("obj").Op("*").Id(typeName), Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder")).Error(),
+	).Line().Line()
+
+	code.Var().Id(varName).Id(interfaceName).Line().Line()
+
+	code.Commentf("%s sets the codec of `%s`, it's usually called in an `init` function.", registerName, typeName).Line()
+	code.Func().Id(registerName).Params(Id("codec").Id(interfaceName)).Block(
+		Id(varName).Op("=").Id("codec"),
+	).Line().Line()
+
+	code.Func().Params(Id("obj").Id(typeName)).Id("MarshalWithEncoder").
+		Params(
+			Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		Block(
+			If(Id(varName).Op("==").Nil()).Block(
+				Return(Qual("errors", "New").Call(Lit(missingCodecMessage))),
+			),
+			Return(Id(varName).Dot("Marshal").Call(Id("obj"), Id("encoder"))),
+		).Line().Line()
+
+	code.Func().Params(Id("obj").Op("*").Id(typeName)).Id("UnmarshalWithDecoder").
+		Params(
+			Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		Block(
+			If(Id(varName).Op("==").Nil()).Block(
+				Return(Qual("errors", "New").Call(Lit(missingCodecMessage))),
+			),
+			Return(Id(varName).Dot("Unmarshal").Call(Id("obj"), Id("decoder"))),
+		)
+	return code
+}
@@ -0,0 +1,116 @@
+package common
+
+import (
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// GenerateMarshalWithEncoderForLayout encodes the zero-copy struct as its memory representation.
+// The fields are plain old data, so their Borsh encoding is their memory representation,
+// only the padding between them is written explicitly (as zeros).
+func GenerateMarshalWithEncoderForLayout(
+	marshalReceiverName string,
+	fields []idl.IdlField,
+	layout *model.Layout,
+	structDiscriminatorName *string,
+) Code {
+	code := Empty()
+	code.Func().Params(Id("obj").Id(marshalReceiverName)).Id("MarshalWithEncoder").
+		Params(
+			Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		BlockFunc(func(body *Group) {
+			if structDiscriminatorName != nil && *structDiscriminatorName != "" {
+				body.Comment("Write account discriminator:")
+				body.Err().Op("=").Id("encoder").Dot("WriteBytes").Call(Id(*structDiscriminatorName).Index(Op(":")), False())
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+
+			writePadding := func(padding uint) {
+				if padding == 0 {
+					return
+				}
+				body.Err().Op("=").Id("encoder").Dot("WriteBytes").Call(Make(Index().Byte(), Lit(int(padding))), False())
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+
+			for i, field := range fields {
+				exportedArgName := helper.ToCamelCase(field.Name)
+				body.Commentf("Serialize `%s` param:", exportedArgName)
+				writePadding(layout.Padding[i])
+				body.Err().Op("=").Id("encoder").Dot("Encode").Call(Id("obj").Dot(exportedArgName))
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+
+			if layout.TrailingPadding > 0 {
+				body.Comment("Pad the struct to its alignment:")
+				writePadding(layout.TrailingPadding)
+			}
+
+			body.Return(Nil())
+		})
+	return code
+}
+
+// GenerateUnmarshalWithDecoderForLayout decodes the zero-copy struct from its memory representation, the padding is skipped.
+func GenerateUnmarshalWithDecoderForLayout(
+	marshalReceiverName string,
+	fields []idl.IdlField,
+	layout *model.Layout,
+	structDiscriminatorName *string,
+	structDiscriminator []byte,
+) Code {
+	code := Empty()
+	code.Func().Params(Id("obj").Op("*").Id(marshalReceiverName)).Id("UnmarshalWithDecoder").
+		Params(
+			Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder"),
+		).
+		Params(
+			Err().Error(),
+		).
+		BlockFunc(func(body *Group) {
+			if structDiscriminatorName != nil && *structDiscriminatorName != "" {
+				body.Comment("Read and check account discriminator:")
+				body.Add(readDiscriminatorCode(*structDiscriminatorName, structDiscriminator))
+			}
+
+			skipPadding := func(padding uint) {
+				if padding == 0 {
+					return
+				}
+				body.Err().Op("=").Id("decoder").Dot("SkipBytes").Call(Lit(int(padding)))
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+
+			for i, field := range fields {
+				exportedArgName := helper.ToCamelCase(field.Name)
+				body.Commentf("Deserialize `%s`:", exportedArgName)
+				skipPadding(layout.Padding[i])
+				body.Err().Op("=").Id("decoder").Dot("Decode").Call(Op("&").Id("obj").Dot(exportedArgName))
+				body.If(Err().Op("!=").Nil()).Block(
+					Return(Err()),
+				)
+			}
+
+			if layout.TrailingPadding > 0 {
+				body.Comment("Skip the padding at the end of the struct:")
+				skipPadding(layout.TrailingPadding)
+			}
+
+			body.Return(Nil())
+		})
+	return code
+}
@@ -8,7 +8,7 @@ func GetInstructionEnumName(instructionExportedName string) string {
 	return "Instruction_" + instructionExportedName
 }
 
-func formatSimpleEnumVariantName(enumTypeName string, enumVariantName string) string {
+func GetSimpleEnumVariantName(enumTypeName string, enumVariantName string) string {
 	return helper.ToCamelCase(enumTypeName + "_" + enumVariantName)
 }
 
@@ -20,6 +20,9 @@ func GetEnumVariantsContainerName(enumTypeName string) string {
 	return helper.ToLowerCamelCase(enumTypeName) + "Container"
 }
 
+// ComplexEnumVariantIndexMethodName is the method of the complex enum variants which returns the index of the variant.
+const ComplexEnumVariantIndexMethodName = "VariantIndex"
+
 func GetComplexEnumInterfaceMethodName(enumTypeName string) string {
 	return "is" + helper.ToCamelCase(enumTypeName)
 }
@@ -35,3 +38,15 @@ func GetInstructionConstructorName(instructionExportedName string) string {
 func GetDiscriminatorName(indentName string) string {
 	return indentName + "Discriminator"
 }
+
+func GetCustomCodecInterfaceName(typeName string) string {
+	return helper.ToCamelCase(typeName) + "Codec"
+}
+
+func GetCustomCodecVarName(typeName string) string {
+	return helper.ToLowerCamelCase(typeName) + "Codec"
+}
+
+func GetCustomCodecRegisterName(typeName string) string {
+	return "Register" + helper.ToCamelCase(typeName) + "Codec"
+}
@@ -4,15 +4,18 @@ import (
 	"fmt"
 
 	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
-	ag_binary "github.com/gagliardetto/binary"
 )
 
+// GenerateMarshalWithEncoderForStruct declares the encoder of the struct, the fields are the ones of
+// `fieldsOwner` in the IDL, whose options are set by the generator config.
 func GenerateMarshalWithEncoderForStruct(
 	ctx *model.GenerateCtx,
 	marshalReceiverName string,
+	fieldsOwner string,
 	fields []idl.IdlField,
 	structDiscriminatorName *string,
 	checkFieldNil bool,
@@ -50,11 +53,11 @@ func GenerateMarshalWithEncoderForStruct(
 						argBody.List(Id("tmp")).Op(":=").Id(GetEnumVariantsContainerName(enumTypeName)).Block()
 						argBody.Switch(Id("realvalue").Op(":=").Id("obj").Dot(exportedArgName).Op(".").Parens(Type())).
 							BlockFunc(func(switchGroup *Group) {
-								interfaceType := program.FindTypeByName(enumTypeName)
-								for variantIndex, variant := range interfaceType.Type.Variants {
+								interfaceType := ctx.GetIdentifierTy(enumTypeName).GetEnum()
+								for variantIndex, variant := range interfaceType.Variants {
 									variantTypeNameStruct := GetComplexEnumVariantTypeName(enumTypeName, variant.Name)
 
-									switchGroup.Case(Op("*").Id(variantTypeNameStruct)).
+									switchGroup.Case(Op("*").Add(idlcode.DefinedTypeIdentCode(ctx, enumTypeName, variantTypeNameStruct))).
 										BlockFunc(func(caseGroup *Group) {
 											caseGroup.Id("tmp").Dot("Enum").Op("=").Lit(variantIndex)
 											caseGroup.Id("tmp").Dot(helper.ToCamelCase(variant.Name)).Op("=").Op("*").Id("realvalue")
@@ -75,14 +78,14 @@ func GenerateMarshalWithEncoderForStruct(
 					if field.Type.IsOption() {
 						if checkFieldNil {
 							body.BlockFunc(func(optGroup *Group) {
-								if !ctx.SkipOptionalFlag {
+								if !ctx.IsSkipOptionalFlag(fieldsOwner, field) {
 									optGroup.If(Id("obj").Dot(helper.ToCamelCase(field.Name)).Op("==").Nil()).Block(
-										Err().Op("=").Id("encoder").Dot("WriteBool").Call(False()),
+										Err().Op("=").Id("encoder").Dot(optionFlagWriter(field)).Call(False()),
 										If(Err().Op("!=").Nil()).Block(
 											Return(Err()),
 										),
 									).Else().Block(
-										Err().Op("=").Id("encoder").Dot("WriteBool").Call(True()),
+										Err().Op("=").Id("encoder").Dot(optionFlagWriter(field)).Call(True()),
 										If(Err().Op("!=").Nil()).Block(
 											Return(Err()),
 										),
@@ -102,8 +105,8 @@ func GenerateMarshalWithEncoderForStruct(
 							})
 						} else {
 							body.BlockFunc(func(optGroup *Group) {
-								if !ctx.SkipOptionalFlag {
-									optGroup.Err().Op("=").Id("encoder").Dot("WriteBool").Call(True())
+								if !ctx.IsSkipOptionalFlag(fieldsOwner, field) {
+									optGroup.Err().Op("=").Id("encoder").Dot(optionFlagWriter(field)).Call(True())
 									optGroup.If(Err().Op("!=").Nil()).Block(
 										Return(Err()),
 									)
@@ -131,12 +134,14 @@ func GenerateMarshalWithEncoderForStruct(
 	return code
 }
 
+// GenerateUnmarshalWithDecoderForStruct declares the decoder of the struct, see `GenerateMarshalWithEncoderForStruct`.
 func GenerateUnmarshalWithDecoderForStruct(
 	ctx *model.GenerateCtx,
 	marshalReceiverName string,
+	fieldsOwner string,
 	fields []idl.IdlField,
 	structDiscriminatorName *string,
-	structDiscriminator *ag_binary.TypeID,
+	structDiscriminator []byte,
 	program *idl.Idl,
 ) Code {
 	code := Empty()
@@ -150,21 +155,7 @@ func GenerateUnmarshalWithDecoderForStruct(
 		BlockFunc(func(body *Group) {
 			if structDiscriminatorName != nil && *structDiscriminatorName != "" {
 				body.Comment("Read and check account discriminator:")
-				body.BlockFunc(func(discReadBody *Group) {
-					discReadBody.List(Id("discriminator"), Err()).Op(":=").Id("decoder").Dot("ReadTypeID").Call()
-					discReadBody.If(Err().Op("!=").Nil()).Block(
-						Return(Err()),
-					)
-					discReadBody.If(Op("!").Id("discriminator").Dot("Equal").Call(Id(*structDiscriminatorName).Index(Op(":")))).Block(
-						Return(
-							Qual("fmt", "Errorf").Call(
-								Line().Lit("wrong discriminator: wanted %s, got %s"),
-								Line().Lit(fmt.Sprintf("%v", structDiscriminator[:])),
-								Line().Qual("fmt", "Sprint").Call(Id("discriminator").Index(Op(":"))),
-							),
-						),
-					)
-				})
+				body.Add(readDiscriminatorCode(*structDiscriminatorName, structDiscriminator))
 			}
 
 			for _, field := range fields {
@@ -191,15 +182,15 @@ func GenerateUnmarshalWithDecoderForStruct(
 
 						argBody.Switch(Id("tmp").Dot("Enum")).
 							BlockFunc(func(switchGroup *Group) {
-								interfaceType := program.FindTypeByName(enumName)
-								for variantIndex, variant := range interfaceType.Type.Variants {
+								interfaceType := ctx.GetIdentifierTy(enumName).GetEnum()
+								for variantIndex, variant := range interfaceType.Variants {
 									variantTypeNameComplex := GetComplexEnumVariantTypeName(enumName, variant.Name)
 
 									if variant.IsUint8Variant() {
 										switchGroup.Case(Lit(variantIndex)).
 											BlockFunc(func(caseGroup *Group) {
 												caseGroup.Id("obj").Dot(exportedArgName).Op("=").
-													Parens(Op("*").Id(variantTypeNameComplex)).
+													Parens(Op("*").Add(idlcode.DefinedTypeIdentCode(ctx, enumName, variantTypeNameComplex))).
 													Parens(Op("&").Id("tmp").Dot(variant.Name))
 											})
 									} else {
@@ -233,8 +224,8 @@ func GenerateUnmarshalWithDecoderForStruct(
 								),
 							}
 
-							if !ctx.SkipOptionalFlag {
-								optGroup.List(Id("ok"), Err()).Op(":=").Id("decoder").Dot("ReadBool").Call()
+							if !ctx.IsSkipOptionalFlag(fieldsOwner, field) {
+								optGroup.List(Id("ok"), Err()).Op(":=").Id("decoder").Dot(optionFlagReader(field)).Call()
 								optGroup.If(Err().Op("!=").Nil()).Block(
 									Return(Err()),
 								)
@@ -265,3 +256,38 @@ func GenerateUnmarshalWithDecoderForStruct(
 		})
 	return code
 }
+
+// optionFlagWriter returns the method of the encoder which writes the presence flag of the optional field.
+func optionFlagWriter(field idl.IdlField) string {
+	if field.Type.GetOption().COption {
+		return "WriteCOption"
+	}
+	return "WriteBool"
+}
+
+// optionFlagReader returns the method of the decoder which reads the presence flag of the optional field.
+func optionFlagReader(field idl.IdlField) string {
+	if field.Type.GetOption().COption {
+		return "ReadCOption"
+	}
+	return "ReadBool"
+}
+
+// readDiscriminatorCode reads the discriminator and checks it's the expected one, it can be of any length.
+func readDiscriminatorCode(discriminatorName string, discriminator []byte) Code {
+	return BlockFunc(func(discReadBody *Group) {
+		discReadBody.List(Id("discriminator"), Err()).Op(":=").Id("decoder").Dot("ReadNBytes").Call(Lit(len(discriminator)))
+		discReadBody.If(Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		)
+		discReadBody.If(Op("!").Qual(model.PkgBytes, "Equal").Call(Id("discriminator"), Id(discriminatorName).Index(Op(":")))).Block(
+			Return(
+				Qual("fmt", "Errorf").Call(
+					Line().Lit("wrong discriminator: wanted %s, got %s"),
+					Line().Lit(fmt.Sprintf("%v", discriminator)),
+					Line().Qual("fmt", "Sprint").Call(Id("discriminator")),
+				),
+			),
+		)
+	})
+}
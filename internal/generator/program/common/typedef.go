@@ -8,17 +8,18 @@ import (
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
-	"github.com/davecgh/go-spew/spew"
-	ag_binary "github.com/gagliardetto/binary"
 )
 
-func GenerateTypeDefCode(ctx *model.GenerateCtx, typeName string, typeDef *idl.IdlTypeDefTy, anchorDiscriminator *[8]byte, program *idl.Idl) Code {
+// GenerateTypeDefCode declares the type, the struct is encoded with the fixed layout instead of Borsh if `layout` is not nil.
+// `customType` is the name of the type with custom serialization which the struct is declared for, it's empty for the other types.
+// GenerateTypeDefCode declares the type `typeName` defined by `idlName` in the IDL.
+func GenerateTypeDefCode(ctx *model.GenerateCtx, idlName string, typeName string, typeDef *idl.IdlTypeDefTy, anchorDiscriminator []byte, layout *model.Layout, customType string, program *idl.Idl) Code {
 	code := Empty()
 	switch {
 	case typeDef.IsStruct():
 		structDef := typeDef.GetStruct()
 		exportedStructName := helper.ToCamelCase(typeName)
-		code.Add(generateStructTypeDefCode(ctx, exportedStructName, structDef, anchorDiscriminator, program)).Line()
+		code.Add(generateStructTypeDefCode(ctx, idlName, exportedStructName, structDef, anchorDiscriminator, layout, customType, program)).Line()
 	case typeDef.IsEnum():
 		enumDef := typeDef.GetEnum()
 		enumTypeName := typeName
@@ -30,9 +31,9 @@ func GenerateTypeDefCode(ctx *model.GenerateCtx, typeName string, typeDef *idl.I
 		}
 	case typeDef.IsType():
 		aliasDef := typeDef.GetType()
-		code.Type().Id(typeName).Op("=").Add(idlcode.IdlTypeToCode(aliasDef.Alias)).Line()
+		code.Type().Id(typeName).Op("=").Add(idlcode.IdlTypeToCode(ctx, aliasDef.Alias)).Line()
 	default:
-		panic(fmt.Sprintf("not implemented: %s", spew.Sdump(typeDef)))
+		// Empty type definitions are reported by the generator before generating code.
 	}
 	return code
 }
@@ -42,7 +43,7 @@ func generateUint8EnumCode(enumTypeName string, enumDef *idl.IdlTypeDefTyEnum) C
 	code.Type().Id(enumTypeName).Qual(model.PkgDfuseBinary, "BorshEnum")
 	code.Line().Const().DefsFunc(func(gr *Group) {
 		for variantIndex, variant := range enumDef.Variants {
-			gr.Id(formatSimpleEnumVariantName(enumTypeName, variant.Name)).Add(func() Code {
+			gr.Id(GetSimpleEnumVariantName(enumTypeName, variant.Name)).Add(func() Code {
 				if variantIndex == 0 {
 					return Id(enumTypeName).Op("=").Iota()
 				}
@@ -58,7 +59,7 @@ func generateUint8EnumCode(enumTypeName string, enumDef *idl.IdlTypeDefTyEnum) C
 		BlockFunc(func(body *Group) {
 			body.Switch(Id("value")).BlockFunc(func(switchBlock *Group) {
 				for _, variant := range enumDef.Variants {
-					switchBlock.Case(Id(formatSimpleEnumVariantName(enumTypeName, variant.Name))).Line().Return(Lit(variant.Name))
+					switchBlock.Case(Id(GetSimpleEnumVariantName(enumTypeName, variant.Name))).Line().Return(Lit(variant.Name))
 				}
 				switchBlock.Default().Line().Return(Lit(""))
 			})
@@ -68,31 +69,17 @@ func generateUint8EnumCode(enumTypeName string, enumDef *idl.IdlTypeDefTyEnum) C
 }
 
 func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDef *idl.IdlTypeDefTyEnum, program *idl.Idl) Code {
-	containerName := GetEnumVariantsContainerName(enumTypeName)
 	interfaceMethodName := GetComplexEnumInterfaceMethodName(enumTypeName)
 
 	code := Type().Id(enumTypeName).Interface(
 		Id(interfaceMethodName).Call(),
 	).Line().Line()
 
-	enumVariantNames := make([]string, len(enumDef.Variants))
-
 	// Declare the enum variants container (non-exported, used internally)
-	code.Type().Id(containerName).StructFunc(
-		func(structGroup *Group) {
-			structGroup.Id("Enum").Qual(model.PkgDfuseBinary, "BorshEnum").Tag(map[string]string{
-				"borsh_enum": "true",
-			})
-
-			for i, variant := range enumDef.Variants {
-				enumVariantNames[i] = GetComplexEnumVariantTypeName(enumTypeName, variant.Name)
-				structGroup.Id(helper.ToCamelCase(variant.Name)).Id(enumVariantNames[i])
-			}
-		},
-	).Line().Line()
+	code.Add(GenerateComplexEnumContainerCode(ctx, enumTypeName, enumDef)).Line().Line()
 
 	for variantIndex, variant := range enumDef.Variants {
-		variantTypeNameComplex := enumVariantNames[variantIndex]
+		variantTypeNameComplex := GetComplexEnumVariantTypeName(enumTypeName, variant.Name)
 		var complexVariantFields []idl.IdlField
 
 		// Declare the enum variant types:
@@ -106,7 +93,7 @@ func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDe
 						namedFields := variant.Fields.GetNamed().Fields
 						complexVariantFields = namedFields
 						for _, variantField := range namedFields {
-							structGroup.Add(idlcode.IdlFieldToCode(variantField, idlcode.FieldCodeOption{
+							structGroup.Add(idlcode.IdlFieldToCode(ctx, variantField, idlcode.FieldCodeOption{
 								AsPointer:   variantField.Type.IsOption(),
 								ComplexEnum: ctx.IsComplexEnumByType(&variantField.Type),
 							}))
@@ -119,7 +106,7 @@ func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDe
 								Type: variantTupleItem,
 							}
 							complexVariantFields = append(complexVariantFields, variantField)
-							structGroup.Add(idlcode.IdlFieldToCode(variantField, idlcode.FieldCodeOption{
+							structGroup.Add(idlcode.IdlFieldToCode(ctx, variantField, idlcode.FieldCodeOption{
 								AsPointer:   variantField.Type.IsOption(),
 								ComplexEnum: ctx.IsComplexEnumByType(&variantField.Type),
 							}))
@@ -164,6 +151,7 @@ func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDe
 				GenerateMarshalWithEncoderForStruct(
 					ctx,
 					variantTypeNameComplex,
+					"",
 					complexVariantFields,
 					nil,
 					true,
@@ -175,6 +163,7 @@ func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDe
 				GenerateUnmarshalWithDecoderForStruct(
 					ctx,
 					variantTypeNameComplex,
+					"",
 					complexVariantFields,
 					nil,
 					nil,
@@ -193,14 +182,43 @@ func generateComplexEnumCode(ctx *model.GenerateCtx, enumTypeName string, enumDe
 		}
 
 		code.Line().Line()
+
+		// Declare the method which returns the Borsh tag of the variant, the keys of the maps and sets are ordered by it:
+		code.Comment(fmt.Sprintf("%s returns the index of the variant in the %s enum.", ComplexEnumVariantIndexMethodName, enumTypeName)).Line()
+		code.Func().Params(Id("_").Op("*").Id(variantTypeNameComplex)).Id(ComplexEnumVariantIndexMethodName).Params().Uint8().Block(
+			Return(Lit(variantIndex)),
+		)
+
+		code.Line().Line()
 	}
 
 	return code
 }
 
-func generateStructTypeDefCode(ctx *model.GenerateCtx, exportedStructName string, structDef *idl.IdlTypeDefTyStruct, anchorDiscriminator *[8]byte, program *idl.Idl) Code {
+// GenerateComplexEnumContainerCode declares the container which is used to (de)serialize the variants of a complex enum.
+// The container is non-exported, so it's declared in every package which uses the enum.
+func GenerateComplexEnumContainerCode(ctx *model.GenerateCtx, enumTypeName string, enumDef *idl.IdlTypeDefTyEnum) Code {
+	return Type().Id(GetEnumVariantsContainerName(enumTypeName)).StructFunc(
+		func(structGroup *Group) {
+			structGroup.Id("Enum").Qual(model.PkgDfuseBinary, "BorshEnum").Tag(map[string]string{
+				"borsh_enum": "true",
+			})
+
+			for _, variant := range enumDef.Variants {
+				variantTypeName := GetComplexEnumVariantTypeName(enumTypeName, variant.Name)
+				structGroup.Id(helper.ToCamelCase(variant.Name)).Add(idlcode.DefinedTypeIdentCode(ctx, enumTypeName, variantTypeName))
+			}
+		},
+	)
+}
+
+func generateStructTypeDefCode(ctx *model.GenerateCtx, idlName string, exportedStructName string, structDef *idl.IdlTypeDefTyStruct, anchorDiscriminator []byte, layout *model.Layout, customType string, program *idl.Idl) Code {
 	var structFields []idl.IdlField
-	code := Type().Id(exportedStructName).StructFunc(func(fieldsGroup *Group) {
+	code := Empty()
+	if layout != nil {
+		code.Commentf("%s is a zero-copy type, it's stored in %d bytes with the C layout.", exportedStructName, layout.Size).Line()
+	}
+	code.Type().Id(exportedStructName).StructFunc(func(fieldsGroup *Group) {
 		switch {
 		case structDef.Fields.IsNamed():
 			namedFileds := structDef.Fields.GetNamed().Fields
@@ -212,7 +230,7 @@ func generateStructTypeDefCode(ctx *model.GenerateCtx, exportedStructName string
 					}
 					fieldsGroup.Comment(doc)
 				}
-				fieldsGroup.Add(idlcode.IdlFieldToCode(field, idlcode.FieldCodeOption{
+				fieldsGroup.Add(idlcode.IdlFieldToCode(ctx, field, idlcode.FieldCodeOption{
 					AsPointer:   field.Type.IsOption(),
 					ComplexEnum: ctx.IsComplexEnumByType(&field.Type),
 				}))
@@ -234,7 +252,7 @@ func generateStructTypeDefCode(ctx *model.GenerateCtx, exportedStructName string
 					}
 					fieldsGroup.Comment(doc)
 				}
-				fieldsGroup.Add(idlcode.IdlFieldToCode(named, idlcode.FieldCodeOption{
+				fieldsGroup.Add(idlcode.IdlFieldToCode(ctx, named, idlcode.FieldCodeOption{
 					AsPointer:   typ.IsOption(),
 					ComplexEnum: ctx.IsComplexEnumByType(&typ),
 				}))
@@ -242,31 +260,35 @@ func generateStructTypeDefCode(ctx *model.GenerateCtx, exportedStructName string
 		}
 	}).Line()
 
-	if ctx.Encoder != model.EncoderTypeBorsh {
-		return code
-	}
-
-	// generate encoder and decoder methods (for borsh):
+	// generate encoder and decoder methods, they are used by every encoder:
 	var discriminatorName *string
-	var structDiscriminator *ag_binary.TypeID
 
 	if anchorDiscriminator != nil {
-		typeId := ag_binary.TypeID(*anchorDiscriminator)
-
-		structDiscriminator = &typeId
 		discriminatorName = helper.StrPtr(GetDiscriminatorName(exportedStructName))
 
-		code.Var().Id(*discriminatorName).Op("=").Index(Lit(8)).Byte().Op("{").ListFunc(func(byteGroup *Group) {
-			for _, byteVal := range structDiscriminator[:] {
+		// The discriminators are 8 bytes by default, but they can be customized with any length.
+		code.Var().Id(*discriminatorName).Op("=").Index(Lit(len(anchorDiscriminator))).Byte().Op("{").ListFunc(func(byteGroup *Group) {
+			for _, byteVal := range anchorDiscriminator {
 				byteGroup.Lit(int(byteVal))
 			}
 		}).Op("}")
 	}
 
+	if customType != "" {
+		code.Line().Line().Add(generateCustomCodecCode(ctx, exportedStructName, customType, discriminatorName, anchorDiscriminator))
+		return code
+	}
+	if layout != nil {
+		code.Line().Line().Add(GenerateMarshalWithEncoderForLayout(exportedStructName, structFields, layout, discriminatorName))
+		code.Line().Line().Add(GenerateUnmarshalWithDecoderForLayout(exportedStructName, structFields, layout, discriminatorName, anchorDiscriminator))
+		return code
+	}
+
 	code.Line().Line().Add(
 		GenerateMarshalWithEncoderForStruct(
 			ctx,
 			exportedStructName,
+			idlName,
 			structFields,
 			discriminatorName,
 			true,
@@ -277,9 +299,10 @@ func generateStructTypeDefCode(ctx *model.GenerateCtx, exportedStructName string
 		GenerateUnmarshalWithDecoderForStruct(
 			ctx,
 			exportedStructName,
+			idlName,
 			structFields,
 			discriminatorName,
-			structDiscriminator,
+			anchorDiscriminator,
 			program,
 		),
 	)
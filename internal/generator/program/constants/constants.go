@@ -1,123 +1,419 @@
 package constants
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"math/big"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/idlcode"
 	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/generator/program/common"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
-	"github.com/davecgh/go-spew/spew"
+	"github.com/gagliardetto/solana-go"
 )
 
+// GenerateConstants declares the constants of the program, as `const` if Go allows it, otherwise as `var`.
+// The constants which can't be represented in Go (e.g. the values which are not literals) are skipped with a warning.
 func GenerateConstants(ctx *model.GenerateCtx, program *idl.Idl) *File {
 	file := helper.NewGoFile(ctx)
-	for _, c := range program.Constants {
-		code := Commentf("constant %s: %s", c.Type, c.Value).Line()
+	for i, c := range program.Constants {
+		code, err := constantCode(ctx, program, c)
+		if err != nil {
+			ctx.Warnf(fmt.Sprintf("constants[%d]", i), "constant %s is skipped: %s", c.Name, err)
+			continue
+		}
+		file.Line().Add(Commentf("constant %s: %s", constantTypeString(c.Type), c.Value).Line().Add(code))
+	}
+
+	return file
+}
+
+func constantTypeString(typ idl.IdlType) string {
+	if typ.IsSimple() {
+		return typ.GetSimple().String()
+	}
+	data, err := json.Marshal(typ)
+	if err != nil {
+		return "unknown"
+	}
+	return string(data)
+}
+
+func constantCode(ctx *model.GenerateCtx, program *idl.Idl, c idl.IdlConst) (Code, error) {
+	if c.Type.IsHashMap() {
+		return nil, errors.New("hash map constants are not supported")
+	}
+	if c.Type.IsHashSet() {
+		return nil, errors.New("hash set constants are not supported")
+	}
+	lit, err := parseLiteral(c.Value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value: %w", err)
+	}
+	name := fmt.Sprintf("CONST_%s", c.Name)
+	gen := &valueGenerator{ctx: ctx, program: program}
 
-		code.Var().Id(fmt.Sprintf("CONST_%s", c.Name)).Op("=")
-		if !c.Type.IsSimple() {
-			panic(fmt.Sprintf("unsupported constant type: %s", spew.Sdump(c)))
+	if c.Type.IsSimple() && (c.Type.GetSimple() == idl.IdlTypeSimpleU128 || c.Type.GetSimple() == idl.IdlTypeSimpleI128) {
+		// The 128-bit constants are big integers, which are easier to use than `Uint128` and `Int128`.
+		value, err := gen.bigIntCode(c.Type.GetSimple(), lit)
+		if err != nil {
+			return nil, err
 		}
+		return Var().Id(name).Op("=").Add(value), nil
+	}
 
-		simpleTyp := c.Type.GetSimple()
+	value, err := gen.valueCode(c.Type, lit)
+	if err != nil {
+		return nil, err
+	}
+	if constType := gen.constTypeCode(c.Type); constType != nil {
+		return Const().Id(name).Add(constType).Op("=").Add(value), nil
+	}
+	return Var().Id(name).Op("=").Add(value), nil
+}
 
-		switch simpleTyp {
-		case idl.IdlTypeSimpleString:
-			v, err := strconv.Unquote(c.Value)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleBool:
-			v, err := strconv.ParseBool(c.Value)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleU8:
-			v, err := strconv.ParseUint(c.Value, 10, 8)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleI8:
-			v, err := strconv.ParseInt(c.Value, 10, 8)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleU16:
-			v, err := strconv.ParseUint(c.Value, 10, 16)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleI16:
-			v, err := strconv.ParseInt(c.Value, 10, 16)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
-			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleU32:
-			v, err := strconv.ParseUint(c.Value, 10, 32)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+type valueGenerator struct {
+	ctx     *model.GenerateCtx
+	program *idl.Idl
+}
+
+// constTypeCode returns the type of the constant if it can be declared as `const` in Go, otherwise nil.
+func (g *valueGenerator) constTypeCode(typ idl.IdlType) Code {
+	switch {
+	case typ.IsSimple():
+		switch typ.GetSimple() {
+		case idl.IdlTypeSimpleBool, idl.IdlTypeSimpleString, idl.IdlTypeSimpleF32, idl.IdlTypeSimpleF64,
+			idl.IdlTypeSimpleU8, idl.IdlTypeSimpleI8, idl.IdlTypeSimpleU16, idl.IdlTypeSimpleI16,
+			idl.IdlTypeSimpleU32, idl.IdlTypeSimpleI32, idl.IdlTypeSimpleU64, idl.IdlTypeSimpleI64:
+			return idlcode.IdlTypeSimpleToCode(typ.GetSimple())
+		}
+	case typ.IsDefined():
+		name := typ.GetDefined().Name
+		def := g.program.FindTypeByName(name)
+		if _, overridden := g.ctx.GetTypeOverride(name); overridden || def == nil {
+			return nil
+		}
+		switch {
+		case def.Type.IsEnum() && def.Type.GetEnum().IsUint8Enum() && !g.ctx.IsComplexEnumByTypeName(name):
+			return idlcode.DefinedTypeIdentCode(g.ctx, name, name)
+		case def.Type.IsType() && g.constTypeCode(def.Type.GetType().Alias) != nil:
+			return idlcode.DefinedTypeIdentCode(g.ctx, name, name)
+		}
+	}
+	return nil
+}
+
+// valueCode returns the Go expression of the value, the numbers are untyped since the type is given by the context.
+func (g *valueGenerator) valueCode(typ idl.IdlType, lit *literal) (Code, error) {
+	switch {
+	case typ.IsSimple():
+		return g.simpleValueCode(typ.GetSimple(), lit)
+	case typ.IsOption():
+		return g.optionValueCode(typ, lit)
+	case typ.IsArray():
+		arrayLen := typ.GetArray().Len
+		var length uint
+		switch {
+		case arrayLen.IsValue():
+			length = arrayLen.GetValue().Value
+		case arrayLen.IsGeneric():
+			var ok bool
+			if length, ok = g.ctx.GetArrayLen(arrayLen.GetGeneric().Value); !ok {
+				return nil, fmt.Errorf("unresolved array length %s", arrayLen.GetGeneric().Value)
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleI32:
-			v, err := strconv.ParseInt(c.Value, 10, 32)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+		}
+		items, err := g.itemsCode(typ.GetArray().Elem, lit)
+		if err != nil {
+			return nil, err
+		}
+		if uint(len(items)) != length {
+			return nil, fmt.Errorf("the array has %d items, wanted %d", len(items), length)
+		}
+		return Add(idlcode.IdlTypeToCode(g.ctx, typ)).Values(items...), nil
+	case typ.IsVec():
+		items, err := g.itemsCode(typ.GetVec().Vec, lit)
+		if err != nil {
+			return nil, err
+		}
+		return Add(idlcode.IdlTypeToCode(g.ctx, typ)).Values(items...), nil
+	case typ.IsDefined():
+		return g.definedValueCode(typ.GetDefined().Name, lit)
+	case typ.IsHashMap():
+		return nil, errors.New("hash map constants are not supported")
+	case typ.IsHashSet():
+		return nil, errors.New("hash set constants are not supported")
+	default:
+		return nil, errors.New("unsupported constant type")
+	}
+}
+
+// optionValueCode returns a pointer, which is how the options are declared in the structs.
+func (g *valueGenerator) optionValueCode(typ idl.IdlType, lit *literal) (Code, error) {
+	inner := typ.GetOption().Option
+	if lit.kind == literalPath && lastPathSegment(lit.text) == "None" {
+		return Parens(Op("*").Add(idlcode.IdlTypeToCode(g.ctx, inner))).Parens(Nil()), nil
+	}
+	if lit.kind != literalTuple || lastPathSegment(lit.path) != "Some" || len(lit.items) != 1 {
+		return nil, errors.New("the value of an option must be `None` or `Some(...)`")
+	}
+	value, err := g.valueCode(inner, lit.items[0])
+	if err != nil {
+		return nil, err
+	}
+	return Func().Params().Op("*").Add(idlcode.IdlTypeToCode(g.ctx, inner)).Block(
+		Var().Id("value").Add(idlcode.IdlTypeToCode(g.ctx, inner)).Op("=").Add(value),
+		Return(Op("&").Id("value")),
+	).Call(), nil
+}
+
+// itemsCode returns the items of an array or a vec, given by a list, a repetition or a byte string.
+func (g *valueGenerator) itemsCode(elem idl.IdlType, lit *literal) ([]Code, error) {
+	if elem.IsOption() {
+		return nil, errors.New("the options in arrays or vecs can't be represented, they are not pointers in Go")
+	}
+
+	var items []*literal
+	switch lit.kind {
+	case literalList:
+		items = lit.items
+	case literalRepeat:
+		count, err := helper.ParseRustUint(lit.items[1].text)
+		if lit.items[1].kind != literalNumber || err != nil {
+			return nil, fmt.Errorf("invalid repetition count %q", lit.items[1].text)
+		}
+		items = slices.Repeat([]*literal{lit.items[0]}, int(count))
+	case literalByteString:
+		if !elem.IsSimple() || elem.GetSimple() != idl.IdlTypeSimpleU8 {
+			return nil, errors.New("a byte string can only be the value of bytes")
+		}
+		for _, b := range []byte(lit.text) {
+			items = append(items, &literal{kind: literalNumber, text: strconv.Itoa(int(b))})
+		}
+	default:
+		return nil, errors.New("the value must be a list")
+	}
+
+	codes := make([]Code, 0, len(items))
+	for i, item := range items {
+		code, err := g.valueCode(elem, item)
+		if err != nil {
+			return nil, fmt.Errorf("item %d: %w", i, err)
+		}
+		codes = append(codes, code)
+	}
+	return codes, nil
+}
+
+func (g *valueGenerator) simpleValueCode(simple idl.IdlTypeSimple, lit *literal) (Code, error) {
+	switch simple {
+	case idl.IdlTypeSimpleBool:
+		if lit.kind == literalPath && (lit.text == "true" || lit.text == "false") {
+			return Lit(lit.text == "true"), nil
+		}
+		return nil, errors.New("the value of bool must be `true` or `false`")
+	case idl.IdlTypeSimpleU8, idl.IdlTypeSimpleI8, idl.IdlTypeSimpleU16, idl.IdlTypeSimpleI16,
+		idl.IdlTypeSimpleU32, idl.IdlTypeSimpleI32, idl.IdlTypeSimpleU64, idl.IdlTypeSimpleI64:
+		value, err := parseInteger(simple, lit)
+		if err != nil {
+			return nil, err
+		}
+		return Op(value.String()), nil
+	case idl.IdlTypeSimpleU128, idl.IdlTypeSimpleI128:
+		value, err := parseInteger(simple, lit)
+		if err != nil {
+			return nil, err
+		}
+		// Two's complement of the signed values.
+		unsigned := new(big.Int).And(value, new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1)))
+		lo := new(big.Int).And(unsigned, new(big.Int).SetUint64(^uint64(0))).Uint64()
+		hi := new(big.Int).Rsh(unsigned, 64).Uint64()
+		return Add(idlcode.IdlTypeSimpleToCode(simple)).Values(Dict{
+			Id("Lo"): Op(strconv.FormatUint(lo, 10)),
+			Id("Hi"): Op(strconv.FormatUint(hi, 10)),
+		}), nil
+	case idl.IdlTypeSimpleU256, idl.IdlTypeSimpleI256:
+		value, err := parseInteger(simple, lit)
+		if err != nil {
+			return nil, err
+		}
+		bytes := int256Bytes(value, simple == idl.IdlTypeSimpleI256)
+		return Add(idlcode.IdlTypeSimpleToCode(simple)).Values(idlcode.IdlBytesToValuesCode(bytes)...), nil
+	case idl.IdlTypeSimpleF32, idl.IdlTypeSimpleF64:
+		bits := 64
+		if simple == idl.IdlTypeSimpleF32 {
+			bits = 32
+		}
+		if lit.kind != literalNumber {
+			return nil, fmt.Errorf("the value of %s must be a number", simple)
+		}
+		text := strings.TrimSuffix(strings.TrimSuffix(lit.text, "f32"), "f64")
+		value, err := strconv.ParseFloat(text, bits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s %q", simple, lit.text)
+		}
+		return Op(strconv.FormatFloat(value, 'g', -1, bits)), nil
+	case idl.IdlTypeSimpleString:
+		if lit.kind != literalString {
+			return nil, errors.New("the value of string must be a string literal")
+		}
+		return Lit(lit.text), nil
+	case idl.IdlTypeSimpleBytes:
+		elem := idl.IdlTypeSimpleU8
+		items, err := g.itemsCode(idl.IdlType{IdlTypeSimple: &elem}, lit)
+		if err != nil {
+			return nil, err
+		}
+		return Index().Byte().Values(items...), nil
+	case idl.IdlTypeSimplePubkey:
+		address := lit.text
+		if lit.kind == literalTuple && len(lit.items) == 1 && strings.HasSuffix(lit.path, "pubkey!") {
+			// `pubkey!("...")` of solana-program.
+			address = lit.items[0].text
+		} else if lit.kind != literalString && lit.kind != literalPath && lit.kind != literalNumber {
+			return nil, errors.New("the value of pubkey must be a base58 address")
+		}
+		if _, err := solana.PublicKeyFromBase58(address); err != nil {
+			return nil, fmt.Errorf("invalid pubkey %q: %w", address, err)
+		}
+		return Qual(model.PkgSolanaGo, "MustPublicKeyFromBase58").Call(Lit(address)), nil
+	default:
+		return nil, fmt.Errorf("unsupported constant type: %s", simple)
+	}
+}
+
+// bigIntCode returns the value as `*big.Int`.
+func (g *valueGenerator) bigIntCode(simple idl.IdlTypeSimple, lit *literal) (Code, error) {
+	value, err := parseInteger(simple, lit)
+	if err != nil {
+		return nil, err
+	}
+	code := Qual(model.PkgBigInt, "NewInt").Call(Lit(0)).
+		Dot("SetBytes").
+		Call(
+			Index().Byte().
+				Values(idlcode.IdlBytesToValuesCode(new(big.Int).Abs(value).Bytes())...),
+		)
+	if value.Sign() < 0 {
+		return Qual(model.PkgBigInt, "NewInt").Call(Lit(0)).Dot("Neg").Call(code), nil
+	}
+	return code, nil
+}
+
+func (g *valueGenerator) definedValueCode(name string, lit *literal) (Code, error) {
+	if _, overridden := g.ctx.GetTypeOverride(name); overridden {
+		return nil, fmt.Errorf("type %s is overridden by the config", name)
+	}
+	def := g.program.FindTypeByName(name)
+	if def == nil {
+		return nil, fmt.Errorf("type %s is not found", name)
+	}
+	typeCode := func() *Statement { return idlcode.DefinedTypeIdentCode(g.ctx, name, name) }
+
+	switch {
+	case def.Type.IsType():
+		return g.valueCode(def.Type.GetType().Alias, lit)
+	case def.Type.IsEnum():
+		if g.ctx.IsComplexEnumByTypeName(name) {
+			return nil, fmt.Errorf("enum %s has variants with fields, which are not supported in constants", name)
+		}
+		variantName := lastPathSegment(lit.text)
+		if lit.kind != literalPath || !slices.ContainsFunc(def.Type.GetEnum().Variants, func(variant idl.IdlEnumVariant) bool {
+			return variant.Name == variantName
+		}) {
+			return nil, fmt.Errorf("the value must be a variant of enum %s", name)
+		}
+		return idlcode.DefinedTypeIdentCode(g.ctx, name, common.GetSimpleEnumVariantName(name, variantName)), nil
+	case def.Type.IsStruct():
+		fields := def.Type.GetStruct().Fields
+		switch {
+		case fields == nil || (!fields.IsNamed() && !fields.IsTuple()):
+			if lit.kind != literalPath {
+				return nil, fmt.Errorf("the value of unit struct %s must be its name", name)
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleU64:
-			v, err := strconv.ParseUint(c.Value, 10, 64)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+			return typeCode().Values(), nil
+		case fields.IsNamed():
+			if lit.kind != literalStruct {
+				return nil, fmt.Errorf("the value of struct %s must be `%s { ... }`", name, name)
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleI64:
-			v, err := strconv.ParseInt(c.Value, 10, 64)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+			values := Dict{}
+			for _, field := range fields.GetNamed().Fields {
+				index := slices.IndexFunc(lit.fields, func(item literalField) bool { return item.name == field.Name })
+				if index < 0 {
+					return nil, fmt.Errorf("missing field %s of struct %s", field.Name, name)
+				}
+				value, err := g.valueCode(field.Type, lit.fields[index].value)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", field.Name, err)
+				}
+				values[Id(helper.ToCamelCase(field.Name))] = value
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleF32:
-			v, err := strconv.ParseFloat(c.Value, 32)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+			if len(lit.fields) != len(fields.GetNamed().Fields) {
+				return nil, fmt.Errorf("unknown fields of struct %s", name)
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimpleF64:
-			v, err := strconv.ParseFloat(c.Value, 64)
-			if err != nil {
-				panic(fmt.Sprintf("failed to parse constant: %s", spew.Sdump(c)))
+			return typeCode().Values(values), nil
+		default:
+			types := fields.GetTuple().Types
+			if lit.kind != literalTuple || len(lit.items) != len(types) {
+				return nil, fmt.Errorf("the value of struct %s must be `%s(...)` with %d items", name, name, len(types))
 			}
-			code.Lit(v)
-		case idl.IdlTypeSimplePubkey:
-			code.Qual(model.PkgSolanaGo, "MustPublicKeyFromBase58").Call(Lit(c.Value))
-		case idl.IdlTypeSimpleBytes:
-			values := helper.BytesStrToBytes(c.Value)
-			code.Index().Byte().Values(idlcode.IdlBytesToValuesCode(values)...)
-		case idl.IdlTypeSimpleU128:
-			val, ok := big.NewInt(0).SetString(c.Value, 10)
-			if !ok {
-				panic(fmt.Sprintf("failed to parse u128 constant: %s", spew.Sdump(c)))
+			values := Dict{}
+			for i, typ := range types {
+				value, err := g.valueCode(typ, lit.items[i])
+				if err != nil {
+					return nil, fmt.Errorf("item %d: %w", i, err)
+				}
+				values[Id(helper.ToCamelCase(common.GetTupleStructElementName(i)))] = value
 			}
-			code.Qual(model.PkgBigInt, "NewInt").Call(Lit(0)).
-				Dot("SetBytes").
-				Call(
-					Index().Byte().
-						Values(idlcode.IdlBytesToValuesCode(val.Bytes())...),
-				)
+			return typeCode().Values(values), nil
 		}
+	default:
+		return nil, fmt.Errorf("missing type definition of %s", name)
+	}
+}
 
-		file.Line().Add(code)
+// parseInteger parses the integer and checks it fits in the type.
+func parseInteger(simple idl.IdlTypeSimple, lit *literal) (*big.Int, error) {
+	if lit.kind != literalNumber {
+		return nil, fmt.Errorf("the value of %s must be an integer", simple)
+	}
+	value, err := helper.ParseRustInt(lit.text)
+	if err != nil {
+		return nil, err
 	}
+	signed := strings.HasPrefix(simple.String(), "i")
+	bits, _ := strconv.Atoi(simple.String()[1:])
+	if signed {
+		bits--
+	}
+	limit := new(big.Int).Lsh(big.NewInt(1), uint(bits))
+	if value.Cmp(limit) >= 0 || (!signed && value.Sign() < 0) || (signed && value.Cmp(new(big.Int).Neg(limit)) < 0) {
+		return nil, fmt.Errorf("%s overflows %s", lit.text, simple)
+	}
+	return value, nil
+}
 
-	return file
+// lastPathSegment returns the name at the end of the path, e.g. `A` of `Kind::A`.
+func lastPathSegment(path string) string {
+	if index := strings.LastIndex(path, "::"); index >= 0 {
+		return path[index+2:]
+	}
+	return path
+}
+
+// int256Bytes returns the 32 little-endian bytes of the value, in two's complement if signed.
+// The value must fit in 256 bits.
+func int256Bytes(val *big.Int, signed bool) []byte {
+	unsigned := new(big.Int).Set(val)
+	if signed && unsigned.Sign() < 0 {
+		unsigned.Add(unsigned, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	bytes := unsigned.FillBytes(make([]byte, 32))
+	slices.Reverse(bytes)
+	return bytes
 }
@@ -0,0 +1,83 @@
+package constants
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+func TestSimpleValueCode(t *testing.T) {
+	tests := []struct {
+		typ      idl.IdlTypeSimple
+		value    string
+		expected string
+		err      string
+	}{
+		{typ: idl.IdlTypeSimpleBool, value: `true`, expected: `true`},
+		{typ: idl.IdlTypeSimpleBool, value: `1`, err: "the value of bool must be `true` or `false`"},
+
+		// Integers of any radix, with underscores and suffixes, in the range of the type.
+		{typ: idl.IdlTypeSimpleU8, value: `255u8`, expected: `255`},
+		{typ: idl.IdlTypeSimpleU8, value: `0xff`, expected: `255`},
+		{typ: idl.IdlTypeSimpleU8, value: `b'A'`, expected: `65`},
+		{typ: idl.IdlTypeSimpleU8, value: `256`, err: `256 overflows u8`},
+		{typ: idl.IdlTypeSimpleU8, value: `-1`, err: `-1 overflows u8`},
+		{typ: idl.IdlTypeSimpleI8, value: `-128i8`, expected: `-128`},
+		{typ: idl.IdlTypeSimpleI8, value: `-129`, err: `-129 overflows i8`},
+		{typ: idl.IdlTypeSimpleI8, value: `128`, err: `128 overflows i8`},
+		{typ: idl.IdlTypeSimpleU16, value: `0o17_7777`, expected: `65535`},
+		{typ: idl.IdlTypeSimpleU32, value: `0b1000_0000`, expected: `128`},
+		{typ: idl.IdlTypeSimpleU64, value: `18_446_744_073_709_551_615`, expected: `18446744073709551615`},
+		{typ: idl.IdlTypeSimpleU64, value: `18446744073709551616`, err: `18446744073709551616 overflows u64`},
+		{typ: idl.IdlTypeSimpleI64, value: `-9223372036854775808`, expected: `-9223372036854775808`},
+		{typ: idl.IdlTypeSimpleU64, value: `1.5`, err: `invalid integer "1.5"`},
+		{typ: idl.IdlTypeSimpleU64, value: `0x`, err: `invalid integer "0x"`},
+		{typ: idl.IdlTypeSimpleU64, value: `0xfg`, err: `invalid integer "0xfg"`},
+		{typ: idl.IdlTypeSimpleU64, value: `"1"`, err: `the value of u64 must be an integer`},
+		{typ: idl.IdlTypeSimpleU128, value: `0x1_0000_0000_0000_0002u128`, expected: "binary.Uint128{\n\tHi: 1,\n\tLo: 2,\n}"},
+		{typ: idl.IdlTypeSimpleI128, value: `-1`, expected: "binary.Int128{\n\tHi: 18446744073709551615,\n\tLo: 18446744073709551615,\n}"},
+
+		// Floats.
+		{typ: idl.IdlTypeSimpleF32, value: `2.5f32`, expected: `2.5`},
+		{typ: idl.IdlTypeSimpleF64, value: `1e-3`, expected: `0.001`},
+		{typ: idl.IdlTypeSimpleF64, value: `-6.02E+23f64`, expected: `-6.02e+23`},
+		{typ: idl.IdlTypeSimpleF64, value: `1.2.3`, err: `invalid f64 "1.2.3"`},
+		{typ: idl.IdlTypeSimpleF32, value: `true`, err: `the value of f32 must be a number`},
+
+		// Strings and bytes.
+		{typ: idl.IdlTypeSimpleString, value: `"vault\n"`, expected: `"vault\n"`},
+		{typ: idl.IdlTypeSimpleString, value: `b"vault"`, err: `the value of string must be a string literal`},
+		{typ: idl.IdlTypeSimpleBytes, value: `b"ab"`, expected: `[]byte{97, 98}`},
+		{typ: idl.IdlTypeSimpleBytes, value: `[1, 0x2]`, expected: `[]byte{1, 2}`},
+		{typ: idl.IdlTypeSimpleBytes, value: `[0; 2]`, expected: `[]byte{0, 0}`},
+		{typ: idl.IdlTypeSimpleBytes, value: `[256]`, err: `item 0: 256 overflows u8`},
+
+		// Pubkeys.
+		{typ: idl.IdlTypeSimplePubkey, value: `"11111111111111111111111111111111"`, expected: `solanago.MustPublicKeyFromBase58("11111111111111111111111111111111")`},
+		{typ: idl.IdlTypeSimplePubkey, value: `pubkey!("11111111111111111111111111111111")`, expected: `solanago.MustPublicKeyFromBase58("11111111111111111111111111111111")`},
+		{typ: idl.IdlTypeSimplePubkey, value: `"0OIl"`, err: `invalid pubkey "0OIl": decode: invalid base58 digit ('0')`},
+		{typ: idl.IdlTypeSimplePubkey, value: `[1, 2]`, err: `the value of pubkey must be a base58 address`},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s %s", tt.typ, tt.value), func(t *testing.T) {
+			lit, err := parseLiteral(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, err := (&valueGenerator{}).simpleValueCode(tt.typ, lit)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Errorf("got error %v, expected %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := fmt.Sprintf("%#v", code); got != tt.expected {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
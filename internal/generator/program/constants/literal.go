@@ -0,0 +1,396 @@
+package constants
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// The values of the constants are Rust expressions, either written in the source (e.g. `b"vault"`, `1_000u64`)
+// or printed by `Debug` (e.g. `[118, 97]`, `Some(1)`, `Point { x: 1, y: 2 }`).
+// Only the literals are parsed, the other expressions (e.g. `1 << 8`, `u64::MAX`) can't be represented.
+
+type literalKind int
+
+const (
+	// A number, `text` is the literal without the underscores, e.g. `-0x10u8`.
+	literalNumber literalKind = iota
+	// A string or a byte string, `text` is the unescaped content.
+	literalString
+	literalByteString
+	// A path, e.g. `true`, `None` or `Kind::A`.
+	literalPath
+	// `[a, b]`, `vec![a, b]` or `&[a, b]`.
+	literalList
+	// `[value; count]`, the count is the second item.
+	literalRepeat
+	// `Name(a, b)` (with a path) or `(a, b)`.
+	literalTuple
+	// `Name { a: 1, b: 2 }`.
+	literalStruct
+)
+
+type literal struct {
+	kind literalKind
+	text string
+	// The path of the tuples, the structs or a macro (e.g. `pubkey!`).
+	path   string
+	items  []*literal
+	fields []literalField
+}
+
+type literalField struct {
+	name  string
+	value *literal
+}
+
+// parseLiteral parses the Rust expression of the value of a constant.
+func parseLiteral(value string) (*literal, error) {
+	p := &literalParser{src: value}
+	lit, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return nil, fmt.Errorf("unexpected %q after the value", p.src[p.pos:])
+	}
+	return lit, nil
+}
+
+type literalParser struct {
+	src string
+	pos int
+}
+
+func (p *literalParser) skipSpaces() {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *literalParser) peek() byte {
+	p.skipSpaces()
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *literalParser) consume(token string) bool {
+	p.skipSpaces()
+	if strings.HasPrefix(p.src[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *literalParser) expect(token string) error {
+	if !p.consume(token) {
+		return p.unexpected(fmt.Sprintf("%q", token))
+	}
+	return nil
+}
+
+func (p *literalParser) unexpected(wanted string) error {
+	if p.pos >= len(p.src) {
+		return fmt.Errorf("unexpected end of value, wanted %s", wanted)
+	}
+	return fmt.Errorf("unexpected %q at offset %d, wanted %s", p.src[p.pos:p.pos+1], p.pos, wanted)
+}
+
+func (p *literalParser) parseValue() (*literal, error) {
+	switch c := p.peek(); {
+	case c == '&':
+		// References don't matter for the values, e.g. `&[1, 2]` or `&"seed"`.
+		p.pos++
+		return p.parseValue()
+	case c == '"':
+		text, err := p.parseString(false)
+		return &literal{kind: literalString, text: text}, err
+	case c == 'b' && strings.HasPrefix(p.src[p.pos:], `b"`), c == 'b' && strings.HasPrefix(p.src[p.pos:], `br`):
+		p.pos++
+		text, err := p.parseString(true)
+		return &literal{kind: literalByteString, text: text}, err
+	case c == 'b' && strings.HasPrefix(p.src[p.pos:], `b'`):
+		p.pos++
+		char, err := p.parseChar()
+		return &literal{kind: literalNumber, text: strconv.Itoa(int(char))}, err
+	case c == 'r' && (strings.HasPrefix(p.src[p.pos:], `r"`) || strings.HasPrefix(p.src[p.pos:], `r#`)):
+		text, err := p.parseString(false)
+		return &literal{kind: literalString, text: text}, err
+	case c == '-' || c >= '0' && c <= '9':
+		return p.parseNumber()
+	case c == '[':
+		p.pos++
+		return p.parseList("]")
+	case c == '(':
+		p.pos++
+		items, err := p.parseItems(")")
+		return &literal{kind: literalTuple, items: items}, err
+	case c == '_' || unicode.IsLetter(rune(c)):
+		return p.parsePathValue()
+	default:
+		return nil, p.unexpected("a value")
+	}
+}
+
+func (p *literalParser) parseNumber() (*literal, error) {
+	start := p.pos
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		isExponentSign := (c == '-' || c == '+') && p.pos > start && (p.src[p.pos-1] == 'e' || p.src[p.pos-1] == 'E') &&
+			!strings.HasPrefix(strings.TrimPrefix(p.src[start:], "-"), "0x")
+		if !isExponentSign && c != '.' && c != '_' && !unicode.IsLetter(rune(c)) && !unicode.IsDigit(rune(c)) {
+			break
+		}
+		p.pos++
+	}
+	text := strings.ReplaceAll(p.src[start:p.pos], "_", "")
+	if text == "-" || text == "" {
+		return nil, p.unexpected("a number")
+	}
+	return &literal{kind: literalNumber, text: text}, nil
+}
+
+func (p *literalParser) parseIdent() string {
+	start := p.pos
+	for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+		p.pos++
+	}
+	return p.src[start:p.pos]
+}
+
+func (p *literalParser) parsePathValue() (*literal, error) {
+	p.skipSpaces()
+	path := p.parseIdent()
+	for p.consume("::") {
+		p.skipSpaces()
+		path += "::" + p.parseIdent()
+	}
+
+	switch {
+	case p.consume("!"):
+		// Macros: `vec![1, 2]`, `pubkey!("...")`.
+		var lit *literal
+		var err error
+		switch {
+		case p.consume("["):
+			lit, err = p.parseList("]")
+		case p.consume("("):
+			lit = &literal{kind: literalTuple}
+			lit.items, err = p.parseItems(")")
+		default:
+			return nil, p.unexpected(`"[" or "("`)
+		}
+		if lit != nil {
+			lit.path = path + "!"
+		}
+		return lit, err
+	case p.peek() == '(':
+		p.pos++
+		items, err := p.parseItems(")")
+		return &literal{kind: literalTuple, path: path, items: items}, err
+	case p.peek() == '{':
+		p.pos++
+		lit := &literal{kind: literalStruct, path: path}
+		for !p.consume("}") {
+			p.skipSpaces()
+			name := p.parseIdent()
+			if name == "" {
+				return nil, p.unexpected("a field name")
+			}
+			if err := p.expect(":"); err != nil {
+				return nil, err
+			}
+			value, err := p.parseValue()
+			if err != nil {
+				return nil, err
+			}
+			lit.fields = append(lit.fields, literalField{name: name, value: value})
+			if !p.consume(",") && p.peek() != '}' {
+				return nil, p.unexpected(`"," or "}"`)
+			}
+		}
+		return lit, nil
+	default:
+		return &literal{kind: literalPath, text: path}, nil
+	}
+}
+
+// parseList parses the items of a list after the opening bracket, e.g. `1, 2]` or `0; 32]`.
+func (p *literalParser) parseList(closing string) (*literal, error) {
+	if p.consume(closing) {
+		return &literal{kind: literalList}, nil
+	}
+	first, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	if p.consume(";") {
+		count, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(closing); err != nil {
+			return nil, err
+		}
+		return &literal{kind: literalRepeat, items: []*literal{first, count}}, nil
+	}
+	items := []*literal{first}
+	if p.consume(",") {
+		rest, err := p.parseItems(closing)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, rest...)
+	} else if err := p.expect(closing); err != nil {
+		return nil, err
+	}
+	return &literal{kind: literalList, items: items}, nil
+}
+
+// parseItems parses the comma separated values until the closing token, a trailing comma is allowed.
+func (p *literalParser) parseItems(closing string) ([]*literal, error) {
+	var items []*literal
+	for !p.consume(closing) {
+		item, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if !p.consume(",") {
+			return items, p.expect(closing)
+		}
+	}
+	return items, nil
+}
+
+// parseString parses a (raw) string literal, the byte strings can only contain the ASCII characters and byte escapes.
+func (p *literalParser) parseString(bytes bool) (string, error) {
+	if p.consume("r") {
+		hashes := 0
+		for p.consume("#") {
+			hashes++
+		}
+		if err := p.expect(`"`); err != nil {
+			return "", err
+		}
+		terminator := `"` + strings.Repeat("#", hashes)
+		end := strings.Index(p.src[p.pos:], terminator)
+		if end < 0 {
+			return "", fmt.Errorf("unterminated raw string")
+		}
+		text := p.src[p.pos : p.pos+end]
+		p.pos += end + len(terminator)
+		return text, nil
+	}
+
+	if err := p.expect(`"`); err != nil {
+		return "", err
+	}
+	var out strings.Builder
+	for {
+		if p.pos >= len(p.src) {
+			return "", fmt.Errorf("unterminated string")
+		}
+		c := p.src[p.pos]
+		switch c {
+		case '"':
+			p.pos++
+			return out.String(), nil
+		case '\\':
+			if strings.HasPrefix(p.src[p.pos:], "\\\n") {
+				// A line continuation skips the following whitespaces.
+				p.pos += 2
+				p.skipSpaces()
+				continue
+			}
+			r, err := p.parseEscape(bytes)
+			if err != nil {
+				return "", err
+			}
+			if bytes {
+				out.WriteByte(byte(r))
+			} else {
+				out.WriteRune(r)
+			}
+		default:
+			r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+			if bytes && r >= utf8.RuneSelf {
+				return "", fmt.Errorf("non-ASCII character %q in byte string", r)
+			}
+			out.WriteRune(r)
+			p.pos += size
+		}
+	}
+}
+
+func (p *literalParser) parseChar() (rune, error) {
+	if err := p.expect("'"); err != nil {
+		return 0, err
+	}
+	var r rune
+	if strings.HasPrefix(p.src[p.pos:], `\`) {
+		var err error
+		if r, err = p.parseEscape(true); err != nil {
+			return 0, err
+		}
+	} else {
+		var size int
+		r, size = utf8.DecodeRuneInString(p.src[p.pos:])
+		p.pos += size
+	}
+	return r, p.expect("'")
+}
+
+// parseEscape parses an escape of Rust, the unicode escapes are not allowed in the bytes.
+func (p *literalParser) parseEscape(bytes bool) (rune, error) {
+	p.pos++ // the backslash
+	if p.pos >= len(p.src) {
+		return 0, fmt.Errorf("unterminated escape")
+	}
+	c := p.src[p.pos]
+	p.pos++
+	switch c {
+	case 'n':
+		return '\n', nil
+	case 'r':
+		return '\r', nil
+	case 't':
+		return '\t', nil
+	case '0':
+		return 0, nil
+	case '\\', '\'', '"':
+		return rune(c), nil
+	case 'x':
+		if p.pos+2 > len(p.src) {
+			return 0, fmt.Errorf("invalid escape \\x")
+		}
+		value, err := strconv.ParseUint(p.src[p.pos:p.pos+2], 16, 8)
+		if err != nil || (!bytes && value > 0x7f) {
+			return 0, fmt.Errorf("invalid escape \\x%s", p.src[p.pos:p.pos+2])
+		}
+		p.pos += 2
+		return rune(value), nil
+	case 'u':
+		end := strings.IndexByte(p.src[p.pos:], '}')
+		if bytes || !strings.HasPrefix(p.src[p.pos:], "{") || end < 0 {
+			return 0, fmt.Errorf("invalid unicode escape")
+		}
+		value, err := strconv.ParseUint(strings.ReplaceAll(p.src[p.pos+1:p.pos+end], "_", ""), 16, 32)
+		if err != nil || !utf8.ValidRune(rune(value)) {
+			return 0, fmt.Errorf("invalid unicode escape \\u%s", p.src[p.pos:p.pos+end+1])
+		}
+		p.pos += end + 1
+		return rune(value), nil
+	default:
+		return 0, fmt.Errorf("unknown escape \\%c", c)
+	}
+}
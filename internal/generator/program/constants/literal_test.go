@@ -0,0 +1,183 @@
+package constants
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestParseLiteral(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		// Numbers, the underscores are removed and the suffixes are kept.
+		{`42`, `n(42)`},
+		{`-7`, `n(-7)`},
+		{`1_000_000`, `n(1000000)`},
+		{`1_000u64`, `n(1000u64)`},
+		{`-128i8`, `n(-128i8)`},
+		{`0xff`, `n(0xff)`},
+		{`0xFF_u8`, `n(0xFFu8)`},
+		{`0o777`, `n(0o777)`},
+		{`0b1010_1010`, `n(0b10101010)`},
+		{`1.5`, `n(1.5)`},
+		{`2.5f32`, `n(2.5f32)`},
+		{`1e-3`, `n(1e-3)`},
+		{`6.02E+23f64`, `n(6.02E+23f64)`},
+		{`0x1e`, `n(0x1e)`},
+		{`b'a'`, `n(97)`},
+		{`b'\n'`, `n(10)`},
+		{`b'\xff'`, `n(255)`},
+
+		// Strings.
+		{`"vault"`, `s("vault")`},
+		{`"a\nb\t\"c\"\\"`, `s("a\nb\t\"c\"\\")`},
+		{`"\x41\0"`, `s("A\x00")`},
+		{`"\u{1F600}"`, `s("😀")`},
+		{`"\u{00_e9}"`, `s("é")`},
+		{`"é"`, `s("é")`},
+		{"\"line \\\n    continued\"", `s("line continued")`},
+		{`r"raw \n"`, `s("raw \\n")`},
+		{`r#"quoted "raw""#`, `s("quoted \"raw\"")`},
+		{`&"seed"`, `s("seed")`},
+
+		// Byte strings.
+		{`b"vault"`, `b("vault")`},
+		{`b"\xff\x00"`, `b("\xff\x00")`},
+		{`br"raw\x"`, `b("raw\\x")`},
+		{`br#"a"b"#`, `b("a\"b")`},
+
+		// Paths.
+		{`true`, `p(true)`},
+		{`None`, `p(None)`},
+		{`Kind::A`, `p(Kind::A)`},
+		{`crate :: state :: Kind :: B`, `p(crate::state::Kind::B)`},
+		{`_private`, `p(_private)`},
+
+		// Lists.
+		{`[]`, `[]`},
+		{`[1, 2, 3]`, `[n(1), n(2), n(3)]`},
+		{`[1, 2,]`, `[n(1), n(2)]`},
+		{`&[118, 97]`, `[n(118), n(97)]`},
+		{`vec![1, 2]`, `vec!([n(1), n(2)])`},
+		{`vec![]`, `vec!([])`},
+		{`[0u8; 32]`, `[n(0u8); n(32)]`},
+		{`[[1, 2], [3, 4]]`, `[[n(1), n(2)], [n(3), n(4)]]`},
+
+		// Tuples.
+		{`()`, `()`},
+		{`(1, "a")`, `(n(1), s("a"))`},
+		{`(1,)`, `(n(1))`},
+		{`Some(1)`, `Some(n(1))`},
+		{`Point(1, -2)`, `Point(n(1), n(-2))`},
+		{`pubkey!("11111111111111111111111111111111")`, `pubkey!(s("11111111111111111111111111111111"))`},
+
+		// Structs.
+		{`Point { x: 1, y: 2 }`, `Point{x: n(1), y: n(2)}`},
+		{`Point { x: 1, y: 2, }`, `Point{x: n(1), y: n(2)}`},
+		{`Empty {}`, `Empty{}`},
+		{`Outer { inner: Inner { tag: Some(b"t") }, list: [1] }`, `Outer{inner: Inner{tag: Some(b("t"))}, list: [n(1)]}`},
+
+		// Spaces.
+		{"  [ 1 ,\n\t2 ]  ", `[n(1), n(2)]`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			lit, err := parseLiteral(tt.value)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := dumpLiteral(lit); got != tt.expected {
+				t.Errorf("got %s, expected %s", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestParseLiteralErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		err   string
+	}{
+		{``, `unexpected end of value, wanted a value`},
+		{`   `, `unexpected end of value, wanted a value`},
+		{`-`, `unexpected end of value, wanted a number`},
+		{`1 2`, `unexpected "2" after the value`},
+		{`1 << 8`, `unexpected "<< 8" after the value`},
+		{`+1`, `unexpected "+" at offset 0, wanted a value`},
+		{`"open`, `unterminated string`},
+		{`r#"open"`, `unterminated raw string`},
+		{`r#open`, `unexpected "o" at offset 2, wanted "\""`},
+		{`"\q"`, `unknown escape \q`},
+		{`"\`, `unterminated escape`},
+		{`"\x8f"`, `invalid escape \x8f`},
+		{`"\xz1"`, `invalid escape \xz1`},
+		{`"\x4"`, `invalid escape \x4"`},
+		{`"\x`, `invalid escape \x`},
+		{`"\u{110000}"`, `invalid unicode escape \u{110000}`},
+		{`"\u1234"`, `invalid unicode escape`},
+		{`b"\u{41}"`, `invalid unicode escape`},
+		{`b"é"`, `non-ASCII character 'é' in byte string`},
+		{`b'a`, `unexpected end of value, wanted "'"`},
+		{`[1, 2`, `unexpected end of value, wanted "]"`},
+		{`[1 2]`, `unexpected "2" at offset 3, wanted "]"`},
+		{`[1; 2`, `unexpected end of value, wanted "]"`},
+		{`[,]`, `unexpected "," at offset 1, wanted a value`},
+		{`(1 2)`, `unexpected "2" at offset 3, wanted ")"`},
+		{`vec!1`, `unexpected "1" at offset 4, wanted "[" or "("`},
+		{`Point { x 1 }`, `unexpected "1" at offset 10, wanted ":"`},
+		{`Point { x: 1 y: 2 }`, `unexpected "y" at offset 13, wanted "," or "}"`},
+		{`Point { : 1 }`, `unexpected ":" at offset 8, wanted a field name`},
+		{`Point { x: }`, `unexpected "}" at offset 11, wanted a value`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			lit, err := parseLiteral(tt.value)
+			if err == nil {
+				t.Fatalf("expected error %q, got %s", tt.err, dumpLiteral(lit))
+			}
+			if err.Error() != tt.err {
+				t.Errorf("got error %q, expected %q", err, tt.err)
+			}
+		})
+	}
+}
+
+// dumpLiteral formats the literal compactly, e.g. `Point{x: n(1), y: s("a")}`.
+func dumpLiteral(lit *literal) string {
+	items := func(sep string) string {
+		dumped := make([]string, len(lit.items))
+		for i, item := range lit.items {
+			dumped[i] = dumpLiteral(item)
+		}
+		return strings.Join(dumped, sep)
+	}
+	switch lit.kind {
+	case literalNumber:
+		return fmt.Sprintf("n(%s)", lit.text)
+	case literalString:
+		return fmt.Sprintf("s(%q)", lit.text)
+	case literalByteString:
+		return fmt.Sprintf("b(%q)", lit.text)
+	case literalPath:
+		return fmt.Sprintf("p(%s)", lit.text)
+	case literalList:
+		list := "[" + items(", ") + "]"
+		if lit.path != "" {
+			return lit.path + "(" + list + ")"
+		}
+		return list
+	case literalRepeat:
+		return "[" + items("; ") + "]"
+	case literalTuple:
+		return lit.path + "(" + items(", ") + ")"
+	case literalStruct:
+		fields := make([]string, len(lit.fields))
+		for i, field := range lit.fields {
+			fields[i] = field.name + ": " + dumpLiteral(field.value)
+		}
+		return lit.path + "{" + strings.Join(fields, ", ") + "}"
+	}
+	return fmt.Sprintf("unknown(%d)", lit.kind)
+}
@@ -1,6 +1,9 @@
 package events
 
 import (
+	"fmt"
+	"slices"
+
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/generator/program/common"
@@ -11,44 +14,59 @@ import (
 func GenerateEvents(ctx *model.GenerateCtx, program *idl.Idl) *File {
 	file := helper.NewGoFile(ctx)
 
-	for _, evt := range program.Events {
+	for i, evt := range program.Events {
 		identType := ctx.GetIdentifierTy(evt.Name)
 		if identType == nil {
-			panic("event " + evt.Name + " not found in IDL types")
-		}
-
-		var discriminator *[8]byte
-		if evt.Discriminator != nil {
-			discriminator = (*[8]byte)(evt.Discriminator)
+			ctx.Errorf(fmt.Sprintf("events[%d]", i), "event %s not found in IDL types", evt.Name)
+			continue
 		}
 
 		file.Add(
 			common.GenerateTypeDefCode(
 				ctx,
+				evt.Name,
 				evt.Name+"EventData",
 				identType,
-				discriminator,
+				evt.Discriminator,
+				nil,
+				helper.StrIf(ctx.IsCustomSerialization(evt.Name), evt.Name),
 				program,
 			),
 		)
+		file.Line().Func().Params(Id("_").Op("*").Id(evt.Name + "EventData")).Id("isEventData").Params().Block().Line()
 	}
 
-	file.Add(Empty().Var().Id("eventTypes").Op("=").Map(Index(Lit(8)).Byte()).Qual("reflect", "Type").Values(DictFunc(func(d Dict) {
+	// The discriminators are keyed as strings, since they can be of any length.
+	discriminatorLengths := []int{}
+	file.Add(Empty().Var().Id("eventTypes").Op("=").Map(String()).Qual("reflect", "Type").Values(DictFunc(func(d Dict) {
 		for _, evt := range program.Events {
-			if identType := ctx.GetIdentifierTy(evt.Name); identType != nil {
-				d[Id(evt.Name+"EventDataDiscriminator")] = Id("reflect.TypeOf(" + evt.Name + "EventData{})")
+			if identType := ctx.GetIdentifierTy(evt.Name); identType != nil && evt.Discriminator != nil {
+				d[String().Call(Id(evt.Name+"EventDataDiscriminator").Index(Op(":")))] = Id("reflect.TypeOf(" + evt.Name + "EventData{})")
+				if !slices.Contains(discriminatorLengths, len(evt.Discriminator)) {
+					discriminatorLengths = append(discriminatorLengths, len(evt.Discriminator))
+				}
 			}
 		}
 	})))
 
-	file.Add(Empty().Var().Id("eventNames").Op("=").Map(Index(Lit(8)).Byte()).String().Values(DictFunc(func(d Dict) {
+	file.Add(Empty().Var().Id("eventNames").Op("=").Map(String()).String().Values(DictFunc(func(d Dict) {
 		for _, evt := range program.Events {
-			if identType := ctx.GetIdentifierTy(evt.Name); identType != nil {
-				d[Id(evt.Name+"EventDataDiscriminator")] = Lit(evt.Name)
+			if identType := ctx.GetIdentifierTy(evt.Name); identType != nil && evt.Discriminator != nil {
+				d[String().Call(Id(evt.Name+"EventDataDiscriminator").Index(Op(":")))] = Lit(evt.Name)
 			}
 		}
 	})))
 
+	// The longest discriminator is matched first, like the instructions.
+	slices.Sort(discriminatorLengths)
+	slices.Reverse(discriminatorLengths)
+	file.Comment("eventDiscriminatorLengths lists the lengths of the discriminators of the events, from the longest one.")
+	file.Var().Id("eventDiscriminatorLengths").Op("=").Index().Int().ValuesFunc(func(group *Group) {
+		for _, length := range discriminatorLengths {
+			group.Lit(length)
+		}
+	})
+
 	generateEventSnippet(file)
 
 	return file
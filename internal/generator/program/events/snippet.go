@@ -174,14 +174,15 @@ func generateParseEventsFunc(file *File) {
 		),
 
 		For(List(Id("_"), Id("eventBinary")).Op(":=").Range().Id("base64Binaries")).Block(
-			If(Len(Id("eventBinary")).Op("<").Lit(8)).Block(
-				Continue(),
-			),
-			Id("eventDiscriminator").Op(":=").Qual(model.PkgDfuseBinary, "TypeID").Call(Id("eventBinary").Index(Lit(0), Lit(8))),
-			If(
+			For(List(Id("_"), Id("length")).Op(":=").Range().Id("eventDiscriminatorLengths")).Block(
+				If(Len(Id("eventBinary")).Op("<").Id("length")).Block(
+					Continue(),
+				),
+				Id("eventDiscriminator").Op(":=").String().Call(Id("eventBinary").Index(Empty(), Id("length"))),
 				List(Id("eventType"), Id("ok")).Op(":=").Id("eventTypes").Index(Id("eventDiscriminator")),
-				Id("ok"),
-			).Block(
+				If(Op("!").Id("ok")).Block(
+					Continue(),
+				),
 				Id("eventData").Op(":=").Qual("reflect", "New").Call(Id("eventType")).Dot("Interface").Call().Assert(Id("EventData")),
 				Id("decoder").Dot("Reset").Call(Id("eventBinary")),
 				If(
@@ -195,6 +196,7 @@ func generateParseEventsFunc(file *File) {
 					Id("Name"): Id("eventNames").Index(Id("eventDiscriminator")),
 					Id("Data"): Id("eventData"),
 				})),
+				Break(),
 			),
 		),
 		Return(),
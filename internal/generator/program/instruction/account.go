@@ -2,12 +2,15 @@ package instruction
 
 import (
 	"encoding/hex"
+	"slices"
+	"strings"
 
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/idlcode"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/gagliardetto/solana-go"
 )
 
@@ -84,20 +87,16 @@ func generateInstPdaAccountAddressDerivationCode(
 	ctx *model.GenerateCtx,
 	derivationReceiverName string,
 	account *idl.IdlInstructionAccount,
-	instruction *idl.IdlInstruction,
-	program *idl.Idl,
+	programPdaSeed *pdaSeedValue,
+	pdaSeeds []*pdaSeedValue,
 ) Code {
-	programPdaSeed, pdaSeeds := resolveInstructionAccountPda(account, instruction, program)
-	if programPdaSeed == nil && len(pdaSeeds) == 0 {
-		return Empty()
-	}
 
 	accountExportedName := helper.ToCamelCase(account.Name)
 	derivationPrivateName := instPdaAccountDerivationPrivateFuncName(accountExportedName)
 	derivationExportedName := instPdaAccountDerivationExportedFuncName(accountExportedName)
 	derivationWithBumpSeedName := instPdaAccountDerivationWithBumpSeedFuncName(accountExportedName)
 
-	derivationParamsIdent, derivationParamsType := generateDerivationFuncParamsCode(programPdaSeed, pdaSeeds)
+	derivationParamsIdent, derivationParamsType := generateDerivationFuncParamsCode(ctx, programPdaSeed, pdaSeeds)
 
 	code := Line()
 
@@ -122,16 +121,17 @@ func generateInstPdaAccountAddressDerivationCode(
 					body.Add(Id("seeds").Op("=").Append(Id("seeds"), Index().Byte().Values(idlcode.IdlBytesToValuesCode(seed.SeedConst)...)))
 				} else {
 					seedRef := seed.SeedRef
-					if seedRef.RefType.IsSimple() && seedRef.RefType.GetSimple() == idl.IdlTypeSimplePubkey {
-						body.Add(Id("seeds").Op("=").Append(Id("seeds"), Id(seedRef.SeedRefName).Dot("Bytes").Call()))
+					seedBytes, marshal := pdaSeedBytesCode(ctx, *seedRef.RefType, Id(seedRef.SeedRefName))
+					if !marshal {
+						body.Commentf("path: %s", seedRef.SeedRefPath)
+						body.Add(Id("seeds").Op("=").Append(Id("seeds"), seedBytes))
 					} else {
 						body.Block(
-							// seedTyp := seedRef.RefType
 							Commentf("path: %s", seedRef.SeedRefPath),
 							// var res []byte
-							// res, err = ag_solanago.MarshalBorsh(seedRef.SeedRefName)
+							// res, err = ag_binary.MarshalBorsh(seedRef.SeedRefName)
 							Var().Id("res").Index().Byte(),
-							List(Id("res"), Id("err")).Op("=").Qual(model.PkgDfuseBinary, "MarshalBorsh").Call(Id(seedRef.SeedRefName)),
+							List(Id("res"), Id("err")).Op("=").Add(seedBytes),
 							If(Id("err").Op("!=").Nil()).Block(Return()),
 							Id("seeds").Op("=").Append(Id("seeds"), Id("res")),
 						)
@@ -152,13 +152,14 @@ func generateInstPdaAccountAddressDerivationCode(
 				} else {
 					seedProgramRef := programPdaSeed.SeedRef
 					body.Commentf("path: %s", seedProgramRef.SeedRefPath)
-					body.Add(seedProgramId.Op(":=").Id(seedProgramRef.SeedRefName))
+					body.Add(Id("programID").Op(":=").Id(seedProgramRef.SeedRefName))
 				}
 			}
 
 			body.Line().Add(
 				If(Id("knownBumpSeed").Op("!=").Lit(0)).BlockFunc(func(group *Group) {
 					// seeds = append(seeds, []bytes{byte(bumpSeed)})
+					group.Add(Id("bumpSeed").Op("=").Id("knownBumpSeed"))
 					group.Add(Id("seeds").Op("=").Append(Id("seeds"), Index().Byte().Values(Byte().Call(Id("bumpSeed")))))
 					group.Add(List(Id("pda"), Id("err")).Op("=").Add(Qual(model.PkgSolanaGo, "CreateProgramAddress").Call(Id("seeds"), seedProgramId)))
 				}).
@@ -276,7 +277,7 @@ func generateInstPdaAccountAddressDerivationCode(
 	return code
 }
 
-func generateDerivationFuncParamsCode(programPdaSeed *pdaSeedValue, pdaSeeds []*pdaSeedValue) (identList []Code, typeList []Code) {
+func generateDerivationFuncParamsCode(ctx *model.GenerateCtx, programPdaSeed *pdaSeedValue, pdaSeeds []*pdaSeedValue) (identList []Code, typeList []Code) {
 	if programPdaSeed == nil && len(pdaSeeds) == 0 {
 		return nil, nil
 	}
@@ -292,7 +293,7 @@ func generateDerivationFuncParamsCode(programPdaSeed *pdaSeedValue, pdaSeeds []*
 
 		ref := seed.SeedRef
 		identList = append(identList, Id(ref.SeedRefName))
-		typeList = append(typeList, idlcode.IdlTypeToCode(*ref.RefType))
+		typeList = append(typeList, idlcode.IdlTypeToCode(ctx, *ref.RefType))
 	}
 
 	if programPdaSeed != nil && programPdaSeed.SeedConst == nil {
@@ -306,3 +307,114 @@ func generateDerivationFuncParamsCode(programPdaSeed *pdaSeedValue, pdaSeeds []*
 
 	return identList, typeList
 }
+
+// generateInstPdaAccountAddressFromAccountsDerivationCode generates `Find<X>AddressFrom<Accounts>` if some seeds are in the data of accounts,
+// the seeds are read from the decoded accounts instead of the parameters.
+func generateInstPdaAccountAddressFromAccountsDerivationCode(
+	ctx *model.GenerateCtx,
+	derivationReceiverName string,
+	account *idl.IdlInstructionAccount,
+	programPdaSeed *pdaSeedValue,
+	pdaSeeds []*pdaSeedValue,
+	program *idl.Idl,
+) Code {
+	// The parameters follow the order of `generateDerivationFuncParamsCode`.
+	refSeeds := make([]*pdaSeedValue, 0, len(pdaSeeds)+1)
+	for _, seed := range pdaSeeds {
+		if seed.SeedConst == nil {
+			refSeeds = append(refSeeds, seed)
+		}
+	}
+	if programPdaSeed != nil && programPdaSeed.SeedConst == nil {
+		refSeeds = append(refSeeds, programPdaSeed)
+	}
+
+	paramNames := mapset.NewSet[string]()
+	var dataAccountNames []string
+	dataAccountTypes := make(map[string]string)
+	for _, seed := range refSeeds {
+		accountSeed := seed.OriginIdlSeed.GetAccount()
+		if !seed.OriginIdlSeed.IsAccount() || accountSeed.Account == nil {
+			paramNames.Add(seed.SeedRef.SeedRefName)
+			continue
+		}
+		if !slices.ContainsFunc(program.Accounts, func(acc idl.IdlAccount) bool { return acc.Name == *accountSeed.Account }) {
+			// Only the program accounts are decoded.
+			return Empty()
+		}
+		dataAccountName := strings.Split(accountSeed.Path, ".")[0]
+		if _, ok := dataAccountTypes[dataAccountName]; !ok {
+			dataAccountNames = append(dataAccountNames, dataAccountName)
+			dataAccountTypes[dataAccountName] = *accountSeed.Account
+		}
+	}
+	if len(dataAccountNames) == 0 {
+		return Empty()
+	}
+
+	dataAccountParamNames := make(map[string]string, len(dataAccountNames))
+	dataAccountExportedNames := make([]string, len(dataAccountNames))
+	for i, dataAccountName := range dataAccountNames {
+		paramName := helper.ToLowerCamelCase(dataAccountName)
+		if paramNames.Contains(paramName) {
+			paramName += "Account"
+		}
+		dataAccountParamNames[dataAccountName] = paramName
+		dataAccountExportedNames[i] = helper.ToCamelCase(dataAccountName)
+	}
+
+	accountExportedName := helper.ToCamelCase(account.Name)
+	derivationExportedName := instPdaAccountDerivationExportedFuncName(accountExportedName)
+	derivationFromAccountsName := instPdaAccountDerivationFromAccountsFuncName(accountExportedName, dataAccountExportedNames)
+
+	code := Line()
+	code.Commentf("%s finds %s account address with given seeds, the seeds in the account data are read from the decoded accounts.", derivationFromAccountsName, accountExportedName).Line()
+	code.Func().Params(Id("inst").Op("*").Id(derivationReceiverName)).Id(derivationFromAccountsName).
+		ParamsFunc(func(group *Group) {
+			for _, seed := range refSeeds {
+				accountSeed := seed.OriginIdlSeed.GetAccount()
+				if !seed.OriginIdlSeed.IsAccount() || accountSeed.Account == nil {
+					group.Id(seed.SeedRef.SeedRefName).Add(idlcode.IdlTypeToCode(ctx, *seed.SeedRef.RefType))
+				}
+			}
+			for _, dataAccountName := range dataAccountNames {
+				group.Id(dataAccountParamNames[dataAccountName]).Op("*").Id(dataAccountTypes[dataAccountName] + "Account")
+			}
+		}).
+		Params(
+			Id("pda").Qual(model.PkgSolanaGo, "PublicKey"),
+			Id("bumpSeed").Uint8(),
+			Id("err").Error(),
+		).
+		BlockFunc(func(body *Group) {
+			args := make([]Code, 0, len(refSeeds))
+			for _, seed := range refSeeds {
+				accountSeed := seed.OriginIdlSeed.GetAccount()
+				if !seed.OriginIdlSeed.IsAccount() || accountSeed.Account == nil {
+					args = append(args, Id(seed.SeedRef.SeedRefName))
+					continue
+				}
+
+				pathParts := strings.Split(accountSeed.Path, ".")
+				value := Id(dataAccountParamNames[pathParts[0]])
+				for i, field := range seed.SeedRef.RefFields {
+					value = value.Clone().Dot(field.Name)
+					if field.Type.IsOption() {
+						// Optional fields are pointers.
+						body.If(value.Clone().Op("==").Nil()).Block(
+							Id("err").Op("=").Qual("errors", "New").Call(Lit(strings.Join(pathParts[:i+2], ".")+" is not set")),
+							Return(),
+						)
+					}
+				}
+				if seed.SeedRef.RefType.IsOption() && !ctx.IsComplexEnumByType(seed.SeedRef.RefType) {
+					args = append(args, Op("*").Add(value))
+				} else {
+					args = append(args, value)
+				}
+			}
+			body.Return(Id("inst").Dot(derivationExportedName).Call(args...))
+		}).Line()
+
+	return code
+}
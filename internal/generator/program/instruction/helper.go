@@ -100,3 +100,7 @@ func buildInstructionAccountComments(
 
 	return comment.String()
 }
+
+func instPdaAccountDerivationFromAccountsFuncName(accountExportedName string, dataAccountExportedNames []string) string {
+	return instPdaAccountDerivationExportedFuncName(accountExportedName) + "From" + strings.Join(dataAccountExportedNames, "And")
+}
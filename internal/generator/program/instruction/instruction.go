@@ -13,23 +13,28 @@ import (
 	"github.com/alivers/anchor-go/internal/idl"
 	. "github.com/dave/jennifer/jen"
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/gagliardetto/solana-go"
 )
 
-func GenerateInstruction(ctx *model.GenerateCtx, program *idl.Idl, instruction *idl.IdlInstruction) (instName string, instExportedName string, file *File) {
+// GenerateInstruction generates the file of the instruction at `instPath` of the IDL, e.g. `instructions[3]`.
+func GenerateInstruction(ctx *model.GenerateCtx, program *idl.Idl, instPath string, instruction *idl.IdlInstruction) (instName string, instExportedName string, file *File) {
 	instExportedName = helper.ToCamelCase(instruction.Name)
 	file = helper.NewGoFile(ctx)
 
 	addHeaderComment(file, instruction)
 	addInstructionStruct(ctx, file, instExportedName, instruction)
-	addInstructionBuilder(ctx, file, instExportedName, instruction)
+	addInstructionBuilder(ctx, file, instExportedName, instPath, instruction)
 	addInstructionArgsSetter(ctx, file, instExportedName, instruction)
-	addInstructionAccountsGetterSetter(ctx, file, instExportedName, instruction, program)
-	addInstructionBuildMethod(ctx, file, instExportedName)
+	pdaAccounts := addInstructionAccountsGetterSetter(ctx, file, instExportedName, instPath, instruction, program)
+	addInstructionBuildMethod(ctx, file, instExportedName, instruction)
+	addInstructionSetAccountsMethod(file, instExportedName, instruction)
+	addInstructionResolvePdaAccountsMethod(ctx, file, instExportedName, instruction, pdaAccounts)
+	addInstructionResolveRelatedAccountsMethod(ctx, file, instExportedName, instPath, instruction, program)
 	addInstructionValidateMethod(file, instExportedName, instruction)
 	addInstructionValidateAndBuildMethod(file, instExportedName)
 	addInstructionEncodeToTreeMethod(ctx, file, instExportedName, instruction)
 	addInstructionStructSerializeMethod(ctx, file, instExportedName, instruction, program)
-	addInstructionConstructor(file, instExportedName, instruction)
+	addInstructionConstructor(ctx, file, instExportedName, instruction)
 
 	return instruction.Name, instExportedName, file
 }
@@ -52,7 +57,7 @@ func addInstructionStruct(ctx *model.GenerateCtx, file *File, instExportedName s
 				fieldsGroup.Line().Comment(doc)
 			}
 			isComplexEnum := ctx.IsComplexEnumByType(&arg.Type)
-			fieldsGroup.Add(idlcode.IdlFieldToCode(arg, idlcode.FieldCodeOption{AsPointer: true, ComplexEnum: isComplexEnum}))
+			fieldsGroup.Add(idlcode.IdlFieldToCode(ctx, arg, idlcode.FieldCodeOption{AsPointer: true, ComplexEnum: isComplexEnum}))
 		}
 		fieldsGroup.Line()
 
@@ -78,7 +83,7 @@ func addInstructionStruct(ctx *model.GenerateCtx, file *File, instExportedName s
 	})
 }
 
-func addInstructionBuilder(ctx *model.GenerateCtx, file *File, instExportedName string, instruction *idl.IdlInstruction) {
+func addInstructionBuilder(ctx *model.GenerateCtx, file *File, instExportedName string, instPath string, instruction *idl.IdlInstruction) {
 	builderFuncName := newInstructionBuilderName(instExportedName)
 	file.Commentf("%s creates a new `%s` instruction builder.", builderFuncName, instExportedName)
 	file.Func().Id(builderFuncName).Params().Op("*").Id(instExportedName).
@@ -92,6 +97,10 @@ func addInstructionBuilder(ctx *model.GenerateCtx, file *File, instExportedName
 				account := accountWrapper.Account
 
 				if account.Address != nil && *account.Address != "" {
+					if _, err := solana.PublicKeyFromBase58(*account.Address); err != nil {
+						ctx.Errorf(instPath+"."+accountWrapper.Path+".address", "invalid address: %s", err)
+						continue
+					}
 					def := Qual(model.PkgSolanaGo, "Meta").Call(Id("Addresses").Index(Lit(*account.Address)))
 					ctx.SetAddress(*account.Address)
 					if account.Writable {
@@ -121,7 +130,7 @@ func addInstructionArgsSetter(ctx *model.GenerateCtx, file *File, instExportedNa
 
 		file.Func().Params(Id("inst").Op("*").Id(instExportedName)).Id(name).
 			Params(
-				Id(arg.Name).Add(idlcode.IdlTypeToCode(arg.Type)),
+				Id(arg.Name).Add(idlcode.IdlTypeToCode(ctx, arg.Type)),
 			).
 			Params(
 				Op("*").Id(instExportedName),
@@ -141,7 +150,15 @@ func addInstructionArgsSetter(ctx *model.GenerateCtx, file *File, instExportedNa
 	}
 }
 
-func addInstructionAccountsGetterSetter(ctx *model.GenerateCtx, file *File, instExportedName string, instruction *idl.IdlInstruction, program *idl.Idl) {
+// addInstructionAccountsGetterSetter generates the accessors of the accounts, and the address derivation methods of the PDA accounts.
+// It returns the PDA accounts whose derivation is generated.
+func addInstructionAccountsGetterSetter(ctx *model.GenerateCtx, file *File, instExportedName string, instPath string, instruction *idl.IdlInstruction, program *idl.Idl) (pdaAccounts []*instPdaAccount) {
+	pdaAccounts = resolveInstPdaAccounts(ctx, instExportedName, instPath, instruction, program)
+	pdaAccountsByIndex := make(map[int]*instPdaAccount, len(pdaAccounts))
+	for _, pdaAccount := range pdaAccounts {
+		pdaAccountsByIndex[pdaAccount.Index] = pdaAccount
+	}
+
 	groupAccountIdx := 0
 	declaredReceivers := mapset.NewSet[string]()
 	var groupAccountReceiverName string
@@ -217,19 +234,41 @@ func addInstructionAccountsGetterSetter(ctx *model.GenerateCtx, file *File, inst
 		)
 		file.Add(accessorsCode).Line()
 
+		pdaAccount, ok := pdaAccountsByIndex[accountIdx]
+		if !ok {
+			file.Add(Empty()).Line()
+			continue
+		}
+
 		pdaDerivationCode := generateInstPdaAccountAddressDerivationCode(
 			ctx,
-			groupAccountReceiverName,
-			accountWrapper.Account,
-			instruction,
-			program,
+			pdaAccount.DerivationReceiverName,
+			pdaAccount.Account,
+			pdaAccount.PdaProgram,
+			pdaAccount.PdaSeeds,
 		)
 		file.Add(pdaDerivationCode).Line()
+
+		file.Add(generateInstPdaAccountAddressFromAccountsDerivationCode(
+			ctx,
+			pdaAccount.DerivationReceiverName,
+			pdaAccount.Account,
+			pdaAccount.PdaProgram,
+			pdaAccount.PdaSeeds,
+			program,
+		))
 	}
+
+	return pdaAccounts
 }
 
-func addInstructionBuildMethod(ctx *model.GenerateCtx, file *File, instExportedName string) {
-	file.Line().Line().Func().Params(Id("inst").Id(instExportedName)).Id("Build").
+func addInstructionBuildMethod(ctx *model.GenerateCtx, file *File, instExportedName string, instruction *idl.IdlInstruction) {
+	optionalAccountIndexes := instOptionalAccountIndexes(instruction)
+	code := file.Line().Line().Comment("Build builds the instruction.")
+	if len(optionalAccountIndexes) > 0 {
+		code.Line().Comment("The unset optional accounts are passed as the program ID (read-only, not a signer), as Anchor expects.")
+	}
+	code.Line().Func().Params(Id("inst").Id(instExportedName)).Id("Build").
 		Params().
 		Params(
 			ListFunc(func(results *Group) {
@@ -237,6 +276,17 @@ func addInstructionBuildMethod(ctx *model.GenerateCtx, file *File, instExportedN
 			}),
 		).
 		BlockFunc(func(body *Group) {
+			if len(optionalAccountIndexes) > 0 {
+				// The accounts of the receiver are shared with the builder, which keeps the optional accounts unset.
+				body.Id("inst").Dot("AccountMetaSlice").Op("=").Append(Qual(model.PkgSolanaGo, "AccountMetaSlice").Parens(Nil()), Id("inst").Dot("AccountMetaSlice").Op("..."))
+				for _, accountIndex := range optionalAccountIndexes {
+					body.If(Id("inst").Dot("AccountMetaSlice").Index(Lit(accountIndex)).Op("==").Nil()).Block(
+						Id("inst").Dot("AccountMetaSlice").Index(Lit(accountIndex)).Op("=").Qual(model.PkgSolanaGo, "Meta").Call(Id("ProgramID")),
+					)
+				}
+				body.Line()
+			}
+
 			instEnumName := common.GetInstructionEnumName(instExportedName)
 			var typeIDCode Code
 
@@ -249,6 +299,9 @@ func addInstructionBuildMethod(ctx *model.GenerateCtx, file *File, instExportedN
 				typeIDCode = Qual(model.PkgDfuseBinary, "TypeIDFromUint8").Call(Id(instEnumName))
 			case model.DiscriminatorTypeAnchor:
 				typeIDCode = Id(instEnumName)
+			case model.DiscriminatorTypeCustom:
+				// Only the first 8 bytes fit, the discriminator is written by `Instruction.MarshalWithEncoder`.
+				typeIDCode = Qual(model.PkgDfuseBinary, "TypeIDFromBytes").Call(Id(instEnumName))
 			case model.DiscriminatorTypeDefault:
 				typeIDCode = Id(instEnumName)
 			}
@@ -266,6 +319,48 @@ func addInstructionBuildMethod(ctx *model.GenerateCtx, file *File, instExportedN
 		}).Line()
 }
 
+// addInstructionSetAccountsMethod generates the `SetAccounts` method used when decoding the instruction,
+// it unsets the optional accounts passed as the program ID, see `addInstructionBuildMethod`.
+func addInstructionSetAccountsMethod(file *File, instExportedName string, instruction *idl.IdlInstruction) {
+	optionalAccountIndexes := instOptionalAccountIndexes(instruction)
+	if len(optionalAccountIndexes) == 0 {
+		return
+	}
+
+	file.Line().Line().
+		Comment("SetAccounts sets the accounts of the decoded instruction, the optional accounts passed as the program ID are unset.").
+		Line().
+		Func().Params(Id("inst").Op("*").Id(instExportedName)).Id("SetAccounts").
+		Params(
+			Id("accounts").Index().Op("*").Qual(model.PkgSolanaGo, "AccountMeta"),
+		).
+		Params(
+			Error(),
+		).
+		BlockFunc(func(body *Group) {
+			body.Id("inst").Dot("AccountMetaSlice").Op("=").Append(Qual(model.PkgSolanaGo, "AccountMetaSlice").Parens(Nil()), Id("accounts").Op("..."))
+			for _, accountIndex := range optionalAccountIndexes {
+				account := Id("inst").Dot("AccountMetaSlice").Dot("Get").Call(Lit(accountIndex))
+				body.If(
+					account.Clone().Op("!=").Nil().Op("&&").Add(account.Clone()).Dot("PublicKey").Dot("Equals").Call(Id("ProgramID")),
+				).Block(
+					Id("inst").Dot("AccountMetaSlice").Index(Lit(accountIndex)).Op("=").Nil(),
+				)
+			}
+			body.Return(Nil())
+		}).Line()
+}
+
+func instOptionalAccountIndexes(instruction *idl.IdlInstruction) []int {
+	var indexes []int
+	for accountIndex, accountWrapper := range instruction.GetAccountsWithRelation() {
+		if accountWrapper.Account.Optional {
+			indexes = append(indexes, accountIndex)
+		}
+	}
+	return indexes
+}
+
 func addInstructionValidateMethod(file *File, instExportedName string, instruction *idl.IdlInstruction) {
 	file.Line().Line().Func().Params(Id("inst").Op("*").Id(instExportedName)).Id("Validate").
 		Params().
@@ -321,12 +416,14 @@ func addInstructionValidateMethod(file *File, instExportedName string, instructi
 
 func addInstructionValidateAndBuildMethod(file *File, instExportedName string) {
 	file.Line().Line().
-		Comment("ValidateAndBuild validates the instruction parameters and accounts;").
+		Comment("ValidateAndBuild resolves the unset PDA accounts, then validates the instruction parameters and accounts;").
 		Line().
 		Comment("if there is a validation error, it returns the error.").
 		Line().
 		Comment("Otherwise, it builds and returns the instruction.").
 		Line().
+		Comment("The PDAs are resolved on a copy of the accounts, the builder is left unchanged.").
+		Line().
 		Func().Params(Id("inst").Id(instExportedName)).Id("ValidateAndBuild").
 		Params().
 		Params(
@@ -336,11 +433,15 @@ func addInstructionValidateAndBuildMethod(file *File, instExportedName string) {
 			}),
 		).
 		BlockFunc(func(body *Group) {
+			// The accounts of the receiver are shared with the builder.
+			body.Id("inst").Dot("AccountMetaSlice").Op("=").Append(Qual(model.PkgSolanaGo, "AccountMetaSlice").Parens(Nil()), Id("inst").Dot("AccountMetaSlice").Op("..."))
+			// The unresolved PDAs only matter if their accounts are required.
+			body.Id("resolveErr").Op(":=").Id("inst").Dot("ResolvePdaAccounts").Call()
 			body.If(
 				Err().Op(":=").Id("inst").Dot("Validate").Call(),
 				Err().Op("!=").Nil(),
 			).Block(
-				Return(Nil(), Err()),
+				Return(Nil(), Qual("errors", "Join").Call(Err(), Id("resolveErr"))),
 			)
 
 			body.Return(Id("inst").Dot("Build").Call(), Nil())
@@ -422,6 +523,7 @@ func addInstructionStructSerializeMethod(ctx *model.GenerateCtx, file *File, ins
 	marshalCodes := common.GenerateMarshalWithEncoderForStruct(
 		ctx,
 		instExportedName,
+		instruction.Name,
 		instruction.Args,
 		nil,
 		true,
@@ -432,6 +534,7 @@ func addInstructionStructSerializeMethod(ctx *model.GenerateCtx, file *File, ins
 	unmarshalCodes := common.GenerateUnmarshalWithDecoderForStruct(
 		ctx,
 		instExportedName,
+		instruction.Name,
 		instruction.Args,
 		nil,
 		nil,
@@ -441,6 +544,7 @@ func addInstructionStructSerializeMethod(ctx *model.GenerateCtx, file *File, ins
 }
 
 func addInstructionConstructor(
+	ctx *model.GenerateCtx,
 	file *File,
 	instExportedName string,
 	instruction *idl.IdlInstruction,
@@ -459,7 +563,7 @@ func addInstructionConstructor(
 					if argIndex == 0 {
 						paramCode.Line().Comment("Parameters:")
 					}
-					paramCode.Line().Id(arg.Name).Add(idlcode.IdlTypeToCode(arg.Type))
+					paramCode.Line().Id(arg.Name).Add(idlcode.IdlTypeToCode(ctx, arg.Type))
 					params.Add(paramCode)
 				}
 				for accountIndex, wrapper := range instAccounts {
@@ -2,40 +2,81 @@ package instruction
 
 import (
 	"fmt"
+	"path/filepath"
 	"strings"
 
 	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/generator/program/common"
 	"github.com/alivers/anchor-go/internal/idl"
 	ag_solanago "github.com/gagliardetto/solana-go"
 )
 
-func resolveInstructionAccountPda(account *idl.IdlInstructionAccount, instruction *idl.IdlInstruction, program *idl.Idl) (pdaProgram *pdaSeedValue, pdaSeeds []*pdaSeedValue) {
+// resolveInstPdaAccounts resolves the PDA accounts of the instruction whose address derivation can be generated.
+func resolveInstPdaAccounts(ctx *model.GenerateCtx, instExportedName string, instPath string, instruction *idl.IdlInstruction, program *idl.Idl) []*instPdaAccount {
+	var pdaAccounts []*instPdaAccount
+	for accountIdx, accountWrapper := range instruction.GetAccountsWithRelation() {
+		pdaProgram, pdaSeeds, ok := resolveInstructionAccountPda(ctx, accountWrapper.Account, instPath+"."+accountWrapper.Path, instruction, program)
+		if !ok || (pdaProgram == nil && len(pdaSeeds) == 0) {
+			continue
+		}
+
+		groupPath := buildInstAccountGroupPath(accountWrapper.Parents)
+		// The derivation methods of the grouped accounts are on the group builder.
+		derivationReceiverName := instExportedName
+		if groupPath != "" {
+			derivationReceiverName = instAccountsBuilderStructName(instExportedName, helper.ToCamelCase(groupPath))
+		}
+		pdaAccounts = append(pdaAccounts, &instPdaAccount{
+			Account:                accountWrapper.Account,
+			Index:                  accountIdx,
+			ExportedName:           helper.ToCamelCase(filepath.Join(groupPath, accountWrapper.Account.Name)),
+			DerivationReceiverName: derivationReceiverName,
+			PdaProgram:             pdaProgram,
+			PdaSeeds:               pdaSeeds,
+		})
+	}
+	return pdaAccounts
+}
+
+// resolveInstructionAccountPda resolves the pda seeds of the account at `accountPath` of the IDL.
+// It records the problems into `ctx` and returns ok=false if any seed can't be resolved.
+func resolveInstructionAccountPda(ctx *model.GenerateCtx, account *idl.IdlInstructionAccount, accountPath string, instruction *idl.IdlInstruction, program *idl.Idl) (pdaProgram *pdaSeedValue, pdaSeeds []*pdaSeedValue, ok bool) {
 	if account.Pda == nil {
-		return nil, nil
+		return nil, nil, true
 	}
 
 	instAccounts := instruction.GetAccounts()
+	ok = true
 
 	if account.Pda.Program != nil {
-		pdaProgram = resolveInstructionAccountSeed(account.Pda.Program, instAccounts, instruction, program)
+		pdaProgram = resolveInstructionAccountSeed(ctx, account.Pda.Program, accountPath+".pda.program", instAccounts, instruction, program)
+		if pdaProgram != nil && pdaProgram.SeedConst != nil && len(pdaProgram.SeedConst) != ag_solanago.PublicKeyLength {
+			ctx.Errorf(accountPath+".pda.program", "invalid program id length: %d", len(pdaProgram.SeedConst))
+			pdaProgram = nil
+		}
+		ok = pdaProgram != nil
 	}
 
 	if len(account.Pda.Seeds) > 0 {
 		pdaSeeds = make([]*pdaSeedValue, len(account.Pda.Seeds))
 		for i, seed := range account.Pda.Seeds {
-			pdaSeeds[i] = resolveInstructionAccountSeed(&seed, instAccounts, instruction, program)
+			pdaSeeds[i] = resolveInstructionAccountSeed(ctx, &seed, fmt.Sprintf("%s.pda.seeds[%d]", accountPath, i), instAccounts, instruction, program)
+			ok = ok && pdaSeeds[i] != nil
 		}
 	}
 
-	return pdaProgram, pdaSeeds
+	return pdaProgram, pdaSeeds, ok
 }
 
-func resolveInstructionAccountSeed(seed *idl.IdlSeed, instAccounts []*idl.IdlInstructionAccount, instruction *idl.IdlInstruction, program *idl.Idl) *pdaSeedValue {
+// resolveInstructionAccountSeed resolves the seed at `seedPath` of the IDL, it returns nil if the seed can't be resolved.
+func resolveInstructionAccountSeed(ctx *model.GenerateCtx, seed *idl.IdlSeed, seedPath string, instAccounts []*idl.IdlInstructionAccount, instruction *idl.IdlInstruction, program *idl.Idl) *pdaSeedValue {
 	switch {
 	case seed.IsConst():
 		constSeed := seed.GetConst()
 		if constSeed.Value == nil {
-			panic(fmt.Sprintf("[IdlSeedConst]Invalid seed value for const: %v", constSeed))
+			ctx.Errorf(seedPath, "missing value of const seed")
+			return nil
 		}
 		return &pdaSeedValue{
 			OriginIdlSeed: seed,
@@ -45,33 +86,7 @@ func resolveInstructionAccountSeed(seed *idl.IdlSeed, instAccounts []*idl.IdlIns
 	case seed.IsArg():
 		argSeed := seed.GetArg()
 		argField := findInstArgByName(argSeed.Path, instruction.Args)
-		if argField == nil {
-			argParts := strings.Split(argSeed.Path, ".")
-			if len(argParts) != 2 {
-				panic(fmt.Sprintf("[IdlSeedArg]Invalid argument path to split by accesor: %v", argSeed.Path))
-			}
-			argField = findInstArgByName(argParts[0], instruction.Args)
-			if argField == nil {
-				panic(fmt.Sprintf("[IdlSeedArg]Argument field not found for path: %v, find by %s", argSeed.Path, argParts[0]))
-			}
-			switch {
-			case argField.Type.IsDefined():
-				definedType := argField.Type.GetDefined()
-				argType := findStructFieldTypeInProgramTypes(definedType.Name, argParts[1], program.Types)
-				if argType == nil {
-					panic(fmt.Sprintf("[IdlSeedArg]Type definition not found in program types for: %s.%s", definedType.Name, argParts[1]))
-				}
-				return &pdaSeedValue{
-					OriginIdlSeed: seed,
-					SeedConst:     nil,
-					SeedRef: &pdaSeedRef{
-						SeedRefPath: argSeed.Path,
-						SeedRefName: strings.Join(argParts, "_"),
-						RefType:     argType,
-					},
-				}
-			}
-		} else {
+		if argField != nil {
 			return &pdaSeedValue{
 				OriginIdlSeed: seed,
 				SeedConst:     nil,
@@ -82,52 +97,89 @@ func resolveInstructionAccountSeed(seed *idl.IdlSeed, instAccounts []*idl.IdlIns
 				},
 			}
 		}
+
+		argParts := strings.Split(argSeed.Path, ".")
+		argField = findInstArgByName(argParts[0], instruction.Args)
+		if argField == nil {
+			ctx.Errorf(seedPath, "argument %s not found for path: %s", argParts[0], argSeed.Path)
+			return nil
+		}
+		refFields, argType := resolveSeedRefFields(ctx, seedPath, &argField.Type, argParts)
+		if argType == nil {
+			return nil
+		}
+		return &pdaSeedValue{
+			OriginIdlSeed: seed,
+			SeedConst:     nil,
+			SeedRef: &pdaSeedRef{
+				SeedRefPath: argSeed.Path,
+				SeedRefName: strings.Join(argParts, "_"),
+				RefType:     argType,
+				RefFields:   refFields,
+			},
+		}
 	case seed.IsAccount():
 		accountSeed := seed.GetAccount()
 		if accountSeed.Account == nil {
 			for _, account := range instAccounts {
-				if account.Name == accountSeed.Path {
-					if account.Address != nil && *account.Address != "" {
-						return &pdaSeedValue{
-							OriginIdlSeed: seed,
-							SeedConst:     ag_solanago.MustPublicKeyFromBase58(*account.Address).Bytes(),
-							SeedRef:       nil,
-						}
-					} else {
-						ty := idl.IdlTypeSimplePubkey
-						return &pdaSeedValue{
-							OriginIdlSeed: seed,
-							SeedConst:     nil,
-							SeedRef: &pdaSeedRef{
-								SeedRefPath: accountSeed.Path,
-								SeedRefName: helper.ToLowerCamelCase(account.Name),
-								RefType:     &idl.IdlType{IdlTypeSimple: &ty},
-							},
-						}
+				if account.Name != accountSeed.Path {
+					continue
+				}
+				if account.Address != nil && *account.Address != "" {
+					address, err := ag_solanago.PublicKeyFromBase58(*account.Address)
+					if err != nil {
+						ctx.Errorf(seedPath, "invalid address of account %s: %s", account.Name, err)
+						return nil
+					}
+					return &pdaSeedValue{
+						OriginIdlSeed: seed,
+						SeedConst:     address.Bytes(),
+						SeedRef:       nil,
 					}
 				}
+				ty := idl.IdlTypeSimplePubkey
+				return &pdaSeedValue{
+					OriginIdlSeed: seed,
+					SeedConst:     nil,
+					SeedRef: &pdaSeedRef{
+						SeedRefPath: accountSeed.Path,
+						SeedRefName: helper.ToLowerCamelCase(account.Name),
+						RefType:     &idl.IdlType{IdlTypeSimple: &ty},
+					},
+				}
 			}
-		} else {
-			fieldParts := strings.Split(accountSeed.Path, ".")
-			if len(fieldParts) != 2 {
-				panic(fmt.Sprintf("[IdlSeedAccount]Invalid account path to split by accesor: %v", accountSeed.Path))
-			}
-			fieldType := findStructFieldTypeInProgramTypes(*accountSeed.Account, fieldParts[1], program.Types)
-			if fieldType == nil {
-				panic(fmt.Sprintf("[IdlSeedAccount]Type definition not found in program types for: %s.%s", fieldParts[0], fieldParts[1]))
-			}
-			return &pdaSeedValue{
-				OriginIdlSeed: seed,
-				SeedConst:     nil,
-				SeedRef: &pdaSeedRef{
-					SeedRefPath: accountSeed.Path,
-					SeedRefName: helper.ToLowerCamelCase(strings.Join(fieldParts, "_")),
-					RefType:     fieldType,
-				},
-			}
+			ctx.Errorf(seedPath, "account not found for path: %s", accountSeed.Path)
+			return nil
+		}
+
+		// The value is in the data of the account, e.g. `config.authority` of the `Config` account.
+		fieldParts := strings.Split(accountSeed.Path, ".")
+		if len(fieldParts) < 2 {
+			ctx.Errorf(seedPath, "missing field of account %s in path: %s", *accountSeed.Account, accountSeed.Path)
+			return nil
+		}
+		if _, overridden := ctx.GetTypeOverride(*accountSeed.Account); ctx.GetIdentifierTy(*accountSeed.Account) == nil && !overridden && !ctx.IsExternalType(*accountSeed.Account) {
+			// The type is declared nowhere (e.g. `TokenAccount` in the legacy IDLs), the validator warns that the pda is skipped.
+			return nil
+		}
+		accountType := &idl.IdlType{IdlTypeDefined: &idl.IdlTypeDefined{Name: *accountSeed.Account}}
+		refFields, fieldType := resolveSeedRefFields(ctx, seedPath, accountType, fieldParts)
+		if fieldType == nil {
+			return nil
+		}
+		return &pdaSeedValue{
+			OriginIdlSeed: seed,
+			SeedConst:     nil,
+			SeedRef: &pdaSeedRef{
+				SeedRefPath: accountSeed.Path,
+				SeedRefName: helper.ToLowerCamelCase(strings.Join(fieldParts, "_")),
+				RefType:     fieldType,
+				RefFields:   refFields,
+			},
 		}
 	}
 
+	ctx.Errorf(seedPath, "unknown seed kind")
 	return nil
 }
 
@@ -140,31 +192,66 @@ func findInstArgByName(argName string, args []idl.IdlField) *idl.IdlField {
 	return nil
 }
 
-func findStructFieldTypeInProgramTypes(structTypeName, fieldName string, programTypes []idl.IdlTypeDef) *idl.IdlType {
-	for _, typ := range programTypes {
-		// Match the type name
-		if typ.Name == structTypeName {
-			switch {
-			case typ.Type.IsStruct():
-				structType := typ.Type.GetStruct()
-				switch {
-				case structType.Fields.IsNamed():
-					for _, field := range structType.Fields.GetNamed().Fields {
-						// Match the field name
-						if field.Name == fieldName {
-							return &field.Type
-						}
-					}
-				case structType.Fields.IsTuple():
-					for i, typ := range structType.Fields.GetTuple().Types {
-						// Match the field name(tuple index)
-						if helper.IntToStr(i) == fieldName {
-							return &typ
-						}
-					}
-				}
+// resolveSeedRefFields resolves the fields on the `path` (e.g. `params.inner.0`) from the root of type `rootType`,
+// the first part of the path is the root. It returns nil type if any field can't be resolved.
+func resolveSeedRefFields(ctx *model.GenerateCtx, seedPath string, rootType *idl.IdlType, path []string) ([]*pdaSeedRefField, *idl.IdlType) {
+	fields := make([]*pdaSeedRefField, 0, len(path)-1)
+	typ := rootType
+	for i, part := range path[1:] {
+		parentPath := strings.Join(path[:i+1], ".")
+		if !typ.IsDefined() {
+			ctx.Errorf(seedPath, "%s is not a defined type, path: %s", parentPath, strings.Join(path, "."))
+			return nil, nil
+		}
+		typeName := typ.GetDefined().Name
+		field := findStructField(ctx, typeName, part)
+		if field == nil {
+			ctx.Errorf(seedPath, "field %s.%s not found in program types", typeName, part)
+			return nil, nil
+		}
+		fields = append(fields, field)
+		typ = field.Type
+	}
+	return fields, typ
+}
+
+// findStructField finds the field of the struct type `structTypeName` by the name, or by the index for the tuple structs.
+func findStructField(ctx *model.GenerateCtx, structTypeName, fieldName string) *pdaSeedRefField {
+	structType := findStructType(ctx, structTypeName)
+	if structType == nil {
+		return nil
+	}
+	switch {
+	case structType.Fields.IsNamed():
+		for _, field := range structType.Fields.GetNamed().Fields {
+			// Match the field name
+			if field.Name == fieldName {
+				return &pdaSeedRefField{Name: helper.ToCamelCase(field.Name), Type: &field.Type}
+			}
+		}
+	case structType.Fields.IsTuple():
+		for i, typ := range structType.Fields.GetTuple().Types {
+			// Match the field name(tuple index)
+			if helper.IntToStr(i) == fieldName {
+				return &pdaSeedRefField{Name: helper.ToCamelCase(common.GetTupleStructElementName(i)), Type: &typ}
 			}
 		}
 	}
 	return nil
 }
+
+// findStructType returns the struct type of the name, following the aliases. It's nil if the type isn't a struct.
+func findStructType(ctx *model.GenerateCtx, typeName string) *idl.IdlTypeDefTyStruct {
+	typ := ctx.GetIdentifierTy(typeName)
+	// Follow the aliases (Go type aliases), a cycle ends up with a type which isn't a struct.
+	for range len(ctx.IdentifierTypeRegistry) {
+		if typ == nil || !typ.IsType() || !typ.GetType().Alias.IsDefined() {
+			break
+		}
+		typ = ctx.GetIdentifierTy(typ.GetType().Alias.GetDefined().Name)
+	}
+	if typ == nil || !typ.IsStruct() || typ.GetStruct().Fields == nil {
+		return nil
+	}
+	return typ.GetStruct()
+}
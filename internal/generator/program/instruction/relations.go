@@ -0,0 +1,227 @@
+package instruction
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// instRelatedAccount is an account of the instruction which is a `has_one` relation of other accounts.
+type instRelatedAccount struct {
+	Account *idl.IdlInstructionAccount
+	// Index of the account in the `AccountMetaSlice` of the instruction.
+	Index        int
+	ExportedName string
+	// Indexes of the accounts which have the account as a `has_one` relation.
+	RelationIndexes []int
+	// Program account types which may be the relations.
+	AccountTypes []*relationAccountType
+}
+
+// relationAccountType is a program account type which has the address of a related account.
+type relationAccountType struct {
+	// Go type of the decoded account, e.g. `VaultAccount`.
+	TypeName string
+	// Go field of the address, e.g. `Authority`.
+	FieldName string
+}
+
+// addInstructionResolveRelatedAccountsMethod generates the `ResolveRelatedAccounts` method, which fills the unset accounts
+// from the data of the accounts which have them as `has_one` relations, e.g. `authority` from `vault.authority`.
+func addInstructionResolveRelatedAccountsMethod(ctx *model.GenerateCtx, file *File, instExportedName string, instPath string, instruction *idl.IdlInstruction, program *idl.Idl) {
+	instAccounts := instruction.GetAccountsWithRelation()
+	var relatedAccounts []*instRelatedAccount
+	for accountIdx, accountWrapper := range instAccounts {
+		account := accountWrapper.Account
+		// Optional accounts are never resolved, like the PDA accounts.
+		if len(account.Relations) == 0 || account.Optional {
+			continue
+		}
+		relatedAccount := &instRelatedAccount{
+			Account:      account,
+			Index:        accountIdx,
+			ExportedName: helper.ToCamelCase(filepath.Join(buildInstAccountGroupPath(accountWrapper.Parents), account.Name)),
+		}
+		for i, relation := range account.Relations {
+			relationIndex := findInstAccountIndexByName(relation, instruction.GetAccounts())
+			if relationIndex < 0 {
+				ctx.Warnf(fmt.Sprintf("%s.%s.relations[%d]", instPath, accountWrapper.Path, i), "relation %s not found in the accounts of the instruction", relation)
+				continue
+			}
+			relatedAccount.RelationIndexes = append(relatedAccount.RelationIndexes, relationIndex)
+		}
+		if len(relatedAccount.RelationIndexes) == 0 {
+			continue
+		}
+		if relatedAccount.AccountTypes = findRelationAccountTypes(ctx, program, account.Name); len(relatedAccount.AccountTypes) == 0 {
+			ctx.Warnf(fmt.Sprintf("%s.%s.relations", instPath, accountWrapper.Path), "no account type of the program has the pubkey field %s, the account can't be resolved from its relations", account.Name)
+			continue
+		}
+		relatedAccounts = append(relatedAccounts, relatedAccount)
+	}
+	if len(relatedAccounts) == 0 {
+		return
+	}
+
+	file.Line().Line().
+		Comment("ResolveRelatedAccounts fills the unset accounts which are `has_one` relations of the set accounts (e.g. `authority` from `vault.authority`),").
+		Line().
+		Comment("the accounts which have the relations are fetched with `fetcher` and decoded. A filled account may have relations as well.").
+		Line().
+		Comment("Optional accounts are never resolved. It returns an error listing the accounts which can't be resolved and why.").
+		Line().
+		Func().Params(Id("inst").Op("*").Id(instExportedName)).Id("ResolveRelatedAccounts").
+		Params(
+			Id("ctx").Qual("context", "Context"),
+			Id("fetcher").Id("AccountFetcher"),
+		).
+		Params(
+			Error(),
+		).
+		BlockFunc(func(body *Group) {
+			body.Var().Id("unresolved").Index().String()
+			body.Id("fetched").Op(":=").Make(Map(Qual(model.PkgSolanaGo, "PublicKey")).Index().Byte())
+			body.Id("fetch").Op(":=").Func().Params(Id("address").Qual(model.PkgSolanaGo, "PublicKey")).Params(Index().Byte(), Error()).Block(
+				If(List(Id("data"), Id("ok")).Op(":=").Id("fetched").Index(Id("address")), Id("ok")).Block(
+					Return(Id("data"), Nil()),
+				),
+				List(Id("data"), Err()).Op(":=").Id("fetcher").Dot("FetchAccountData").Call(Id("ctx"), Id("address")),
+				If(Err().Op("!=").Nil()).Block(
+					Return(Nil(), Err()),
+				),
+				Id("fetched").Index(Id("address")).Op("=").Id("data"),
+				Return(Id("data"), Nil()),
+			)
+
+			for _, relatedAccount := range sortInstRelatedAccountsByDependency(relatedAccounts) {
+				body.Line().Commentf("[%v] = %s", relatedAccount.Index, relatedAccount.ExportedName)
+				body.If(Id("inst").Dot("AccountMetaSlice").Index(Lit(relatedAccount.Index)).Op("==").Nil()).
+					BlockFunc(func(block *Group) {
+						generateResolveRelatedAccountCode(block, relatedAccount, instruction.GetAccounts())
+					})
+			}
+
+			body.Line()
+			body.If(Len(Id("unresolved")).Op(">").Lit(0)).Block(
+				Return(Qual("fmt", "Errorf").Call(
+					Lit("unresolved related accounts: %s"),
+					Qual("strings", "Join").Call(Id("unresolved"), Lit("; ")),
+				)),
+			)
+			body.Return(Nil())
+		}).Line()
+}
+
+func generateResolveRelatedAccountCode(block *Group, relatedAccount *instRelatedAccount, instAccounts []*idl.IdlInstructionAccount) {
+	account := relatedAccount.Account
+	block.Var().Id("reasons").Index().String()
+	for i, relationIndex := range relatedAccount.RelationIndexes {
+		relation := instAccounts[relationIndex].Name
+		relationAccount := Id("inst").Dot("AccountMetaSlice").Index(Lit(relationIndex))
+
+		code := If(relationAccount.Clone().Op("==").Nil()).Block(
+			Id("reasons").Op("=").Append(Id("reasons"), Lit(fmt.Sprintf("account %s is not set", relation))),
+		).Else().If(
+			List(Id("data"), Err()).Op(":=").Id("fetch").Call(relationAccount.Clone().Dot("PublicKey")),
+			Err().Op("!=").Nil(),
+		).Block(
+			Id("reasons").Op("=").Append(Id("reasons"), Qual("fmt", "Sprintf").Call(Lit(fmt.Sprintf("account %s: %%s", relation)), Err())),
+		)
+
+		for _, accountType := range relatedAccount.AccountTypes {
+			meta := Qual(model.PkgSolanaGo, "Meta").Call(Id("account").Dot(accountType.FieldName))
+			if account.Writable {
+				meta.Dot("WRITE").Call()
+			}
+			if account.Signer {
+				meta.Dot("SIGNER").Call()
+			}
+			code.Else().If(
+				Id("account").Op(":=").New(Id(accountType.TypeName)),
+				// The data of the accounts is always encoded with Borsh, whatever the encoder of the instructions.
+				Id("account").Dot("UnmarshalWithDecoder").Call(Qual(model.PkgDfuseBinary, model.EncoderTypeBorsh.GetNewDecoderName()).Call(Id("data"))).Op("==").Nil(),
+			).Block(
+				Id("inst").Dot("AccountMetaSlice").Index(Lit(relatedAccount.Index)).Op("=").Add(meta),
+			)
+		}
+
+		typeNames := make([]string, len(relatedAccount.AccountTypes))
+		for j, accountType := range relatedAccount.AccountTypes {
+			typeNames[j] = accountType.TypeName
+		}
+		code.Else().Block(
+			Id("reasons").Op("=").Append(Id("reasons"), Lit(fmt.Sprintf("account %s is not one of %s", relation, strings.Join(typeNames, ", ")))),
+		)
+
+		if i > 0 {
+			// The previous relations may have resolved the account.
+			code = If(Id("inst").Dot("AccountMetaSlice").Index(Lit(relatedAccount.Index)).Op("==").Nil()).Block(code)
+		}
+		block.Commentf("%s.%s", relation, account.Name)
+		block.Add(code)
+	}
+
+	block.If(Id("inst").Dot("AccountMetaSlice").Index(Lit(relatedAccount.Index)).Op("==").Nil()).Block(
+		Id("unresolved").Op("=").Append(
+			Id("unresolved"),
+			Lit(fmt.Sprintf("accounts.%s: ", relatedAccount.ExportedName)).Op("+").Qual("strings", "Join").Call(Id("reasons"), Lit(", ")),
+		),
+	)
+}
+
+// findRelationAccountTypes finds the program account types which have the pubkey field `fieldName`,
+// the field is the address of the related account. The fields are matched by their names in the IDL,
+// as Anchor names them after the accounts, even if they are renamed by the generator config.
+func findRelationAccountTypes(ctx *model.GenerateCtx, program *idl.Idl, fieldName string) []*relationAccountType {
+	var accountTypes []*relationAccountType
+	for _, acc := range program.Accounts {
+		structType := findStructType(ctx, acc.Name)
+		if structType == nil || !structType.Fields.IsNamed() {
+			continue
+		}
+		for _, field := range structType.Fields.GetNamed().Fields {
+			if ctx.FieldIdlName(acc.Name, field) != fieldName || !field.Type.IsSimple() || field.Type.GetSimple() != idl.IdlTypeSimplePubkey {
+				continue
+			}
+			accountTypes = append(accountTypes, &relationAccountType{
+				TypeName:  acc.Name + "Account",
+				FieldName: helper.ToCamelCase(field.Name),
+			})
+		}
+	}
+	return accountTypes
+}
+
+// sortInstRelatedAccountsByDependency sorts the related accounts so that every account comes after its relations which are resolved as well.
+func sortInstRelatedAccountsByDependency(relatedAccounts []*instRelatedAccount) []*instRelatedAccount {
+	byIndex := make(map[int]*instRelatedAccount, len(relatedAccounts))
+	for _, relatedAccount := range relatedAccounts {
+		byIndex[relatedAccount.Index] = relatedAccount
+	}
+
+	sorted := make([]*instRelatedAccount, 0, len(relatedAccounts))
+	visited := make(map[int]bool, len(relatedAccounts))
+	var visit func(relatedAccount *instRelatedAccount)
+	visit = func(relatedAccount *instRelatedAccount) {
+		if visited[relatedAccount.Index] {
+			// Also breaks the dependency cycles, the accounts of a cycle are reported as unresolved.
+			return
+		}
+		visited[relatedAccount.Index] = true
+		for _, relationIndex := range relatedAccount.RelationIndexes {
+			if dependency, ok := byIndex[relationIndex]; ok {
+				visit(dependency)
+			}
+		}
+		sorted = append(sorted, relatedAccount)
+	}
+	for _, relatedAccount := range relatedAccounts {
+		visit(relatedAccount)
+	}
+	return sorted
+}
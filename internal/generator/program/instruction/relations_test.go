@@ -0,0 +1,121 @@
+package instruction_test
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/config"
+	"github.com/alivers/anchor-go/internal/generator"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+const relationsIdl = `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "withdraw",
+      "discriminator": [1, 2, 3, 4, 5, 6, 7, 8],
+      "accounts": [
+        {"name": "vault", "writable": true},
+        {"name": "authority", "signer": true, "relations": ["vault"]},
+        {"name": "mint", "relations": ["vault"]}
+      ],
+      "args": []
+    }
+  ],
+  "accounts": [{"name": "Vault", "discriminator": [1, 1, 1, 1, 1, 1, 1, 1]}],
+  "types": [
+    {"name": "Vault", "type": {"kind": "struct", "fields": [{"name": "authority", "type": "pubkey"}]}}
+  ]
+}`
+
+func TestResolveRelatedAccounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoder  model.EncoderType
+		renames  map[string]string
+		expected []string
+	}{
+		{
+			name:    "field named after the account",
+			encoder: model.EncoderTypeBorsh,
+			expected: []string{
+				"func (inst *Withdraw) ResolveRelatedAccounts(ctx context.Context, fetcher AccountFetcher) error {",
+				"inst.AccountMetaSlice[1] = ag_solanago.Meta(account.Authority).SIGNER()",
+			},
+		},
+		{
+			name:     "renamed field",
+			encoder:  model.EncoderTypeBorsh,
+			renames:  map[string]string{"Vault.authority": "Owner"},
+			expected: []string{"inst.AccountMetaSlice[1] = ag_solanago.Meta(account.Owner).SIGNER()"},
+		},
+		{
+			name:     "account data decoded with borsh",
+			encoder:  model.EncoderTypeBin,
+			expected: []string{"account.UnmarshalWithDecoder(ag_binary.NewBorshDecoder(data))"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := idl.Parse([]byte(relationsIdl))
+			if err != nil {
+				t.Fatal(err)
+			}
+			files, diags, err := generator.Generate(generator.Options{
+				DstFolder:  t.TempDir(),
+				ImportBase: "example.com/out",
+				Encoder:    tt.encoder,
+				Config:     &config.Config{Renames: config.Renames{Fields: tt.renames}},
+			}, program)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The mint isn't a field of any account type.
+			warnings := diags.Warnings()
+			if len(warnings) != 1 || warnings[0].Path != "instructions[0].accounts[2].relations" {
+				t.Errorf("expected a warning for the mint relation, got %v", warnings)
+			}
+
+			withdraw := generatedFile(files, "withdraw.go")
+			for _, expected := range tt.expected {
+				if !strings.Contains(withdraw, expected) {
+					t.Errorf("withdraw.go doesn't contain %q", expected)
+				}
+			}
+			if strings.Contains(withdraw, "// [2] = Mint\n\tif inst.AccountMetaSlice[2] == nil {") {
+				t.Error("the unresolvable mint is resolved")
+			}
+		})
+	}
+}
+
+func TestResolveRelatedAccountsNotGeneratedWithoutAccountTypes(t *testing.T) {
+	program, err := idl.Parse([]byte(strings.ReplaceAll(relationsIdl, `"name": "authority", "type"`, `"name": "admin", "type"`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, diags, err := generator.Generate(generator.Options{DstFolder: t.TempDir(), ImportBase: "example.com/out"}, program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(diags.Warnings()) != 2 {
+		t.Errorf("expected a warning for each relation, got %v", diags.Warnings())
+	}
+	if strings.Contains(generatedFile(files, "withdraw.go"), "ResolveRelatedAccounts") {
+		t.Error("ResolveRelatedAccounts is generated without any resolvable account")
+	}
+}
+
+func generatedFile(files map[string][]byte, name string) string {
+	for path, content := range files {
+		if filepath.Base(path) == name {
+			return string(content)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,229 @@
+package instruction
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// pdaSeedRequirement is a check which must pass before reading a seed value from the instruction.
+type pdaSeedRequirement struct {
+	// Condition which is true when the value is missing.
+	Missing Code
+	Reason  string
+}
+
+// addInstructionResolvePdaAccountsMethod generates the `ResolvePdaAccounts` method, which fills the unset PDA accounts
+// whose seeds are constants, parameters or accounts of the instruction.
+func addInstructionResolvePdaAccountsMethod(ctx *model.GenerateCtx, file *File, instExportedName string, instruction *idl.IdlInstruction, pdaAccounts []*instPdaAccount) {
+	instAccounts := instruction.GetAccounts()
+
+	file.Line().Line().
+		Comment("ResolvePdaAccounts derives and sets the unset PDA accounts whose seeds are constants,").
+		Line().
+		Comment("parameters or accounts of the instruction, a PDA may be a seed of another one.").
+		Line().
+		Comment("Optional accounts are never resolved. It returns an error listing the PDAs which can't be resolved and why.").
+		Line().
+		Func().Params(Id("inst").Op("*").Id(instExportedName)).Id("ResolvePdaAccounts").
+		Params().
+		Params(
+			Error(),
+		).
+		BlockFunc(func(body *Group) {
+			orderedAccounts := sortInstPdaAccountsByDependency(pdaAccounts, instAccounts)
+			if len(orderedAccounts) == 0 {
+				body.Return(Nil())
+				return
+			}
+
+			body.Var().Id("unresolved").Index().String()
+			for _, pdaAccount := range orderedAccounts {
+				body.Line().Commentf("[%v] = %s", pdaAccount.Index, pdaAccount.ExportedName)
+				body.If(Id("inst").Dot("AccountMetaSlice").Index(Lit(pdaAccount.Index)).Op("==").Nil()).
+					BlockFunc(func(block *Group) {
+						generateResolvePdaAccountCode(ctx, block, instExportedName, pdaAccount, instAccounts)
+					})
+			}
+
+			body.Line()
+			body.If(Len(Id("unresolved")).Op(">").Lit(0)).Block(
+				Return(Qual("fmt", "Errorf").Call(
+					Lit("unresolved PDA accounts: %s"),
+					Qual("strings", "Join").Call(Id("unresolved"), Lit("; ")),
+				)),
+			)
+			body.Return(Nil())
+		}).Line()
+}
+
+func generateResolvePdaAccountCode(ctx *model.GenerateCtx, block *Group, instExportedName string, pdaAccount *instPdaAccount, instAccounts []*idl.IdlInstructionAccount) {
+	var (
+		requirements []*pdaSeedRequirement
+		args         []Code
+	)
+	seeds := pdaAccount.PdaSeeds
+	if pdaAccount.PdaProgram != nil {
+		// The program is the last derivation parameter, see `generateDerivationFuncParamsCode`.
+		seeds = append(seeds[:len(seeds):len(seeds)], pdaAccount.PdaProgram)
+	}
+	for _, seed := range seeds {
+		if seed.SeedConst != nil {
+			continue
+		}
+		value, seedRequirements, unresolvable := instSeedValueCode(ctx, seed, instAccounts)
+		if unresolvable != "" {
+			block.Id("unresolved").Op("=").Append(Id("unresolved"), Lit(fmt.Sprintf("accounts.%s: %s", pdaAccount.ExportedName, unresolvable)))
+			return
+		}
+		requirements = append(requirements, seedRequirements...)
+		args = append(args, value)
+	}
+
+	derivationReceiver := Id("inst")
+	if pdaAccount.DerivationReceiverName != instExportedName {
+		// The derivation methods of grouped accounts are on the group builder, they don't access the receiver.
+		derivationReceiver = New(Id(pdaAccount.DerivationReceiverName))
+	}
+
+	meta := Qual(model.PkgSolanaGo, "Meta").Call(Id("pda"))
+	if pdaAccount.Account.Writable {
+		meta.Dot("WRITE").Call()
+	}
+	if pdaAccount.Account.Signer {
+		meta.Dot("SIGNER").Call()
+	}
+
+	block.Switch().BlockFunc(func(cases *Group) {
+		reasons := make(map[string]bool)
+		for _, requirement := range requirements {
+			if reasons[requirement.Reason] {
+				continue
+			}
+			reasons[requirement.Reason] = true
+			cases.Case(requirement.Missing).Block(
+				Id("unresolved").Op("=").Append(Id("unresolved"), Lit(fmt.Sprintf("accounts.%s: %s", pdaAccount.ExportedName, requirement.Reason))),
+			)
+		}
+		cases.Default().Block(
+			List(Id("pda"), Id("_"), Err()).Op(":=").Add(derivationReceiver).
+				Dot(instPdaAccountDerivationExportedFuncName(helper.ToCamelCase(pdaAccount.Account.Name))).Call(args...),
+			If(Err().Op("!=").Nil()).Block(
+				Id("unresolved").Op("=").Append(Id("unresolved"), Qual("fmt", "Sprintf").Call(Lit(fmt.Sprintf("accounts.%s: %%s", pdaAccount.ExportedName)), Err())),
+			).Else().Block(
+				Id("inst").Dot("AccountMetaSlice").Index(Lit(pdaAccount.Index)).Op("=").Add(meta),
+			),
+		)
+	})
+}
+
+// instSeedValueCode returns the code reading the value of the seed from the instruction, and the requirements to read it.
+// If the seed can never be read from the instruction, it returns the reason as `unresolvable`.
+func instSeedValueCode(ctx *model.GenerateCtx, seed *pdaSeedValue, instAccounts []*idl.IdlInstructionAccount) (value Code, requirements []*pdaSeedRequirement, unresolvable string) {
+	seedRef := seed.SeedRef
+	switch {
+	case seed.OriginIdlSeed.IsArg():
+		argName := seedRef.SeedRefPath
+		if len(seedRef.RefFields) > 0 {
+			argName = strings.Split(seedRef.SeedRefPath, ".")[0]
+		}
+		fields := []string{helper.ToCamelCase(argName)}
+		requirements = append(requirements, &pdaSeedRequirement{
+			Missing: instFieldsCode(fields).Op("==").Nil(),
+			Reason:  fmt.Sprintf("%s parameter is not set", fields[0]),
+		})
+		for _, field := range seedRef.RefFields {
+			fields = append(fields, field.Name)
+			if field.Type.IsOption() {
+				// Optional fields of a defined type are pointers.
+				requirements = append(requirements, &pdaSeedRequirement{
+					Missing: instFieldsCode(fields).Op("==").Nil(),
+					Reason:  fmt.Sprintf("%s parameter is not set", strings.Join(fields, ".")),
+				})
+			}
+		}
+		if ctx.IsComplexEnumByType(seedRef.RefType) {
+			// Complex enums are interfaces.
+			return instFieldsCode(fields), requirements, ""
+		}
+		if len(fields) == 1 || seedRef.RefType.IsOption() {
+			return Op("*").Add(instFieldsCode(fields)), requirements, ""
+		}
+		return instFieldsCode(fields), requirements, ""
+	case seed.OriginIdlSeed.IsAccount():
+		accountSeed := seed.OriginIdlSeed.GetAccount()
+		if accountSeed.Account != nil {
+			return nil, nil, fmt.Sprintf("seed %s is read from the data of the %s account", accountSeed.Path, *accountSeed.Account)
+		}
+		index := findInstAccountIndexByName(accountSeed.Path, instAccounts)
+		account := Id("inst").Dot("AccountMetaSlice").Index(Lit(index))
+		requirements = append(requirements, &pdaSeedRequirement{
+			Missing: account.Clone().Op("==").Nil(),
+			Reason:  fmt.Sprintf("account %s is not set", accountSeed.Path),
+		})
+		return account.Clone().Dot("PublicKey"), requirements, ""
+	}
+	return nil, nil, fmt.Sprintf("seed %s is unknown", seedRef.SeedRefPath)
+}
+
+// sortInstPdaAccountsByDependency sorts the PDA accounts so that every PDA comes after the PDAs used as its seeds.
+func sortInstPdaAccountsByDependency(pdaAccounts []*instPdaAccount, instAccounts []*idl.IdlInstructionAccount) []*instPdaAccount {
+	byIndex := make(map[int]*instPdaAccount, len(pdaAccounts))
+	for _, pdaAccount := range pdaAccounts {
+		if pdaAccount.Account.Optional {
+			continue
+		}
+		byIndex[pdaAccount.Index] = pdaAccount
+	}
+
+	sorted := make([]*instPdaAccount, 0, len(byIndex))
+	visited := make(map[int]bool, len(byIndex))
+	var visit func(pdaAccount *instPdaAccount)
+	visit = func(pdaAccount *instPdaAccount) {
+		if visited[pdaAccount.Index] {
+			// Also breaks the dependency cycles, the PDAs of a cycle are reported as unresolved.
+			return
+		}
+		visited[pdaAccount.Index] = true
+		seeds := pdaAccount.PdaSeeds
+		if pdaAccount.PdaProgram != nil {
+			seeds = append(seeds[:len(seeds):len(seeds)], pdaAccount.PdaProgram)
+		}
+		for _, seed := range seeds {
+			if seed.SeedRef == nil || !seed.OriginIdlSeed.IsAccount() || seed.OriginIdlSeed.GetAccount().Account != nil {
+				continue
+			}
+			if dependency, ok := byIndex[findInstAccountIndexByName(seed.SeedRef.SeedRefPath, instAccounts)]; ok {
+				visit(dependency)
+			}
+		}
+		sorted = append(sorted, pdaAccount)
+	}
+	for _, pdaAccount := range pdaAccounts {
+		if _, ok := byIndex[pdaAccount.Index]; ok {
+			visit(pdaAccount)
+		}
+	}
+	return sorted
+}
+
+func findInstAccountIndexByName(accountName string, instAccounts []*idl.IdlInstructionAccount) int {
+	for i, account := range instAccounts {
+		if account.Name == accountName {
+			return i
+		}
+	}
+	return -1
+}
+
+func instFieldsCode(fields []string) *Statement {
+	code := Id("inst")
+	for _, field := range fields {
+		code.Dot(field)
+	}
+	return code
+}
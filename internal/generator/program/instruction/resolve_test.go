@@ -0,0 +1,58 @@
+package instruction_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/generator"
+	"github.com/alivers/anchor-go/internal/idl/legacy"
+)
+
+// The legacy IDLs refer to the accounts of other programs in the seeds, e.g. `TokenAccount`.
+const legacyPdaIdl = `{
+  "version": "0.1.0",
+  "name": "staking",
+  "instructions": [
+    {
+      "name": "stake",
+      "accounts": [
+        {"name": "pool", "isMut": false, "isSigner": false},
+        {"name": "tokenAccount", "isMut": false, "isSigner": false},
+        {"name": "poolVault", "isMut": true, "isSigner": false, "pda": {"seeds": [
+          {"kind": "account", "type": "publicKey", "account": "Pool", "path": "pool.mint"}
+        ]}},
+        {"name": "stake", "isMut": true, "isSigner": false, "pda": {"seeds": [
+          {"kind": "account", "type": "publicKey", "account": "Pool", "path": "pool.mint"},
+          {"kind": "account", "type": "publicKey", "account": "TokenAccount", "path": "tokenAccount.owner"}
+        ]}}
+      ],
+      "args": []
+    }
+  ],
+  "accounts": [
+    {"name": "Pool", "type": {"kind": "struct", "fields": [{"name": "mint", "type": "publicKey"}]}}
+  ],
+  "metadata": {"address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS"}
+}`
+
+func TestSkipPdaOfUndeclaredAccountType(t *testing.T) {
+	program, err := legacy.Convert([]byte(legacyPdaIdl))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files, diags, err := generator.Generate(generator.Options{DstFolder: t.TempDir(), ImportBase: "example.com/out"}, program)
+	if err != nil {
+		t.Fatalf("%v: %v", err, diags)
+	}
+	if warnings := diags.Warnings(); len(warnings) != 1 || warnings[0].Path != "instructions[0].accounts[3].pda.seeds[1]" {
+		t.Errorf("got warnings %v, expected the one of the TokenAccount seed", warnings)
+	}
+
+	stake := generatedFile(files, "stake.go")
+	if !strings.Contains(stake, "FindPoolVaultAddress") {
+		t.Error("the address of the pool vault isn't derived")
+	}
+	if strings.Contains(stake, "FindStakeAddress") {
+		t.Error("the address of the stake is derived without the type of the token account")
+	}
+}
@@ -0,0 +1,237 @@
+package instruction
+
+import (
+	"encoding/binary"
+
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+	"github.com/gagliardetto/solana-go"
+)
+
+// pdaSeedBytesCode returns the code converting the seed `value` of type `typ` into bytes, the same way as Anchor:
+// pubkeys, strings, bytes and byte arrays are the raw bytes, integers are the little-endian bytes.
+// The other types are encoded with Borsh, then `marshal` is true and the code returns `([]byte, error)`.
+func pdaSeedBytesCode(ctx *model.GenerateCtx, typ idl.IdlType, value Code) (code Code, marshal bool) {
+	if typ.IsOption() {
+		// The derivation parameters of optional fields are the inner values.
+		return pdaSeedBytesCode(ctx, typ.GetOption().Option, value)
+	}
+	if isPdaSeedTypeOverridden(ctx, typ) {
+		return Qual(model.PkgDfuseBinary, "MarshalBorsh").Call(value), true
+	}
+
+	switch {
+	case typ.IsSimple():
+		switch typ.GetSimple() {
+		case idl.IdlTypeSimplePubkey:
+			return Add(value).Dot("Bytes").Call(), false
+		case idl.IdlTypeSimpleString:
+			return Index().Byte().Parens(value), false
+		case idl.IdlTypeSimpleBytes:
+			return value, false
+		case idl.IdlTypeSimpleU8:
+			return Index().Byte().Values(value), false
+		case idl.IdlTypeSimpleI8:
+			return Index().Byte().Values(Byte().Parens(value)), false
+		case idl.IdlTypeSimpleU16, idl.IdlTypeSimpleI16:
+			return littleEndianBytesCode(value, 2), false
+		case idl.IdlTypeSimpleU32, idl.IdlTypeSimpleI32:
+			return littleEndianBytesCode(value, 4), false
+		case idl.IdlTypeSimpleU64, idl.IdlTypeSimpleI64:
+			return littleEndianBytesCode(value, 8), false
+		}
+		// The Borsh encoding of the 128 and 256 bits integers is the little-endian bytes as well.
+	case typ.IsArray():
+		if elem := typ.GetArray().Elem; elem.IsSimple() && elem.GetSimple() == idl.IdlTypeSimpleU8 && !isPdaSeedTypeOverridden(ctx, elem) {
+			return Add(value).Index(Op(":")), false
+		}
+	}
+	return Qual(model.PkgDfuseBinary, "MarshalBorsh").Call(value), true
+}
+
+// GeneratePdaSeedsTest generates the test of the address derivation of the PDA accounts of the instruction,
+// the addresses are checked against the ones derived from the seeds encoded by hand.
+func GeneratePdaSeedsTest(ctx *model.GenerateCtx, file *File, instruction *idl.IdlInstruction, program *idl.Idl) {
+	instExportedName := helper.ToCamelCase(instruction.Name)
+	// The problems of the seeds are reported when generating the instruction.
+	quietCtx := *ctx
+	quietCtx.Diagnostics = nil
+	pdaAccounts := resolveInstPdaAccounts(&quietCtx, instExportedName, "", instruction, program)
+
+	cases := make([]Code, 0, len(pdaAccounts))
+	for _, pdaAccount := range pdaAccounts {
+		if code, ok := generatePdaSeedsTestCase(ctx, instExportedName, pdaAccount); ok {
+			cases = append(cases, code)
+		}
+	}
+	if len(cases) == 0 {
+		return
+	}
+
+	file.Line().Func().Id("TestPdaSeeds_" + instExportedName).
+		Params(Id("t").Op("*").Qual("testing", "T")).
+		Block(cases...)
+}
+
+func generatePdaSeedsTestCase(ctx *model.GenerateCtx, instExportedName string, pdaAccount *instPdaAccount) (Code, bool) {
+	var (
+		args          []Code
+		expectedSeeds []Code
+	)
+	for _, seed := range pdaAccount.PdaSeeds {
+		if seed.SeedConst != nil {
+			expectedSeeds = append(expectedSeeds, Values(idlcode.IdlBytesToValuesCode(seed.SeedConst)...))
+			continue
+		}
+		value, expected, ok := pdaSeedSampleCode(ctx, *seed.SeedRef.RefType)
+		if !ok {
+			return nil, false
+		}
+		args = append(args, value)
+		expectedSeeds = append(expectedSeeds, Values(idlcode.IdlBytesToValuesCode(expected)...))
+	}
+
+	var programID Code = Id("ProgramID")
+	if pdaProgram := pdaAccount.PdaProgram; pdaProgram != nil {
+		if pdaProgram.SeedConst != nil {
+			programID = Qual(model.PkgSolanaGo, "PublicKeyFromBytes").Call(Index().Byte().Values(idlcode.IdlBytesToValuesCode(pdaProgram.SeedConst)...))
+		} else {
+			ty := idl.IdlTypeSimplePubkey
+			value, _, _ := pdaSeedSampleCode(ctx, idl.IdlType{IdlTypeSimple: &ty})
+			args = append(args, value)
+			programID = value
+		}
+	}
+
+	receiver := Id(newInstructionBuilderName(instExportedName)).Call()
+	if pdaAccount.DerivationReceiverName != instExportedName {
+		receiver = Id("New" + pdaAccount.DerivationReceiverName).Call()
+	}
+
+	accountExportedName := helper.ToCamelCase(pdaAccount.Account.Name)
+	return Id("t").Dot("Run").Call(
+		Lit(pdaAccount.ExportedName),
+		Func().Params(Id("t").Op("*").Qual("testing", "T")).Block(
+			List(Id("pda"), Id("bumpSeed"), Err()).Op(":=").Add(receiver).Dot(instPdaAccountDerivationExportedFuncName(accountExportedName)).Call(args...),
+			Qual(model.PkgTestifyRequire, "NoError").Call(Id("t"), Err()),
+			Line(),
+			List(Id("expected"), Id("expectedBumpSeed"), Err()).Op(":=").Qual(model.PkgSolanaGo, "FindProgramAddress").Call(
+				Index().Index().Byte().ValuesFunc(func(group *Group) {
+					for _, seed := range expectedSeeds {
+						group.Add(seed)
+					}
+				}),
+				programID,
+			),
+			Qual(model.PkgTestifyRequire, "NoError").Call(Id("t"), Err()),
+			Qual(model.PkgTestifyRequire, "Equal").Call(Id("t"), Id("expected"), Id("pda")),
+			Qual(model.PkgTestifyRequire, "Equal").Call(Id("t"), Id("expectedBumpSeed"), Id("bumpSeed")),
+		),
+	), true
+}
+
+// littleEndianBytesCode returns the code of the little-endian bytes of the integer `value` of `size` bytes,
+// e.g. `[]byte{byte(v), byte(v >> 8)}`.
+func littleEndianBytesCode(value Code, size int) Code {
+	return Index().Byte().ValuesFunc(func(group *Group) {
+		for i := range size {
+			if i == 0 {
+				group.Byte().Parens(value)
+			} else {
+				group.Byte().Parens(Add(value).Op(">>").Lit(8 * i))
+			}
+		}
+	})
+}
+
+// pdaSeedSampleCode returns a sample value of the seed type and its expected bytes, for testing the seed derivation.
+// It returns ok=false if the type has no sample value.
+func pdaSeedSampleCode(ctx *model.GenerateCtx, typ idl.IdlType) (value Code, expected []byte, ok bool) {
+	if typ.IsOption() {
+		return pdaSeedSampleCode(ctx, typ.GetOption().Option)
+	}
+	if isPdaSeedTypeOverridden(ctx, typ) {
+		return nil, nil, false
+	}
+
+	switch {
+	case typ.IsSimple():
+		switch typ.GetSimple() {
+		case idl.IdlTypeSimplePubkey:
+			expected = sampleBytes(32)
+			return Qual(model.PkgSolanaGo, "MustPublicKeyFromBase58").Call(Lit(solana.PublicKeyFromBytes(expected).String())), expected, true
+		case idl.IdlTypeSimpleString:
+			return Lit("anchor"), []byte("anchor"), true
+		case idl.IdlTypeSimpleBytes:
+			expected = sampleBytes(3)
+			return Index().Byte().Values(idlcode.IdlBytesToValuesCode(expected)...), expected, true
+		case idl.IdlTypeSimpleBool:
+			return True(), []byte{1}, true
+		case idl.IdlTypeSimpleU8:
+			return Lit(uint8(0x12)), []byte{0x12}, true
+		case idl.IdlTypeSimpleI8:
+			return Lit(int8(-2)), []byte{0xfe}, true
+		case idl.IdlTypeSimpleU16:
+			return Lit(uint16(0x0102)), binary.LittleEndian.AppendUint16(nil, 0x0102), true
+		case idl.IdlTypeSimpleI16:
+			return Lit(int16(-2)), binary.LittleEndian.AppendUint16(nil, 0xfffe), true
+		case idl.IdlTypeSimpleU32:
+			return Lit(uint32(0x01020304)), binary.LittleEndian.AppendUint32(nil, 0x01020304), true
+		case idl.IdlTypeSimpleI32:
+			return Lit(int32(-2)), binary.LittleEndian.AppendUint32(nil, 0xfffffffe), true
+		case idl.IdlTypeSimpleU64:
+			return Lit(uint64(0x0102030405060708)), binary.LittleEndian.AppendUint64(nil, 0x0102030405060708), true
+		case idl.IdlTypeSimpleI64:
+			return Lit(int64(-2)), binary.LittleEndian.AppendUint64(nil, 0xfffffffffffffffe), true
+		case idl.IdlTypeSimpleU128, idl.IdlTypeSimpleI128:
+			expected = binary.LittleEndian.AppendUint64(binary.LittleEndian.AppendUint64(nil, 0x0102030405060708), 0x090a0b0c0d0e0f10)
+			return Add(idlcode.IdlTypeSimpleToCode(typ.GetSimple())).Values(Dict{
+				Id("Lo"): Lit(uint64(0x0102030405060708)),
+				Id("Hi"): Lit(uint64(0x090a0b0c0d0e0f10)),
+			}), expected, true
+		}
+	case typ.IsArray():
+		elem := typ.GetArray().Elem
+		if !elem.IsSimple() || elem.GetSimple() != idl.IdlTypeSimpleU8 || isPdaSeedTypeOverridden(ctx, elem) {
+			return nil, nil, false
+		}
+		length, ok := pdaSeedArrayLen(ctx, typ.GetArray())
+		if !ok {
+			return nil, nil, false
+		}
+		expected = sampleBytes(length)
+		return Add(idlcode.IdlTypeToCode(ctx, typ)).Values(idlcode.IdlBytesToValuesCode(expected)...), expected, true
+	}
+	return nil, nil, false
+}
+
+func pdaSeedArrayLen(ctx *model.GenerateCtx, arr *idl.IdlTypeArray) (int, bool) {
+	switch {
+	case arr.Len.IsValue():
+		return int(arr.Len.GetValue().Value), true
+	case arr.Len.IsGeneric():
+		length, ok := ctx.GetArrayLen(arr.Len.GetGeneric().Value)
+		return int(length), ok
+	}
+	return 0, false
+}
+
+func isPdaSeedTypeOverridden(ctx *model.GenerateCtx, typ idl.IdlType) bool {
+	if !typ.IsSimple() {
+		return false
+	}
+	_, ok := ctx.GetTypeOverride(typ.GetSimple().String())
+	return ok
+}
+
+// sampleBytes returns the bytes 1, 2, ..., n.
+func sampleBytes(n int) []byte {
+	bytes := make([]byte, n)
+	for i := range bytes {
+		bytes[i] = byte(i + 1)
+	}
+	return bytes
+}
@@ -14,4 +14,26 @@ type pdaSeedRef struct {
 	SeedRefPath string
 	SeedRefName string
 	RefType     *idl.IdlType
+	// Fields from the arg or the account data to the value, e.g. `Params.Id` for `params.id`.
+	// It's empty if the value is the whole arg or account.
+	RefFields []*pdaSeedRefField
+}
+
+type pdaSeedRefField struct {
+	// Name of the Go field
+	Name string
+	Type *idl.IdlType
+}
+
+// instPdaAccount is a PDA account of the instruction whose address derivation is generated.
+type instPdaAccount struct {
+	Account *idl.IdlInstructionAccount
+	// Index of the account in the `AccountMetaSlice` of the instruction.
+	Index int
+	// Exported name of the account, prefixed with the account group path.
+	ExportedName string
+	// Receiver of the derivation methods, it's the instruction or an accounts group builder.
+	DerivationReceiverName string
+	PdaProgram             *pdaSeedValue
+	PdaSeeds               []*pdaSeedValue
 }
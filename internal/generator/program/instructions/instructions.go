@@ -80,9 +80,22 @@ func addInit(file *File) {
 
 func addInstructionEnum(ctx *model.GenerateCtx, file *File, program *idl.Idl) {
 	code := Empty()
-	for _, instruction := range program.Instructions {
+	for i, instruction := range program.Instructions {
 		insExportedName := helper.ToCamelCase(instruction.Name)
 
+		switch ctx.DiscriminatorType {
+		case model.DiscriminatorTypeUint8, model.DiscriminatorTypeUvarint32, model.DiscriminatorTypeUint32:
+			if instruction.Discriminant == nil {
+				ctx.Errorf(fmt.Sprintf("instructions[%d]", i), "missing discriminant, all instructions must use the same discriminator scheme")
+				continue
+			}
+		case model.DiscriminatorTypeAnchor, model.DiscriminatorTypeCustom:
+			if instruction.Discriminator == nil {
+				ctx.Errorf(fmt.Sprintf("instructions[%d]", i), "missing discriminator, all instructions must use the same discriminator scheme")
+				continue
+			}
+		}
+
 		ins := Empty()
 		for _, doc := range instruction.Docs {
 			ins.Comment(doc).Line()
@@ -102,6 +115,12 @@ func addInstructionEnum(ctx *model.GenerateCtx, file *File, program *idl.Idl) {
 					}
 				}).Op("}"),
 			)
+		case model.DiscriminatorTypeCustom:
+			ins.Op("=").Index().Byte().ValuesFunc(func(byteGroup *Group) {
+				for _, byteVal := range instruction.Discriminator {
+					byteGroup.Lit(int(byteVal))
+				}
+			})
 		case model.DiscriminatorTypeDefault:
 			ins.Op("=").Qual(model.PkgDfuseBinary, "TypeID").Call(
 				Index(Lit(8)).Byte().Op("{").ListFunc(func(byteGroup *Group) {
@@ -119,6 +138,11 @@ func addInstructionEnum(ctx *model.GenerateCtx, file *File, program *idl.Idl) {
 }
 
 func addInstructionIdToName(ctx *model.GenerateCtx, file *File, program *idl.Idl) {
+	if ctx.DiscriminatorType == model.DiscriminatorTypeCustom {
+		addInstructionDiscriminatorMatcher(file, program)
+		return
+	}
+
 	idCode := Empty()
 	switch ctx.DiscriminatorType {
 	case model.DiscriminatorTypeUvarint32, model.DiscriminatorTypeUint32:
@@ -145,6 +169,52 @@ func addInstructionIdToName(ctx *model.GenerateCtx, file *File, program *idl.Idl
 		}).Line()
 }
 
+// addInstructionDiscriminatorMatcher declares the table of the instructions with discriminators of different lengths.
+// The instruction data is matched against all the discriminators, the longest one which prefixes the data wins.
+func addInstructionDiscriminatorMatcher(file *File, program *idl.Idl) {
+	file.Type().Id("instructionVariant").Struct(
+		Id("name").String(),
+		Id("discriminator").Index().Byte(),
+		Id("new").Func().Params().Any(),
+	).Line()
+
+	file.Var().Id("instructionVariants").Op("=").Index().Id("instructionVariant").ValuesFunc(func(group *Group) {
+		for _, instruction := range program.Instructions {
+			insExportedName := helper.ToCamelCase(instruction.Name)
+			group.Line().Values(
+				Lit(insExportedName),
+				Id(common.GetInstructionEnumName(insExportedName)),
+				Func().Params().Any().Block(Return(New(Id(insExportedName)))),
+			)
+		}
+		group.Line()
+	}).Line()
+
+	file.Comment("matchInstructionVariant returns the instruction whose discriminator is the longest prefix of the data, or nil.")
+	file.Func().Id("matchInstructionVariant").Params(Id("data").Index().Byte()).Op("*").Id("instructionVariant").Block(
+		Var().Id("match").Op("*").Id("instructionVariant"),
+		For(Id("i").Op(":=").Range().Id("instructionVariants")).Block(
+			Id("variant").Op(":=").Op("&").Id("instructionVariants").Index(Id("i")),
+			If(
+				Qual(model.PkgBytes, "HasPrefix").Call(Id("data"), Id("variant").Dot("discriminator")).Op("&&").
+					Parens(Id("match").Op("==").Nil().Op("||").Len(Id("variant").Dot("discriminator")).Op(">").Len(Id("match").Dot("discriminator"))),
+			).Block(
+				Id("match").Op("=").Id("variant"),
+			),
+		),
+		Return(Id("match")),
+	).Line()
+
+	file.Comment("InstructionIDToName returns the name of the instruction given its discriminator,")
+	file.Comment("or given its data which starts with the discriminator.")
+	file.Func().Id("InstructionIDToName").Params(Id("id").Index().Byte()).String().Block(
+		If(Id("variant").Op(":=").Id("matchInstructionVariant").Call(Id("id")), Id("variant").Op("!=").Nil()).Block(
+			Return(Id("variant").Dot("name")),
+		),
+		Return(Lit("")),
+	).Line()
+}
+
 func addInstructionVariants(ctx *model.GenerateCtx, file *File, program *idl.Idl) {
 	file.Type().Id("Instruction").Struct(
 		Qual(model.PkgDfuseBinary, "BaseVariant"),
@@ -184,19 +254,22 @@ func addInstructionVariants(ctx *model.GenerateCtx, file *File, program *idl.Idl
 		instNameConverter = helper.ToRustSnakeCase
 	}
 
-	file.Var().Id("InstructionImplDef").Op("=").Qual(model.PkgDfuseBinary, "NewVariantDefinition").
-		Parens(
-			implDefParam.Index().Qual(model.PkgDfuseBinary, "VariantType").
-				BlockFunc(func(variantBlock *Group) {
-					for _, instruction := range program.Instructions {
-						insName := instNameConverter(instruction.Name)
-						insExportedName := helper.ToCamelCase(instruction.Name)
-						variantBlock.Block(
-							List(Id("Name").Op(":").Lit(insName), Id("Type").Op(":").Parens(Op("*").Id(insExportedName)).Parens(Nil())).Op(","),
-						).Op(",")
-					}
-				}).Op(",").Line(),
-		).Line()
+	// The instructions with custom discriminators are matched by `matchInstructionVariant` instead of a variant definition.
+	if ctx.DiscriminatorType != model.DiscriminatorTypeCustom {
+		file.Var().Id("InstructionImplDef").Op("=").Qual(model.PkgDfuseBinary, "NewVariantDefinition").
+			Parens(
+				implDefParam.Index().Qual(model.PkgDfuseBinary, "VariantType").
+					BlockFunc(func(variantBlock *Group) {
+						for _, instruction := range program.Instructions {
+							insName := instNameConverter(instruction.Name)
+							insExportedName := helper.ToCamelCase(instruction.Name)
+							variantBlock.Block(
+								List(Id("Name").Op(":").Lit(insName), Id("Type").Op(":").Parens(Op("*").Id(insExportedName)).Parens(Nil())).Op(","),
+							).Op(",")
+						}
+					}).Op(",").Line(),
+			).Line()
+	}
 
 	file.Func().Parens(Id("inst").Op("*").Id("Instruction")).Id("ProgramID").Params().
 		Parens(Qual(model.PkgSolanaGo, "PublicKey")).
@@ -250,6 +323,11 @@ func addInstructionVariants(ctx *model.GenerateCtx, file *File, program *idl.Idl
 			body.Return(Id("encoder").Dot("Encode").Call(Id("inst").Dot("Impl"), Id("option")))
 		})
 
+	if ctx.DiscriminatorType == model.DiscriminatorTypeCustom {
+		addCustomDiscriminatorCodecs(file, program)
+		return
+	}
+
 	file.Func().Params(Id("inst").Op("*").Id("Instruction")).Id("UnmarshalWithDecoder").
 		Params(
 			ListFunc(func(params *Group) {
@@ -299,6 +377,61 @@ func addInstructionVariants(ctx *model.GenerateCtx, file *File, program *idl.Idl
 		})
 }
 
+// addCustomDiscriminatorCodecs declares the codecs of the instructions with discriminators of different lengths.
+func addCustomDiscriminatorCodecs(file *File, program *idl.Idl) {
+	file.Func().Params(Id("inst").Op("*").Id("Instruction")).Id("UnmarshalWithDecoder").
+		Params(Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder")).
+		Params(Error()).
+		Block(
+			List(Id("data"), Err()).Op(":=").Id("decoder").Dot("Peek").Call(Id("decoder").Dot("Remaining").Call()),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			Id("variant").Op(":=").Id("matchInstructionVariant").Call(Id("data")),
+			If(Id("variant").Op("==").Nil()).Block(
+				Return(Qual(model.PkgFmt, "Errorf").Call(Lit("no known instruction for data %x"), Id("data").Index(Op(":").Min(Len(Id("data")), Lit(8))))),
+			),
+			Err().Op("=").Id("decoder").Dot("SkipBytes").Call(Uint().Call(Len(Id("variant").Dot("discriminator")))),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			Id("impl").Op(":=").Id("variant").Dot("new").Call(),
+			If(Err().Op("=").Id("decoder").Dot("Decode").Call(Id("impl")), Err().Op("!=").Nil()).Block(
+				Return(Qual(model.PkgFmt, "Errorf").Call(Lit("unable to decode instruction %s: %w"), Id("variant").Dot("name"), Err())),
+			),
+			Id("inst").Dot("BaseVariant").Op("=").Qual(model.PkgDfuseBinary, "BaseVariant").Values(Dict{
+				Id("TypeID"): Qual(model.PkgDfuseBinary, "TypeIDFromBytes").Call(Id("variant").Dot("discriminator")),
+				Id("Impl"):   Id("impl"),
+			}),
+			Return(Nil()),
+		).Line()
+
+	file.Func().Params(Id("inst").Op("*").Id("Instruction")).Id("MarshalWithEncoder").
+		Params(Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder")).
+		Params(Error()).
+		BlockFunc(func(body *Group) {
+			body.Var().Id("discriminator").Index().Byte()
+			body.Switch(Id("inst").Dot("Impl").Assert(Type())).BlockFunc(func(switchBlock *Group) {
+				for _, instruction := range program.Instructions {
+					insExportedName := helper.ToCamelCase(instruction.Name)
+					switchBlock.Case(Id(insExportedName), Op("*").Id(insExportedName)).Block(
+						Id("discriminator").Op("=").Id(common.GetInstructionEnumName(insExportedName)),
+					)
+				}
+				switchBlock.Default().Block(
+					Return(Qual(model.PkgFmt, "Errorf").Call(Lit("unknown instruction %T"), Id("inst").Dot("Impl"))),
+				)
+			})
+			body.If(
+				Err().Op(":=").Id("encoder").Dot("WriteBytes").Call(Id("discriminator"), False()),
+				Err().Op("!=").Nil(),
+			).Block(
+				Return(Qual(model.PkgFmt, "Errorf").Call(Lit("unable to write variant type: %w"), Err())),
+			)
+			body.Return(Id("encoder").Dot("Encode").Call(Id("inst").Dot("Impl")))
+		})
+}
+
 func addDecoderRegistry(file *File) {
 	file.Func().Id("registryDecodeInstruction").
 		Params(
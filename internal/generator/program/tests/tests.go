@@ -1,7 +1,10 @@
 package tests
 
 import (
+	"fmt"
+
 	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/generator/program/common"
 	"github.com/alivers/anchor-go/internal/idl"
@@ -33,6 +36,10 @@ func genTestingFuncsForInstruction(ctx *model.GenerateCtx, file *File, instructi
 		).
 		BlockFunc(func(body *Group) {
 			// Body:
+			if customType := findCustomSerialization(ctx, program, instruction.Args...); customType != "" {
+				body.Id("t").Dot("Skip").Call(Lit(fmt.Sprintf("%s has a custom serialization, its codec is registered by hand", customType)))
+				return
+			}
 			body.Id("fu").Op(":=").Qual(model.PkgGoFuzz, "New").Call().Dot("NilChance").Call(Lit(0))
 
 			body.For(
@@ -87,14 +94,14 @@ func genTestWithComplexEnum(ctx *model.GenerateCtx, tFunGroup *Group, insExporte
 
 		tFunGroup.BlockFunc(func(enumBlock *Group) {
 			enumName := arg.Type.GetDefined().Name
-			interfaceType := program.FindTypeByName(enumName)
-			for _, variant := range interfaceType.Type.GetEnum().Variants {
+			interfaceType := ctx.GetIdentifierTy(enumName).GetEnum()
+			for _, variant := range interfaceType.Variants {
 				enumBlock.BlockFunc(func(variantBlock *Group) {
 					variantBlock.Id("params").Op(":=").New(Id(insExportedName))
 
 					variantBlock.Id("fu").Dot("Fuzz").Call(Id("params"))
 					variantBlock.Id("params").Dot("AccountMetaSlice").Op("=").Nil()
-					variantBlock.Id("tmp").Op(":=").New(Id(common.GetComplexEnumVariantTypeName(enumName, variant.Name)))
+					variantBlock.Id("tmp").Op(":=").New(idlcode.DefinedTypeIdentCode(ctx, enumName, common.GetComplexEnumVariantTypeName(enumName, variant.Name)))
 					variantBlock.Id("fu").Dot("Fuzz").Call(Id("tmp"))
 					variantBlock.Id("params").Dot("Set" + exportedArgName).Call(Id("tmp"))
 
@@ -130,3 +137,29 @@ func isAnyFieldComplexEnum(ctx *model.GenerateCtx, envelopes ...idl.IdlField) bo
 	}
 	return false
 }
+
+// findCustomSerialization returns the name of a type with custom serialization used by the fields (including the nested ones),
+// the fields can't be encoded without the codec registered by hand.
+func findCustomSerialization(ctx *model.GenerateCtx, program *idl.Idl, fields ...idl.IdlField) string {
+	found := ""
+	visited := map[string]bool{}
+	var visit func(_ string, typ *idl.IdlType)
+	visit = func(_ string, typ *idl.IdlType) {
+		if found != "" || !typ.IsDefined() {
+			return
+		}
+		name := typ.GetDefined().Name
+		if ctx.IsCustomSerialization(name) {
+			found = name
+			return
+		}
+		if def := program.FindTypeByName(name); def != nil && !visited[name] {
+			visited[name] = true
+			def.Type.VisitTypes("", visit)
+		}
+	}
+	for i := range fields {
+		fields[i].Type.VisitTypes("", visit)
+	}
+	return found
+}
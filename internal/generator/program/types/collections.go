@@ -0,0 +1,287 @@
+package types
+
+import (
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/generator/program/common"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// UsesCollections reports whether the program uses maps or sets, which are declared by `GenerateCollections`.
+func UsesCollections(program *idl.Idl) bool {
+	found := false
+	program.VisitTypes(func(_ string, typ *idl.IdlType) {
+		found = found || typ.IsHashMap() || typ.IsHashSet()
+	})
+	return found
+}
+
+// GenerateCollections declares the maps and the sets. Go maps are unordered, so the codecs sort the keys
+// to get the deterministic Borsh encoding of Rust, which writes the entries in the ascending order of the keys.
+func GenerateCollections(ctx *model.GenerateCtx) *File {
+	file := helper.NewGoFile(ctx)
+
+	file.Comment("StrictCollectionOrder makes the decoding of the maps and the sets reject the keys which are not")
+	file.Comment("in strictly ascending order (i.e. unsorted or duplicate keys), like the `de_strict_order` feature of borsh-rs.")
+	file.Comment("Otherwise the entries are accepted in any order, and the last one wins for a duplicate key.")
+	file.Var().Id("StrictCollectionOrder").Op("=").False()
+	file.Line()
+
+	mapType := func() *Statement { return Id(idlcode.OrderedMapTypeName).Types(Id("K"), Id("V")) }
+	setType := func() *Statement { return Id(idlcode.OrderedSetTypeName).Types(Id("T")) }
+
+	file.Comment("OrderedMap is the `HashMap` or `BTreeMap` of Rust, its entries are encoded in the ascending order of the keys.")
+	file.Type().Id(idlcode.OrderedMapTypeName).Types(Id("K").Id("comparable"), Id("V").Id("any")).Map(Id("K")).Id("V")
+	file.Line()
+
+	file.Comment("Keys returns the keys in ascending order.")
+	file.Func().Params(Id("m").Add(mapType())).Id("Keys").Params().Index().Id("K").Block(
+		Id("keys").Op(":=").Make(Index().Id("K"), Lit(0), Len(Id("m"))),
+		For(Id("key").Op(":=").Range().Id("m")).Block(
+			Id("keys").Op("=").Append(Id("keys"), Id("key")),
+		),
+		sortKeysCode("keys", "K"),
+		Return(Id("keys")),
+	)
+	file.Line()
+
+	file.Func().Params(Id("m").Add(mapType())).Id("MarshalWithEncoder").Params(Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder")).Error().Block(
+		Id("keys").Op(":=").Id("m").Dot("Keys").Call(),
+		checkDistinctKeysCode("map", "keys"),
+		Err().Op(":=").Id("encoder").Dot("WriteUint32").Call(Uint32().Call(Len(Id("m"))), Qual(model.PkgEncodingBinary, "LittleEndian")),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		),
+		For(List(Id("_"), Id("key")).Op(":=").Range().Id("keys")).Block(
+			Err().Op("=").Id("encoder").Dot("Encode").Call(Id("key")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			Err().Op("=").Id("encoder").Dot("Encode").Call(Id("m").Index(Id("key"))),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+		),
+		Return(Nil()),
+	)
+	file.Line()
+
+	file.Func().Params(Id("m").Op("*").Add(mapType())).Id("UnmarshalWithDecoder").Params(Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder")).Error().BlockFunc(func(body *Group) {
+		readLengthCode(body)
+		body.Op("*").Id("m").Op("=").Make(mapType(), Min(Id("length"), Uint32().Call(Id("decoder").Dot("Remaining").Call())))
+		body.Var().Id("previous").Id("K")
+		body.For(Id("i").Op(":=").Range().Id("length")).Block(
+			Var().Id("key").Id("K"),
+			Err().Op("=").Id("decoder").Dot("Decode").Call(Op("&").Id("key")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			checkOrderCode("map"),
+			Var().Id("value").Id("V"),
+			Err().Op("=").Id("decoder").Dot("Decode").Call(Op("&").Id("value")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			Parens(Op("*").Id("m")).Index(Id("key")).Op("=").Id("value"),
+			Id("previous").Op("=").Id("key"),
+		)
+		body.Return(Nil())
+	})
+	file.Line()
+
+	file.Comment("OrderedSet is the `HashSet` or `BTreeSet` of Rust, its elements are encoded in ascending order.")
+	file.Type().Id(idlcode.OrderedSetTypeName).Types(Id("T").Id("comparable")).Map(Id("T")).Struct()
+	file.Line()
+
+	file.Comment("Elems returns the elements in ascending order.")
+	file.Func().Params(Id("s").Add(setType())).Id("Elems").Params().Index().Id("T").Block(
+		Id("elems").Op(":=").Make(Index().Id("T"), Lit(0), Len(Id("s"))),
+		For(Id("elem").Op(":=").Range().Id("s")).Block(
+			Id("elems").Op("=").Append(Id("elems"), Id("elem")),
+		),
+		sortKeysCode("elems", "T"),
+		Return(Id("elems")),
+	)
+	file.Line()
+
+	file.Func().Params(Id("s").Add(setType())).Id("MarshalWithEncoder").Params(Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder")).Error().Block(
+		Id("elems").Op(":=").Id("s").Dot("Elems").Call(),
+		checkDistinctKeysCode("set", "elems"),
+		Err().Op(":=").Id("encoder").Dot("WriteUint32").Call(Uint32().Call(Len(Id("s"))), Qual(model.PkgEncodingBinary, "LittleEndian")),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		),
+		For(List(Id("_"), Id("elem")).Op(":=").Range().Id("elems")).Block(
+			Err().Op("=").Id("encoder").Dot("Encode").Call(Id("elem")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+		),
+		Return(Nil()),
+	)
+	file.Line()
+
+	file.Func().Params(Id("s").Op("*").Add(setType())).Id("UnmarshalWithDecoder").Params(Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder")).Error().BlockFunc(func(body *Group) {
+		readLengthCode(body)
+		body.Op("*").Id("s").Op("=").Make(setType(), Min(Id("length"), Uint32().Call(Id("decoder").Dot("Remaining").Call())))
+		body.Var().Id("previous").Id("T")
+		body.For(Id("i").Op(":=").Range().Id("length")).Block(
+			Var().Id("key").Id("T"),
+			Err().Op("=").Id("decoder").Dot("Decode").Call(Op("&").Id("key")),
+			If(Err().Op("!=").Nil()).Block(
+				Return(Err()),
+			),
+			checkOrderCode("set"),
+			Parens(Op("*").Id("s")).Index(Id("key")).Op("=").Struct().Values(),
+			Id("previous").Op("=").Id("key"),
+		)
+		body.Return(Nil())
+	})
+	file.Line()
+
+	generateCompareKeys(file)
+
+	return file
+}
+
+// sortKeysCode sorts the slice of the keys, whose type is the type param `typeParam`.
+func sortKeysCode(name string, typeParam string) Code {
+	return Qual("slices", "SortFunc").Call(Id(name), Func().Params(List(Id("a"), Id("b")).Id(typeParam)).Int().Block(
+		Return(Id("compareKeys").Call(Qual("reflect", "ValueOf").Call(Id("a")), Qual("reflect", "ValueOf").Call(Id("b")))),
+	))
+}
+
+// checkDistinctKeysCode rejects the sorted keys which compare equal, e.g. two pointers to equal variants of a complex enum
+// are different Go keys, but they would be encoded as a duplicate key.
+func checkDistinctKeysCode(kind string, name string) Code {
+	return For(Id("i").Op(":=").Lit(1), Id("i").Op("<").Len(Id(name)), Id("i").Op("++")).Block(
+		If(Id("compareKeys").Call(Qual("reflect", "ValueOf").Call(Id(name).Index(Id("i").Op("-").Lit(1))), Qual("reflect", "ValueOf").Call(Id(name).Index(Id("i")))).Op("==").Lit(0)).Block(
+			Return(Qual(model.PkgFmt, "Errorf").Call(Lit("key %d of the "+kind+" is equal to the previous one"), Id("i"))),
+		),
+	)
+}
+
+func readLengthCode(body *Group) {
+	body.List(Id("length"), Err()).Op(":=").Id("decoder").Dot("ReadUint32").Call(Qual(model.PkgEncodingBinary, "LittleEndian"))
+	body.If(Err().Op("!=").Nil()).Block(
+		Return(Err()),
+	)
+}
+
+func checkOrderCode(kind string) Code {
+	return If(
+		Id("StrictCollectionOrder").Op("&&").Id("i").Op(">").Lit(0).Op("&&").
+			Id("compareKeys").Call(Qual("reflect", "ValueOf").Call(Id("previous")), Qual("reflect", "ValueOf").Call(Id("key"))).Op(">=").Lit(0),
+	).Block(
+		Return(Qual(model.PkgFmt, "Errorf").Call(Lit("key %d of the "+kind+" is not greater than the previous one"), Id("i"))),
+	)
+}
+
+// generateCompareKeys declares the comparison of the keys, which follows the derived `Ord` of Rust.
+func generateCompareKeys(file *File) {
+	file.Comment("bigIntKey is implemented by the 128-bit and 256-bit integers, which are compared by their values.")
+	file.Type().Id("bigIntKey").Interface(
+		Id("BigInt").Params().Op("*").Qual(model.PkgBigInt, "Int"),
+	)
+	file.Line()
+
+	file.Comment("enumVariantKey is implemented by the variants of the complex enums, which are compared by their index first.")
+	file.Type().Id("enumVariantKey").Interface(
+		Id(common.ComplexEnumVariantIndexMethodName).Params().Uint8(),
+	)
+	file.Line()
+
+	file.Comment("compareKeys compares the keys like the derived `Ord` of Rust: the numbers by their values,")
+	file.Comment("the strings and the arrays lexicographically, the structs field by field, and the variants")
+	file.Comment("of the complex enums by their index, then by their fields if they are the same variant.")
+	file.Comment("The other keys are compared by their Borsh encodings.")
+	file.Func().Id("compareKeys").Params(List(Id("a"), Id("b")).Qual("reflect", "Value")).Int().Block(
+		Comment("A nil enum is less than any variant."),
+		If(Op("!").Id("a").Dot("IsValid").Call().Op("||").Op("!").Id("b").Dot("IsValid").Call()).Block(
+			Return(Qual("cmp", "Compare").Call(Id("boolToInt").Call(Id("a").Dot("IsValid").Call()), Id("boolToInt").Call(Id("b").Dot("IsValid").Call()))),
+		),
+		If(Id("a").Dot("CanInterface").Call().Op("&&").Id("b").Dot("CanInterface").Call()).Block(
+			List(Id("bigA"), Id("okA")).Op(":=").Id("a").Dot("Interface").Call().Assert(Id("bigIntKey")),
+			List(Id("bigB"), Id("okB")).Op(":=").Id("b").Dot("Interface").Call().Assert(Id("bigIntKey")),
+			If(Id("okA").Op("&&").Id("okB")).Block(
+				Return(Id("bigA").Dot("BigInt").Call().Dot("Cmp").Call(Id("bigB").Dot("BigInt").Call())),
+			),
+			List(Id("variantA"), Id("okA")).Op(":=").Id("a").Dot("Interface").Call().Assert(Id("enumVariantKey")),
+			List(Id("variantB"), Id("okB")).Op(":=").Id("b").Dot("Interface").Call().Assert(Id("enumVariantKey")),
+			If(Id("okA").Op("&&").Id("okB")).Block(
+				If(
+					Id("c").Op(":=").Qual("cmp", "Compare").Call(Id("variantA").Dot(common.ComplexEnumVariantIndexMethodName).Call(), Id("variantB").Dot(common.ComplexEnumVariantIndexMethodName).Call()),
+					Id("c").Op("!=").Lit(0),
+				).Block(
+					Return(Id("c")),
+				),
+			),
+		),
+		Comment("The fields can only be compared between the values of the same type."),
+		If(Id("a").Dot("Type").Call().Op("!=").Id("b").Dot("Type").Call()).Block(
+			Return(Id("compareEncodings").Call(Id("a"), Id("b"))),
+		),
+		Switch(Id("a").Dot("Kind").Call()).Block(
+			Case(Qual("reflect", "Bool")).Block(
+				Return(Qual("cmp", "Compare").Call(Id("boolToInt").Call(Id("a").Dot("Bool").Call()), Id("boolToInt").Call(Id("b").Dot("Bool").Call()))),
+			),
+			Case(Qual("reflect", "Int"), Qual("reflect", "Int8"), Qual("reflect", "Int16"), Qual("reflect", "Int32"), Qual("reflect", "Int64")).Block(
+				Return(Qual("cmp", "Compare").Call(Id("a").Dot("Int").Call(), Id("b").Dot("Int").Call())),
+			),
+			Case(Qual("reflect", "Uint"), Qual("reflect", "Uint8"), Qual("reflect", "Uint16"), Qual("reflect", "Uint32"), Qual("reflect", "Uint64")).Block(
+				Return(Qual("cmp", "Compare").Call(Id("a").Dot("Uint").Call(), Id("b").Dot("Uint").Call())),
+			),
+			Case(Qual("reflect", "Float32"), Qual("reflect", "Float64")).Block(
+				Return(Qual("cmp", "Compare").Call(Id("a").Dot("Float").Call(), Id("b").Dot("Float").Call())),
+			),
+			Case(Qual("reflect", "String")).Block(
+				Return(Qual("strings", "Compare").Call(Id("a").Dot("String").Call(), Id("b").Dot("String").Call())),
+			),
+			Case(Qual("reflect", "Array"), Qual("reflect", "Slice")).Block(
+				For(Id("i").Op(":=").Range().Min(Id("a").Dot("Len").Call(), Id("b").Dot("Len").Call())).Block(
+					If(Id("c").Op(":=").Id("compareKeys").Call(Id("a").Dot("Index").Call(Id("i")), Id("b").Dot("Index").Call(Id("i"))), Id("c").Op("!=").Lit(0)).Block(
+						Return(Id("c")),
+					),
+				),
+				Return(Qual("cmp", "Compare").Call(Id("a").Dot("Len").Call(), Id("b").Dot("Len").Call())),
+			),
+			Case(Qual("reflect", "Struct")).Block(
+				For(Id("i").Op(":=").Range().Id("a").Dot("NumField").Call()).Block(
+					If(Id("c").Op(":=").Id("compareKeys").Call(Id("a").Dot("Field").Call(Id("i")), Id("b").Dot("Field").Call(Id("i"))), Id("c").Op("!=").Lit(0)).Block(
+						Return(Id("c")),
+					),
+				),
+				Return(Lit(0)),
+			),
+			Case(Qual("reflect", "Pointer")).Block(
+				Comment("An option, `None` is less than `Some`."),
+				If(Id("a").Dot("IsNil").Call().Op("||").Id("b").Dot("IsNil").Call()).Block(
+					Return(Qual("cmp", "Compare").Call(Id("boolToInt").Call(Op("!").Id("a").Dot("IsNil").Call()), Id("boolToInt").Call(Op("!").Id("b").Dot("IsNil").Call()))),
+				),
+				Return(Id("compareKeys").Call(Id("a").Dot("Elem").Call(), Id("b").Dot("Elem").Call())),
+			),
+			Case(Qual("reflect", "Interface")).Block(
+				Comment("A complex enum in a struct, its variant is compared."),
+				Return(Id("compareKeys").Call(Id("a").Dot("Elem").Call(), Id("b").Dot("Elem").Call())),
+			),
+		),
+		Return(Id("compareEncodings").Call(Id("a"), Id("b"))),
+	)
+	file.Line()
+
+	file.Func().Id("compareEncodings").Params(List(Id("a"), Id("b")).Qual("reflect", "Value")).Int().Block(
+		List(Id("encodedA"), Id("_")).Op(":=").Qual(model.PkgDfuseBinary, "MarshalBorsh").Call(Id("a").Dot("Interface").Call()),
+		List(Id("encodedB"), Id("_")).Op(":=").Qual(model.PkgDfuseBinary, "MarshalBorsh").Call(Id("b").Dot("Interface").Call()),
+		Return(Qual(model.PkgBytes, "Compare").Call(Id("encodedA"), Id("encodedB"))),
+	)
+	file.Line()
+
+	file.Func().Id("boolToInt").Params(Id("b").Bool()).Int().Block(
+		If(Id("b")).Block(
+			Return(Lit(1)),
+		),
+		Return(Lit(0)),
+	)
+}
@@ -0,0 +1,153 @@
+package types
+
+import (
+	"github.com/alivers/anchor-go/internal/generator/helper"
+	"github.com/alivers/anchor-go/internal/generator/idlcode"
+	"github.com/alivers/anchor-go/internal/generator/model"
+	"github.com/alivers/anchor-go/internal/idl"
+	. "github.com/dave/jennifer/jen"
+)
+
+// UsesInt256 reports whether the program uses `u256` or `i256`, which are declared by `GenerateInt256`.
+func UsesInt256(ctx *model.GenerateCtx, program *idl.Idl) bool {
+	uses := func(simple idl.IdlTypeSimple) bool {
+		if simple != idl.IdlTypeSimpleU256 && simple != idl.IdlTypeSimpleI256 {
+			return false
+		}
+		_, overridden := ctx.GetTypeOverride(simple.String())
+		return !overridden
+	}
+	found := false
+	program.VisitTypes(func(_ string, typ *idl.IdlType) {
+		found = found || (typ.IsSimple() && uses(typ.GetSimple()))
+	})
+	return found
+}
+
+// GenerateInt256 declares the 256-bit integers, they are stored as 32 little-endian bytes like their Borsh encoding.
+func GenerateInt256(ctx *model.GenerateCtx) *File {
+	file := helper.NewGoFile(ctx)
+
+	file.Comment("Uint256 is the `u256` of Rust, stored as 32 little-endian bytes.")
+	file.Type().Id(idlcode.Uint256TypeName).Index(Lit(32)).Byte()
+	file.Line()
+
+	file.Comment("NewUint256 converts the value, it fails if the value is negative or doesn't fit in 256 bits.")
+	file.Func().Id("NewUint256").Params(Id("value").Op("*").Qual(model.PkgBigInt, "Int")).Params(Id(idlcode.Uint256TypeName), Error()).Block(
+		Var().Id("out").Id(idlcode.Uint256TypeName),
+		If(Id("value").Dot("Sign").Call().Op("<").Lit(0).Op("||").Id("value").Dot("BitLen").Call().Op(">").Lit(256)).Block(
+			Return(Id("out"), Qual(model.PkgFmt, "Errorf").Call(Lit("%s overflows u256"), Id("value"))),
+		),
+		Id("value").Dot("FillBytes").Call(Id("out").Index(Op(":"))),
+		Qual("slices", "Reverse").Call(Id("out").Index(Op(":"))),
+		Return(Id("out"), Nil()),
+	)
+	file.Line()
+
+	file.Comment("BigInt returns the value as a big integer.")
+	file.Func().Params(Id("u").Id(idlcode.Uint256TypeName)).Id("BigInt").Params().Op("*").Qual(model.PkgBigInt, "Int").Block(
+		Qual("slices", "Reverse").Call(Id("u").Index(Op(":"))),
+		Return(New(Qual(model.PkgBigInt, "Int")).Dot("SetBytes").Call(Id("u").Index(Op(":")))),
+	)
+	file.Line()
+
+	file.Comment("Int256 is the `i256` of Rust, stored as 32 little-endian bytes in two's complement.")
+	file.Type().Id(idlcode.Int256TypeName).Index(Lit(32)).Byte()
+	file.Line()
+
+	file.Comment("NewInt256 converts the value, it fails if the value doesn't fit in 256 bits.")
+	file.Func().Id("NewInt256").Params(Id("value").Op("*").Qual(model.PkgBigInt, "Int")).Params(Id(idlcode.Int256TypeName), Error()).Block(
+		Var().Id("out").Id(idlcode.Int256TypeName),
+		Id("limit").Op(":=").New(Qual(model.PkgBigInt, "Int")).Dot("Lsh").Call(Qual(model.PkgBigInt, "NewInt").Call(Lit(1)), Lit(255)),
+		If(Id("value").Dot("Cmp").Call(New(Qual(model.PkgBigInt, "Int")).Dot("Neg").Call(Id("limit"))).Op("<").Lit(0).Op("||").Id("value").Dot("Cmp").Call(Id("limit")).Op(">=").Lit(0)).Block(
+			Return(Id("out"), Qual(model.PkgFmt, "Errorf").Call(Lit("%s overflows i256"), Id("value"))),
+		),
+		Id("unsigned").Op(":=").New(Qual(model.PkgBigInt, "Int")).Dot("Set").Call(Id("value")),
+		If(Id("value").Dot("Sign").Call().Op("<").Lit(0)).Block(
+			Id("unsigned").Dot("Add").Call(Id("unsigned"), New(Qual(model.PkgBigInt, "Int")).Dot("Lsh").Call(Id("limit"), Lit(1))),
+		),
+		Id("unsigned").Dot("FillBytes").Call(Id("out").Index(Op(":"))),
+		Qual("slices", "Reverse").Call(Id("out").Index(Op(":"))),
+		Return(Id("out"), Nil()),
+	)
+	file.Line()
+
+	file.Comment("BigInt returns the value as a big integer.")
+	file.Func().Params(Id("i").Id(idlcode.Int256TypeName)).Id("BigInt").Params().Op("*").Qual(model.PkgBigInt, "Int").Block(
+		Id("value").Op(":=").Id(idlcode.Uint256TypeName).Call(Id("i")).Dot("BigInt").Call(),
+		If(Id("i").Index(Lit(31)).Op("&").Lit(0x80).Op("!=").Lit(0)).Block(
+			Id("value").Dot("Sub").Call(Id("value"), New(Qual(model.PkgBigInt, "Int")).Dot("Lsh").Call(Qual(model.PkgBigInt, "NewInt").Call(Lit(1)), Lit(256))),
+		),
+		Return(Id("value")),
+	)
+
+	for _, typeName := range []string{idlcode.Uint256TypeName, idlcode.Int256TypeName} {
+		generateInt256Codecs(file, typeName)
+	}
+
+	return file
+}
+
+func generateInt256Codecs(file *File, typeName string) {
+	receiver := func() *Statement { return Id("v").Id(typeName) }
+	pointerReceiver := func() *Statement { return Id("v").Op("*").Id(typeName) }
+
+	file.Line()
+	file.Func().Params(receiver()).Id("String").Params().String().Block(
+		Return(Id("v").Dot("BigInt").Call().Dot("String").Call()),
+	)
+
+	file.Line()
+	file.Func().Params(receiver()).Id("MarshalText").Params().Params(Index().Byte(), Error()).Block(
+		Return(Index().Byte().Call(Id("v").Dot("String").Call()), Nil()),
+	)
+
+	file.Line()
+	file.Comment("UnmarshalText accepts a decimal or a prefixed (e.g. `0x`) integer.")
+	file.Func().Params(pointerReceiver()).Id("UnmarshalText").Params(Id("text").Index().Byte()).Error().Block(
+		List(Id("value"), Id("ok")).Op(":=").New(Qual(model.PkgBigInt, "Int")).Dot("SetString").Call(String().Call(Id("text")), Lit(0)),
+		If(Op("!").Id("ok")).Block(
+			Return(Qual(model.PkgFmt, "Errorf").Call(Lit("invalid integer %q"), Id("text"))),
+		),
+		List(Id("parsed"), Err()).Op(":=").Id("New"+typeName).Call(Id("value")),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		),
+		Op("*").Id("v").Op("=").Id("parsed"),
+		Return(Nil()),
+	)
+
+	file.Line()
+	file.Comment("MarshalJSON encodes the value as a decimal string, since it doesn't fit in a JSON number.")
+	file.Func().Params(receiver()).Id("MarshalJSON").Params().Params(Index().Byte(), Error()).Block(
+		Return(Qual("encoding/json", "Marshal").Call(Id("v").Dot("String").Call())),
+	)
+
+	file.Line()
+	file.Comment("UnmarshalJSON accepts a string or a number.")
+	file.Func().Params(pointerReceiver()).Id("UnmarshalJSON").Params(Id("data").Index().Byte()).Error().Block(
+		If(String().Call(Id("data")).Op("==").Lit("null")).Block(
+			Return(Nil()),
+		),
+		Var().Id("text").String(),
+		If(Qual("encoding/json", "Unmarshal").Call(Id("data"), Op("&").Id("text")).Op("!=").Nil()).Block(
+			Id("text").Op("=").String().Call(Id("data")),
+		),
+		Return(Id("v").Dot("UnmarshalText").Call(Index().Byte().Call(Id("text")))),
+	)
+
+	file.Line()
+	file.Func().Params(receiver()).Id("MarshalWithEncoder").Params(Id("encoder").Op("*").Qual(model.PkgDfuseBinary, "Encoder")).Error().Block(
+		Return(Id("encoder").Dot("WriteBytes").Call(Id("v").Index(Op(":")), False())),
+	)
+
+	file.Line()
+	file.Func().Params(pointerReceiver()).Id("UnmarshalWithDecoder").Params(Id("decoder").Op("*").Qual(model.PkgDfuseBinary, "Decoder")).Error().Block(
+		List(Id("data"), Err()).Op(":=").Id("decoder").Dot("ReadNBytes").Call(Lit(32)),
+		If(Err().Op("!=").Nil()).Block(
+			Return(Err()),
+		),
+		Copy(Id("v").Index(Op(":")), Id("data")),
+		Return(Nil()),
+	)
+}
@@ -1,6 +1,10 @@
 package types
 
 import (
+	"fmt"
+	"maps"
+	"slices"
+
 	"github.com/alivers/anchor-go/internal/generator/helper"
 	"github.com/alivers/anchor-go/internal/generator/model"
 	"github.com/alivers/anchor-go/internal/generator/program/common"
@@ -12,10 +16,16 @@ func GenerateTypes(ctx *model.GenerateCtx, program *idl.Idl) *File {
 	file := helper.NewGoFile(ctx)
 
 	// Generate types for all IDL types
-	for _, typ := range program.Types {
+	for i, typ := range program.Types {
+		// Shared types are declared in the package of the program which owns them.
+		if ctx.IsExternalType(typ.Name) {
+			continue
+		}
+
 		identType := ctx.GetIdentifierTy(typ.Name)
 		if identType == nil {
-			panic("type " + typ.Name + " not found in IDL types")
+			ctx.Errorf(fmt.Sprintf("types[%d]", i), "type %s not found in IDL types", typ.Name)
+			continue
 		}
 
 		typeName := typ.Name
@@ -27,13 +37,25 @@ func GenerateTypes(ctx *model.GenerateCtx, program *idl.Idl) *File {
 		file.Add(
 			common.GenerateTypeDefCode(
 				ctx,
+				typ.Name,
 				typeName,
 				identType,
 				nil,
+				ctx.GetLayout(typ.Name),
+				helper.StrIf(ctx.IsCustomSerialization(typ.Name), typ.Name),
 				program,
 			),
 		)
 	}
 
+	// The variants container of a complex enum is non-exported,
+	// so declare it for the shared complex enums which are used by this program.
+	for _, name := range slices.Sorted(maps.Keys(program.ReferencedTypeNames())) {
+		if !ctx.IsExternalType(name) || !ctx.IsComplexEnumByTypeName(name) {
+			continue
+		}
+		file.Add(common.GenerateComplexEnumContainerCode(ctx, name, ctx.GetIdentifierTy(name).GetEnum())).Line()
+	}
+
 	return file
 }
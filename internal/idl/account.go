@@ -128,6 +128,17 @@ func (item *IdlInstructionAccountItem) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+func (item IdlInstructionAccountItem) MarshalJSON() ([]byte, error) {
+	switch {
+	case item.IsAccount():
+		return json.Marshal(item.GetAccount())
+	case item.IsAccounts():
+		return json.Marshal(item.GetAccounts())
+	default:
+		return nil, errors.New("unable to marshal empty IdlInstructionAccountItem")
+	}
+}
+
 func (seed *IdlSeed) IsConst() bool {
 	return seed.IdlSeedConst != nil
 }
@@ -192,3 +203,20 @@ func (idlSeed *IdlSeed) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+func (idlSeed IdlSeed) MarshalJSON() ([]byte, error) {
+	switch {
+	case idlSeed.IsConst():
+		// The value is an array of numbers, rather than the base64 string of `[]byte`.
+		return json.Marshal(struct {
+			Kind  string    `json:"kind"`
+			Value byteArray `json:"value"`
+		}{Kind: "const", Value: idlSeed.GetConst().Value})
+	case idlSeed.IsArg():
+		return json.Marshal(IdlSeedArg{Kind: "arg", Path: idlSeed.GetArg().Path})
+	case idlSeed.IsAccount():
+		return json.Marshal(IdlSeedAccount{Kind: "account", Path: idlSeed.GetAccount().Path, Account: idlSeed.GetAccount().Account})
+	default:
+		return nil, errors.New("unable to marshal empty IdlSeed")
+	}
+}
@@ -0,0 +1,68 @@
+package idl
+
+import (
+	"reflect"
+)
+
+// Clone returns a deep copy of the IDL, so it can be modified without affecting the original one.
+func (idl *Idl) Clone() *Idl {
+	if idl == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(idl)).Interface().(*Idl)
+}
+
+func deepCopy(src reflect.Value) reflect.Value {
+	switch src.Kind() {
+	case reflect.Pointer:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.New(src.Type().Elem())
+		dst.Elem().Set(deepCopy(src.Elem()))
+		return dst
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := range src.Len() {
+			dst.Index(i).Set(deepCopy(src.Index(i)))
+		}
+		return dst
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeMapWithSize(src.Type(), src.Len())
+		iter := src.MapRange()
+		for iter.Next() {
+			dst.SetMapIndex(iter.Key(), deepCopy(iter.Value()))
+		}
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(src.Type()).Elem()
+		for i := range src.NumField() {
+			dst.Field(i).Set(deepCopy(src.Field(i)))
+		}
+		return dst
+	default:
+		return src
+	}
+}
+
+// Clone returns a deep copy of the type definition.
+func (def *IdlTypeDef) Clone() *IdlTypeDef {
+	if def == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(def)).Interface().(*IdlTypeDef)
+}
+
+// Clone returns a deep copy of the type.
+func (idlType *IdlType) Clone() *IdlType {
+	if idlType == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(idlType)).Interface().(*IdlType)
+}
@@ -0,0 +1,393 @@
+// Package diff compares two versions of the IDL of a program, and reports the changes
+// which break the existing clients or the data on-chain.
+package diff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"slices"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+type Impact string
+
+const (
+	// ImpactBreaking is a change which breaks the existing clients or the data on-chain.
+	ImpactBreaking Impact = "breaking"
+	// ImpactSafe is an additive change which is compatible with the existing clients and data.
+	ImpactSafe Impact = "safe"
+)
+
+func (i Impact) String() string {
+	return string(i)
+}
+
+// Change is a difference between the old and the new IDL.
+type Change struct {
+	// Location in the IDL, items are referenced by their names, e.g. `instructions[deposit].args[amount]`.
+	Path    string
+	Message string
+	Impact  Impact
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("[%s] %s: %s", c.Impact, c.Path, c.Message)
+}
+
+type Changes []Change
+
+// HasBreaking reports whether there is any breaking change.
+func (c Changes) HasBreaking() bool {
+	return slices.ContainsFunc(c, func(change Change) bool {
+		return change.Impact == ImpactBreaking
+	})
+}
+
+// Compare reports the changes from the old IDL to the new one, in the order of the IDL sections.
+// The changes of the docs and of the metadata except the address are ignored.
+func Compare(old, new *idl.Idl) Changes {
+	c := &comparer{}
+	if old.Address != new.Address {
+		c.breaking("address", "program address changed from %s to %s", old.Address, new.Address)
+	}
+	c.compareInstructions(old.Instructions, new.Instructions)
+	c.compareAccounts(old.Accounts, new.Accounts)
+	c.compareEvents(old.Events, new.Events)
+	c.compareTypes(old, new)
+	c.compareErrors(old.Errors, new.Errors)
+	c.compareConstants(old.Constants, new.Constants)
+	return c.changes
+}
+
+type comparer struct {
+	changes Changes
+}
+
+func (c *comparer) breaking(path string, format string, args ...any) {
+	c.changes = append(c.changes, Change{Path: path, Message: fmt.Sprintf(format, args...), Impact: ImpactBreaking})
+}
+
+func (c *comparer) safe(path string, format string, args ...any) {
+	c.changes = append(c.changes, Change{Path: path, Message: fmt.Sprintf(format, args...), Impact: ImpactSafe})
+}
+
+// matchByName calls fn for each pair of items with the same name, and reports the removed and added items.
+func matchByName[T any](c *comparer, section, kind string, old, new []T, name func(*T) string, fn func(path string, old, new *T)) {
+	for i := range old {
+		path := fmt.Sprintf("%s[%s]", section, name(&old[i]))
+		j := slices.IndexFunc(new, func(item T) bool { return name(&item) == name(&old[i]) })
+		if j < 0 {
+			c.breaking(path, "%s removed", kind)
+			continue
+		}
+		fn(path, &old[i], &new[j])
+	}
+	for i := range new {
+		if !slices.ContainsFunc(old, func(item T) bool { return name(&item) == name(&new[i]) }) {
+			c.safe(fmt.Sprintf("%s[%s]", section, name(&new[i])), "%s added", kind)
+		}
+	}
+}
+
+func (c *comparer) compareDiscriminator(path string, old, new idl.IdlDiscriminator) {
+	if !bytes.Equal(old, new) {
+		c.breaking(path+".discriminator", "discriminator changed from %v to %v", []byte(old), []byte(new))
+	}
+}
+
+func (c *comparer) compareInstructions(old, new []idl.IdlInstruction) {
+	name := func(inst *idl.IdlInstruction) string { return inst.Name }
+	matchByName(c, "instructions", "instruction", old, new, name, func(path string, old, new *idl.IdlInstruction) {
+		c.compareDiscriminator(path, old.Discriminator, new.Discriminator)
+		if jsonString(old.Discriminant) != jsonString(new.Discriminant) {
+			c.breaking(path+".discriminant", "discriminant changed from %s to %s", jsonString(old.Discriminant), jsonString(new.Discriminant))
+		}
+		c.compareInstructionAccounts(path, old, new)
+		// The existing clients don't send the new arguments, so even the trailing ones are breaking.
+		c.compareFields(path+".args", "argument", old.Args, new.Args, false)
+		if typeString(old.Returns) != typeString(new.Returns) {
+			c.breaking(path+".returns", "return type changed from %s to %s", typeString(old.Returns), typeString(new.Returns))
+		}
+	})
+}
+
+func (c *comparer) compareInstructionAccounts(path string, oldInst, newInst *idl.IdlInstruction) {
+	old, new := oldInst.GetAccounts(), newInst.GetAccounts()
+	indexOf := func(accounts []*idl.IdlInstructionAccount, name string) int {
+		return slices.IndexFunc(accounts, func(account *idl.IdlInstructionAccount) bool { return account.Name == name })
+	}
+
+	for i, oldAccount := range old {
+		accountPath := fmt.Sprintf("%s.accounts[%s]", path, oldAccount.Name)
+		j := indexOf(new, oldAccount.Name)
+		if j < 0 {
+			c.breaking(accountPath, "account removed")
+			continue
+		}
+		if i != j {
+			c.breaking(accountPath, "account moved from position %d to %d", i, j)
+		}
+
+		newAccount := new[j]
+		c.compareFlag(accountPath, "writable", oldAccount.Writable, newAccount.Writable)
+		c.compareFlag(accountPath, "signer", oldAccount.Signer, newAccount.Signer)
+		// A required account is still passed by the existing clients when it becomes optional.
+		c.compareFlag(accountPath, "required", !oldAccount.Optional, !newAccount.Optional)
+		if stringValue(oldAccount.Address) != stringValue(newAccount.Address) {
+			switch {
+			case newAccount.Address == nil:
+				c.safe(accountPath+".address", "fixed address %s removed", *oldAccount.Address)
+			case oldAccount.Address == nil:
+				c.breaking(accountPath+".address", "fixed address %s added", *newAccount.Address)
+			default:
+				c.breaking(accountPath+".address", "fixed address changed from %s to %s", *oldAccount.Address, *newAccount.Address)
+			}
+		}
+		if jsonString(oldAccount.Pda) != jsonString(newAccount.Pda) {
+			switch {
+			case newAccount.Pda == nil:
+				c.safe(accountPath+".pda", "PDA constraint removed")
+			case oldAccount.Pda == nil:
+				c.breaking(accountPath+".pda", "PDA constraint added with seeds %s", jsonString(newAccount.Pda))
+			default:
+				c.breaking(accountPath+".pda", "PDA seeds changed from %s to %s", jsonString(oldAccount.Pda), jsonString(newAccount.Pda))
+			}
+		}
+	}
+	for _, newAccount := range new {
+		if indexOf(old, newAccount.Name) < 0 {
+			// Even an optional account must be passed (as the program ID) by the clients.
+			c.breaking(fmt.Sprintf("%s.accounts[%s]", path, newAccount.Name), "account added")
+		}
+	}
+}
+
+// compareFlag reports a flag which becomes required as breaking, and one which is relaxed as safe.
+func (c *comparer) compareFlag(path, flag string, old, new bool) {
+	switch {
+	case !old && new:
+		c.breaking(path, "account becomes %s", flag)
+	case old && !new:
+		c.safe(path, "account is no longer %s", flag)
+	}
+}
+
+// compareFields compares the borsh layout of the fields, which is their order and types.
+// The new trailing fields are safe if trailingSafe is set.
+func (c *comparer) compareFields(path, kind string, old, new []idl.IdlField, trailingSafe bool) {
+	indexOf := func(fields []idl.IdlField, name string) int {
+		return slices.IndexFunc(fields, func(field idl.IdlField) bool { return field.Name == name })
+	}
+
+	for i, oldField := range old {
+		fieldPath := fmt.Sprintf("%s[%s]", path, oldField.Name)
+		j := indexOf(new, oldField.Name)
+		if j < 0 {
+			if i < len(new) && indexOf(old, new[i].Name) < 0 && typeString(oldField.Type) == typeString(new[i].Type) {
+				c.breaking(fieldPath, "%s renamed to %s", kind, new[i].Name)
+			} else {
+				c.breaking(fieldPath, "%s removed", kind)
+			}
+			continue
+		}
+		if i != j {
+			c.breaking(fieldPath, "%s moved from position %d to %d", kind, i, j)
+		}
+		if typeString(oldField.Type) != typeString(new[j].Type) {
+			c.breaking(fieldPath, "%s type changed from %s to %s", kind, typeString(oldField.Type), typeString(new[j].Type))
+		}
+	}
+	for i, newField := range new {
+		if indexOf(old, newField.Name) >= 0 {
+			continue
+		}
+		if i < len(old) && indexOf(new, old[i].Name) < 0 && typeString(old[i].Type) == typeString(newField.Type) {
+			// Reported as renamed.
+			continue
+		}
+		fieldPath := fmt.Sprintf("%s[%s]", path, newField.Name)
+		switch {
+		case i < len(old):
+			c.breaking(fieldPath, "%s inserted at position %d, before the existing ones", kind, i)
+		case trailingSafe:
+			c.safe(fieldPath, "trailing %s added", kind)
+		default:
+			c.breaking(fieldPath, "%s added", kind)
+		}
+	}
+}
+
+func (c *comparer) compareDefinedFields(path string, old, new *idl.IdlDefinedFields) {
+	switch {
+	case old.IsNamed() && new.IsNamed():
+		c.compareFields(path+".fields", "field", old.GetNamed().Fields, new.GetNamed().Fields, true)
+	case old.IsTuple() && new.IsTuple():
+		oldTypes, newTypes := old.GetTuple().Types, new.GetTuple().Types
+		for i := range min(len(oldTypes), len(newTypes)) {
+			if typeString(oldTypes[i]) != typeString(newTypes[i]) {
+				c.breaking(fmt.Sprintf("%s.fields[%d]", path, i), "field type changed from %s to %s", typeString(oldTypes[i]), typeString(newTypes[i]))
+			}
+		}
+		for i := len(newTypes); i < len(oldTypes); i++ {
+			c.breaking(fmt.Sprintf("%s.fields[%d]", path, i), "field removed")
+		}
+		for i := len(oldTypes); i < len(newTypes); i++ {
+			c.safe(fmt.Sprintf("%s.fields[%d]", path, i), "trailing field added")
+		}
+	case old == nil && new == nil:
+	default:
+		c.breaking(path+".fields", "fields changed from %s to %s", fieldsKind(old), fieldsKind(new))
+	}
+}
+
+func (c *comparer) compareAccounts(old, new []idl.IdlAccount) {
+	name := func(account *idl.IdlAccount) string { return account.Name }
+	matchByName(c, "accounts", "account", old, new, name, func(path string, old, new *idl.IdlAccount) {
+		c.compareDiscriminator(path, old.Discriminator, new.Discriminator)
+		// The inline type of the legacy IDL, the type of the current spec is compared with the types.
+		if !old.Type.IsZero() && !new.Type.IsZero() {
+			c.compareTypeDefTy(path+".type", &old.Type, &new.Type)
+		}
+	})
+}
+
+func (c *comparer) compareEvents(old, new []idl.IdlEvent) {
+	name := func(event *idl.IdlEvent) string { return event.Name }
+	matchByName(c, "events", "event", old, new, name, func(path string, old, new *idl.IdlEvent) {
+		c.compareDiscriminator(path, old.Discriminator, new.Discriminator)
+	})
+}
+
+func (c *comparer) compareTypes(oldProgram, newProgram *idl.Idl) {
+	name := func(typ *idl.IdlTypeDef) string { return typ.Name }
+	matchByName(c, "types", "type", oldProgram.Types, newProgram.Types, name, func(path string, old, new *idl.IdlTypeDef) {
+		if old.Serialization != new.Serialization {
+			c.breaking(path+".serialization", "serialization changed from %q to %q", old.Serialization, new.Serialization)
+		}
+		if jsonString(old.Repr) != jsonString(new.Repr) {
+			c.breaking(path+".repr", "repr changed from %s to %s", jsonString(old.Repr), jsonString(new.Repr))
+		}
+		if jsonString(old.Generics) != jsonString(new.Generics) {
+			c.breaking(path+".generics", "generics changed from %s to %s", jsonString(old.Generics), jsonString(new.Generics))
+		}
+		c.compareTypeDefTy(path+".type", &old.Type, &new.Type)
+	})
+}
+
+func (c *comparer) compareTypeDefTy(path string, old, new *idl.IdlTypeDefTy) {
+	switch {
+	case old.IsStruct() && new.IsStruct():
+		c.compareDefinedFields(path, old.GetStruct().Fields, new.GetStruct().Fields)
+	case old.IsEnum() && new.IsEnum():
+		c.compareVariants(path+".variants", old.GetEnum().Variants, new.GetEnum().Variants)
+	case old.IsType() && new.IsType():
+		if typeString(old.GetType().Alias) != typeString(new.GetType().Alias) {
+			c.breaking(path+".alias", "alias changed from %s to %s", typeString(old.GetType().Alias), typeString(new.GetType().Alias))
+		}
+	default:
+		c.breaking(path, "kind changed from %s to %s", typeDefKind(old), typeDefKind(new))
+	}
+}
+
+// compareVariants compares the variants by their index, which is the borsh tag of the variant.
+func (c *comparer) compareVariants(path string, old, new []idl.IdlEnumVariant) {
+	for i := range old {
+		variantPath := fmt.Sprintf("%s[%s]", path, old[i].Name)
+		if i >= len(new) {
+			c.breaking(variantPath, "variant removed")
+			continue
+		}
+		if old[i].Name != new[i].Name {
+			if slices.ContainsFunc(new, func(variant idl.IdlEnumVariant) bool { return variant.Name == old[i].Name }) {
+				c.breaking(variantPath, "variant index %d is now %s", i, new[i].Name)
+			} else {
+				c.breaking(variantPath, "variant renamed to %s", new[i].Name)
+			}
+			continue
+		}
+		c.compareDefinedFields(variantPath, old[i].Fields, new[i].Fields)
+	}
+	for i := len(old); i < len(new); i++ {
+		c.safe(fmt.Sprintf("%s[%s]", path, new[i].Name), "trailing variant added")
+	}
+}
+
+func (c *comparer) compareErrors(old, new []idl.IdlErrorCode) {
+	name := func(item *idl.IdlErrorCode) string { return item.Name }
+	matchByName(c, "errors", "error", old, new, name, func(path string, old, new *idl.IdlErrorCode) {
+		if old.Code != new.Code {
+			c.breaking(path+".code", "error code renumbered from %d to %d", old.Code, new.Code)
+		}
+		if stringValue(old.Msg) != stringValue(new.Msg) {
+			c.safe(path+".msg", "message changed from %q to %q", stringValue(old.Msg), stringValue(new.Msg))
+		}
+	})
+	// The clients map the codes to the errors, a code which now means another error is breaking too.
+	for _, newItem := range new {
+		i := slices.IndexFunc(old, func(item idl.IdlErrorCode) bool { return item.Code == newItem.Code })
+		if i >= 0 && old[i].Name != newItem.Name {
+			c.breaking(fmt.Sprintf("errors[%s].code", newItem.Name), "error code %d was used by %s", newItem.Code, old[i].Name)
+		}
+	}
+}
+
+func (c *comparer) compareConstants(old, new []idl.IdlConst) {
+	name := func(item *idl.IdlConst) string { return item.Name }
+	matchByName(c, "constants", "constant", old, new, name, func(path string, old, new *idl.IdlConst) {
+		if typeString(old.Type) != typeString(new.Type) {
+			c.breaking(path+".type", "type changed from %s to %s", typeString(old.Type), typeString(new.Type))
+		}
+		if old.Value != new.Value {
+			c.breaking(path+".value", "value changed from %s to %s", old.Value, new.Value)
+		}
+	})
+}
+
+// typeString returns the JSON of the type, which is also used to compare the types.
+func typeString(typ idl.IdlType) string {
+	if typ.IsZero() {
+		return "none"
+	}
+	return jsonString(typ)
+}
+
+func jsonString(v any) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(data)
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func typeDefKind(defTy *idl.IdlTypeDefTy) string {
+	switch {
+	case defTy.IsStruct():
+		return "struct"
+	case defTy.IsEnum():
+		return "enum"
+	case defTy.IsType():
+		return "type"
+	default:
+		return "none"
+	}
+}
+
+func fieldsKind(fields *idl.IdlDefinedFields) string {
+	switch {
+	case fields.IsNamed():
+		return "named"
+	case fields.IsTuple():
+		return "tuple"
+	default:
+		return "none"
+	}
+}
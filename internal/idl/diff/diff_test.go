@@ -0,0 +1,539 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/idl"
+)
+
+const baseIdlJson = `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "deposit",
+      "discriminator": [1, 1, 1, 1, 1, 1, 1, 1],
+      "accounts": [
+        {"name": "vault", "writable": true, "pda": {"seeds": [{"kind": "const", "value": [1]}]}},
+        {"name": "authority", "signer": true},
+        {"name": "common", "accounts": [
+          {"name": "systemProgram", "address": "11111111111111111111111111111111"},
+          {"name": "referrer", "optional": true}
+        ]}
+      ],
+      "args": [
+        {"name": "amount", "type": "u64"},
+        {"name": "memo", "type": "string"}
+      ]
+    }
+  ],
+  "accounts": [{"name": "Vault", "discriminator": [2, 2, 2, 2, 2, 2, 2, 2]}],
+  "events": [{"name": "Deposited", "discriminator": [3, 3, 3, 3, 3, 3, 3, 3]}],
+  "errors": [
+    {"code": 6000, "name": "Unauthorized", "msg": "Unauthorized"},
+    {"code": 6001, "name": "Overflow"}
+  ],
+  "types": [
+    {"name": "Vault", "type": {"kind": "struct", "fields": [
+      {"name": "owner", "type": "pubkey"},
+      {"name": "total", "type": "u64"}
+    ]}},
+    {"name": "Deposited", "type": {"kind": "struct", "fields": [{"name": "amount", "type": "u64"}]}},
+    {"name": "Kind", "type": {"kind": "enum", "variants": [
+      {"name": "Empty"},
+      {"name": "Named", "fields": [{"name": "value", "type": "u8"}]},
+      {"name": "Tuple", "fields": ["u8", "bool"]}
+    ]}},
+    {"name": "Amount", "type": {"kind": "type", "alias": "u64"}},
+    {"name": "Pool", "serialization": "bytemuck", "repr": {"kind": "c"}, "type": {"kind": "struct", "fields": [{"name": "total", "type": "u64"}]}}
+  ],
+  "constants": [{"name": "SEED", "type": "bytes", "value": "[1]"}]
+}`
+
+func TestCompare(t *testing.T) {
+	inst := func(program *idl.Idl) *idl.IdlInstruction { return &program.Instructions[0] }
+	account := func(program *idl.Idl, name string) *idl.IdlInstructionAccount {
+		for _, account := range inst(program).GetAccounts() {
+			if account.Name == name {
+				return account
+			}
+		}
+		panic("no account " + name)
+	}
+	typeDef := func(program *idl.Idl, name string) *idl.IdlTypeDef {
+		for i := range program.Types {
+			if program.Types[i].Name == name {
+				return &program.Types[i]
+			}
+		}
+		panic("no type " + name)
+	}
+	fields := func(program *idl.Idl, name string) *[]idl.IdlField {
+		return &typeDef(program, name).Type.GetStruct().Fields.GetNamed().Fields
+	}
+	variants := func(program *idl.Idl) *[]idl.IdlEnumVariant { return &typeDef(program, "Kind").Type.GetEnum().Variants }
+	simple := func(s idl.IdlTypeSimple) idl.IdlType { return idl.IdlType{IdlTypeSimple: &s} }
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name     string
+		mutate   func(program *idl.Idl)
+		expected []string
+	}{
+		{
+			name:   "unchanged",
+			mutate: func(program *idl.Idl) {},
+		},
+		{
+			name: "docs and metadata",
+			mutate: func(program *idl.Idl) {
+				program.Metadata.Version = "0.2.0"
+				program.Docs = []string{"A vault."}
+				inst(program).Docs = []string{"Deposits."}
+			},
+		},
+		{
+			name:     "address",
+			mutate:   func(program *idl.Idl) { program.Address = "11111111111111111111111111111111" },
+			expected: []string{"[breaking] address: program address changed from Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS to 11111111111111111111111111111111"},
+		},
+
+		// Instructions.
+		{
+			name:     "instruction removed",
+			mutate:   func(program *idl.Idl) { program.Instructions = nil },
+			expected: []string{"[breaking] instructions[deposit]: instruction removed"},
+		},
+		{
+			name: "instruction added",
+			mutate: func(program *idl.Idl) {
+				program.Instructions = append(program.Instructions, idl.IdlInstruction{Name: "withdraw", Discriminator: idl.IdlDiscriminator{9}})
+			},
+			expected: []string{"[safe] instructions[withdraw]: instruction added"},
+		},
+		{
+			name:     "instruction discriminator",
+			mutate:   func(program *idl.Idl) { inst(program).Discriminator = idl.IdlDiscriminator{9} },
+			expected: []string{"[breaking] instructions[deposit].discriminator: discriminator changed from [1 1 1 1 1 1 1 1] to [9]"},
+		},
+		{
+			name:     "instruction discriminant",
+			mutate:   func(program *idl.Idl) { inst(program).Discriminant = &idl.IdlDiscriminant{Type: "u8", Value: 1} },
+			expected: []string{`[breaking] instructions[deposit].discriminant: discriminant changed from null to {"type":"u8","value":1}`},
+		},
+		{
+			name:     "return type",
+			mutate:   func(program *idl.Idl) { inst(program).Returns = simple(idl.IdlTypeSimpleU64) },
+			expected: []string{`[breaking] instructions[deposit].returns: return type changed from none to "u64"`},
+		},
+
+		// Instruction accounts.
+		{
+			name: "account removed",
+			mutate: func(program *idl.Idl) {
+				inst(program).Accounts = inst(program).Accounts[:2]
+			},
+			expected: []string{
+				"[breaking] instructions[deposit].accounts[systemProgram]: account removed",
+				"[breaking] instructions[deposit].accounts[referrer]: account removed",
+			},
+		},
+		{
+			name: "account added",
+			mutate: func(program *idl.Idl) {
+				inst(program).Accounts = append(inst(program).Accounts, idl.IdlInstructionAccountItem{
+					IdlInstructionAccount: &idl.IdlInstructionAccount{Name: "mint", Optional: true},
+				})
+			},
+			expected: []string{"[breaking] instructions[deposit].accounts[mint]: account added"},
+		},
+		{
+			name: "account moved",
+			mutate: func(program *idl.Idl) {
+				accounts := inst(program).Accounts
+				accounts[0], accounts[1] = accounts[1], accounts[0]
+			},
+			expected: []string{
+				"[breaking] instructions[deposit].accounts[vault]: account moved from position 0 to 1",
+				"[breaking] instructions[deposit].accounts[authority]: account moved from position 1 to 0",
+			},
+		},
+		{
+			name: "account flags tightened",
+			mutate: func(program *idl.Idl) {
+				account(program, "authority").Writable = true
+				account(program, "vault").Signer = true
+				account(program, "referrer").Optional = false
+			},
+			expected: []string{
+				"[breaking] instructions[deposit].accounts[vault]: account becomes signer",
+				"[breaking] instructions[deposit].accounts[authority]: account becomes writable",
+				"[breaking] instructions[deposit].accounts[referrer]: account becomes required",
+			},
+		},
+		{
+			name: "account flags relaxed",
+			mutate: func(program *idl.Idl) {
+				account(program, "vault").Writable = false
+				account(program, "authority").Signer = false
+				account(program, "authority").Optional = true
+			},
+			expected: []string{
+				"[safe] instructions[deposit].accounts[vault]: account is no longer writable",
+				"[safe] instructions[deposit].accounts[authority]: account is no longer signer",
+				"[safe] instructions[deposit].accounts[authority]: account is no longer required",
+			},
+		},
+		{
+			name:     "fixed address removed",
+			mutate:   func(program *idl.Idl) { account(program, "systemProgram").Address = nil },
+			expected: []string{"[safe] instructions[deposit].accounts[systemProgram].address: fixed address 11111111111111111111111111111111 removed"},
+		},
+		{
+			name:     "fixed address added",
+			mutate:   func(program *idl.Idl) { account(program, "referrer").Address = str("11111111111111111111111111111111") },
+			expected: []string{"[breaking] instructions[deposit].accounts[referrer].address: fixed address 11111111111111111111111111111111 added"},
+		},
+		{
+			name: "fixed address changed",
+			mutate: func(program *idl.Idl) {
+				account(program, "systemProgram").Address = str("SysvarRent111111111111111111111111111111111")
+			},
+			expected: []string{"[breaking] instructions[deposit].accounts[systemProgram].address: fixed address changed from 11111111111111111111111111111111 to SysvarRent111111111111111111111111111111111"},
+		},
+		{
+			name:     "PDA removed",
+			mutate:   func(program *idl.Idl) { account(program, "vault").Pda = nil },
+			expected: []string{"[safe] instructions[deposit].accounts[vault].pda: PDA constraint removed"},
+		},
+		{
+			name: "PDA added",
+			mutate: func(program *idl.Idl) {
+				account(program, "authority").Pda = &idl.IdlPda{Seeds: []idl.IdlSeed{{IdlSeedArg: &idl.IdlSeedArg{Kind: "arg", Path: "amount"}}}}
+			},
+			expected: []string{`[breaking] instructions[deposit].accounts[authority].pda: PDA constraint added with seeds {"seeds":[{"kind":"arg","path":"amount"}]}`},
+		},
+		{
+			name:     "PDA seeds changed",
+			mutate:   func(program *idl.Idl) { account(program, "vault").Pda.Seeds[0].GetConst().Value = []byte{2} },
+			expected: []string{`[breaking] instructions[deposit].accounts[vault].pda: PDA seeds changed from {"seeds":[{"kind":"const","value":[1]}]} to {"seeds":[{"kind":"const","value":[2]}]}`},
+		},
+
+		// Instruction arguments.
+		{
+			name:     "argument removed",
+			mutate:   func(program *idl.Idl) { inst(program).Args = inst(program).Args[:1] },
+			expected: []string{"[breaking] instructions[deposit].args[memo]: argument removed"},
+		},
+		{
+			name: "trailing argument added",
+			mutate: func(program *idl.Idl) {
+				inst(program).Args = append(inst(program).Args, idl.IdlField{Name: "fee", Type: simple(idl.IdlTypeSimpleU8)})
+			},
+			expected: []string{"[breaking] instructions[deposit].args[fee]: argument added"},
+		},
+		{
+			name: "argument inserted",
+			mutate: func(program *idl.Idl) {
+				inst(program).Args = append([]idl.IdlField{{Name: "fee", Type: simple(idl.IdlTypeSimpleU8)}}, inst(program).Args...)
+			},
+			expected: []string{
+				"[breaking] instructions[deposit].args[amount]: argument moved from position 0 to 1",
+				"[breaking] instructions[deposit].args[memo]: argument moved from position 1 to 2",
+				"[breaking] instructions[deposit].args[fee]: argument inserted at position 0, before the existing ones",
+			},
+		},
+		{
+			name:     "argument renamed",
+			mutate:   func(program *idl.Idl) { inst(program).Args[0].Name = "lamports" },
+			expected: []string{"[breaking] instructions[deposit].args[amount]: argument renamed to lamports"},
+		},
+		{
+			name:     "argument type changed",
+			mutate:   func(program *idl.Idl) { inst(program).Args[0].Type = simple(idl.IdlTypeSimpleU128) },
+			expected: []string{`[breaking] instructions[deposit].args[amount]: argument type changed from "u64" to "u128"`},
+		},
+		{
+			name: "argument renamed with another type",
+			mutate: func(program *idl.Idl) {
+				inst(program).Args[0] = idl.IdlField{Name: "lamports", Type: simple(idl.IdlTypeSimpleU128)}
+			},
+			expected: []string{
+				"[breaking] instructions[deposit].args[amount]: argument removed",
+				"[breaking] instructions[deposit].args[lamports]: argument inserted at position 0, before the existing ones",
+			},
+		},
+
+		// Accounts and events.
+		{
+			name:     "account type removed",
+			mutate:   func(program *idl.Idl) { program.Accounts = nil },
+			expected: []string{"[breaking] accounts[Vault]: account removed"},
+		},
+		{
+			name: "account type added",
+			mutate: func(program *idl.Idl) {
+				program.Accounts = append(program.Accounts, idl.IdlAccount{Name: "Pool", Discriminator: idl.IdlDiscriminator{4}})
+			},
+			expected: []string{"[safe] accounts[Pool]: account added"},
+		},
+		{
+			name:     "account discriminator",
+			mutate:   func(program *idl.Idl) { program.Accounts[0].Discriminator = idl.IdlDiscriminator{9} },
+			expected: []string{"[breaking] accounts[Vault].discriminator: discriminator changed from [2 2 2 2 2 2 2 2] to [9]"},
+		},
+		{
+			name:     "event removed",
+			mutate:   func(program *idl.Idl) { program.Events = nil },
+			expected: []string{"[breaking] events[Deposited]: event removed"},
+		},
+		{
+			name: "event added",
+			mutate: func(program *idl.Idl) {
+				program.Events = append(program.Events, idl.IdlEvent{Name: "Withdrawn", Discriminator: idl.IdlDiscriminator{4}})
+			},
+			expected: []string{"[safe] events[Withdrawn]: event added"},
+		},
+		{
+			name:     "event discriminator",
+			mutate:   func(program *idl.Idl) { program.Events[0].Discriminator = idl.IdlDiscriminator{9} },
+			expected: []string{"[breaking] events[Deposited].discriminator: discriminator changed from [3 3 3 3 3 3 3 3] to [9]"},
+		},
+
+		// Types.
+		{
+			name:     "type removed",
+			mutate:   func(program *idl.Idl) { program.Types = program.Types[:4] },
+			expected: []string{"[breaking] types[Pool]: type removed"},
+		},
+		{
+			name: "type added",
+			mutate: func(program *idl.Idl) {
+				program.Types = append(program.Types, idl.IdlTypeDef{Name: "Fee", Type: idl.IdlTypeDefTy{IdlTypeDefTyType: &idl.IdlTypeDefTyType{Kind: "type", Alias: simple(idl.IdlTypeSimpleU8)}}})
+			},
+			expected: []string{"[safe] types[Fee]: type added"},
+		},
+		{
+			name:     "serialization",
+			mutate:   func(program *idl.Idl) { typeDef(program, "Pool").Serialization = idl.IdlSerializationBorsh },
+			expected: []string{`[breaking] types[Pool].serialization: serialization changed from "bytemuck" to "borsh"`},
+		},
+		{
+			name:     "repr",
+			mutate:   func(program *idl.Idl) { typeDef(program, "Pool").Repr.GetC().Modifier.Packed = true },
+			expected: []string{`[breaking] types[Pool].repr: repr changed from {"kind":"c"} to {"kind":"c","packed":true}`},
+		},
+		{
+			name: "generics",
+			mutate: func(program *idl.Idl) {
+				typeDef(program, "Vault").Generics = []idl.IdlTypeDefGeneric{{IdlTypeDefGenericType: &idl.IdlTypeDefGenericType{Kind: "type", Name: "T"}}}
+			},
+			expected: []string{`[breaking] types[Vault].generics: generics changed from null to [{"kind":"type","name":"T"}]`},
+		},
+		{
+			name:     "kind",
+			mutate:   func(program *idl.Idl) { typeDef(program, "Amount").Type = typeDef(program, "Deposited").Type },
+			expected: []string{"[breaking] types[Amount].type: kind changed from type to struct"},
+		},
+		{
+			name: "alias",
+			mutate: func(program *idl.Idl) {
+				typeDef(program, "Amount").Type.GetType().Alias = simple(idl.IdlTypeSimpleU128)
+			},
+			expected: []string{`[breaking] types[Amount].type.alias: alias changed from "u64" to "u128"`},
+		},
+		{
+			name: "trailing field added",
+			mutate: func(program *idl.Idl) {
+				*fields(program, "Vault") = append(*fields(program, "Vault"), idl.IdlField{Name: "bump", Type: simple(idl.IdlTypeSimpleU8)})
+			},
+			expected: []string{"[safe] types[Vault].type.fields[bump]: trailing field added"},
+		},
+		{
+			name:     "field removed",
+			mutate:   func(program *idl.Idl) { *fields(program, "Vault") = (*fields(program, "Vault"))[:1] },
+			expected: []string{"[breaking] types[Vault].type.fields[total]: field removed"},
+		},
+		{
+			name:     "field renamed",
+			mutate:   func(program *idl.Idl) { (*fields(program, "Vault"))[1].Name = "amount" },
+			expected: []string{"[breaking] types[Vault].type.fields[total]: field renamed to amount"},
+		},
+		{
+			name:     "field type changed",
+			mutate:   func(program *idl.Idl) { (*fields(program, "Vault"))[1].Type = simple(idl.IdlTypeSimpleI64) },
+			expected: []string{`[breaking] types[Vault].type.fields[total]: field type changed from "u64" to "i64"`},
+		},
+		{
+			name: "fields kind",
+			mutate: func(program *idl.Idl) {
+				typeDef(program, "Deposited").Type.GetStruct().Fields = &idl.IdlDefinedFields{
+					IdlDefinedFieldsTuple: &idl.IdlDefinedFieldsTuple{Types: []idl.IdlType{simple(idl.IdlTypeSimpleU64)}},
+				}
+			},
+			expected: []string{"[breaking] types[Deposited].type.fields: fields changed from named to tuple"},
+		},
+
+		// Enum variants.
+		{
+			name: "trailing variant added",
+			mutate: func(program *idl.Idl) {
+				*variants(program) = append(*variants(program), idl.IdlEnumVariant{Name: "Other"})
+			},
+			expected: []string{"[safe] types[Kind].type.variants[Other]: trailing variant added"},
+		},
+		{
+			name:     "variant removed",
+			mutate:   func(program *idl.Idl) { *variants(program) = (*variants(program))[:2] },
+			expected: []string{"[breaking] types[Kind].type.variants[Tuple]: variant removed"},
+		},
+		{
+			name:     "variant renamed",
+			mutate:   func(program *idl.Idl) { (*variants(program))[0].Name = "Nothing" },
+			expected: []string{"[breaking] types[Kind].type.variants[Empty]: variant renamed to Nothing"},
+		},
+		{
+			name: "variants reordered",
+			mutate: func(program *idl.Idl) {
+				v := *variants(program)
+				v[0], v[1] = v[1], v[0]
+			},
+			expected: []string{
+				"[breaking] types[Kind].type.variants[Empty]: variant index 0 is now Named",
+				"[breaking] types[Kind].type.variants[Named]: variant index 1 is now Empty",
+			},
+		},
+		{
+			name: "named variant fields",
+			mutate: func(program *idl.Idl) {
+				(*variants(program))[1].Fields.GetNamed().Fields[0].Type = simple(idl.IdlTypeSimpleU16)
+			},
+			expected: []string{`[breaking] types[Kind].type.variants[Named].fields[value]: field type changed from "u8" to "u16"`},
+		},
+		{
+			name: "tuple variant field type changed",
+			mutate: func(program *idl.Idl) {
+				(*variants(program))[2].Fields.GetTuple().Types[1] = simple(idl.IdlTypeSimpleU8)
+			},
+			expected: []string{`[breaking] types[Kind].type.variants[Tuple].fields[1]: field type changed from "bool" to "u8"`},
+		},
+		{
+			name: "tuple variant field removed",
+			mutate: func(program *idl.Idl) {
+				tuple := (*variants(program))[2].Fields.GetTuple()
+				tuple.Types = tuple.Types[:1]
+			},
+			expected: []string{"[breaking] types[Kind].type.variants[Tuple].fields[1]: field removed"},
+		},
+		{
+			name: "tuple variant trailing field added",
+			mutate: func(program *idl.Idl) {
+				tuple := (*variants(program))[2].Fields.GetTuple()
+				tuple.Types = append(tuple.Types, simple(idl.IdlTypeSimpleString))
+			},
+			expected: []string{"[safe] types[Kind].type.variants[Tuple].fields[2]: trailing field added"},
+		},
+		{
+			name:     "unit variant gets fields",
+			mutate:   func(program *idl.Idl) { (*variants(program))[0].Fields = (*variants(program))[2].Fields },
+			expected: []string{"[breaking] types[Kind].type.variants[Empty].fields: fields changed from none to tuple"},
+		},
+
+		// Errors and constants.
+		{
+			name:     "error removed",
+			mutate:   func(program *idl.Idl) { program.Errors = program.Errors[:1] },
+			expected: []string{"[breaking] errors[Overflow]: error removed"},
+		},
+		{
+			name: "error added",
+			mutate: func(program *idl.Idl) {
+				program.Errors = append(program.Errors, idl.IdlErrorCode{Code: 6002, Name: "Paused"})
+			},
+			expected: []string{"[safe] errors[Paused]: error added"},
+		},
+		{
+			name:     "error renumbered",
+			mutate:   func(program *idl.Idl) { program.Errors[1].Code = 6010 },
+			expected: []string{"[breaking] errors[Overflow].code: error code renumbered from 6001 to 6010"},
+		},
+		{
+			name:     "error message",
+			mutate:   func(program *idl.Idl) { program.Errors[0].Msg = str("Not the authority") },
+			expected: []string{`[safe] errors[Unauthorized].msg: message changed from "Unauthorized" to "Not the authority"`},
+		},
+		{
+			name:   "error code reused",
+			mutate: func(program *idl.Idl) { program.Errors[1] = idl.IdlErrorCode{Code: 6001, Name: "Paused"} },
+			expected: []string{
+				"[breaking] errors[Overflow]: error removed",
+				"[safe] errors[Paused]: error added",
+				"[breaking] errors[Paused].code: error code 6001 was used by Overflow",
+			},
+		},
+		{
+			name:     "constant removed",
+			mutate:   func(program *idl.Idl) { program.Constants = nil },
+			expected: []string{"[breaking] constants[SEED]: constant removed"},
+		},
+		{
+			name: "constant added",
+			mutate: func(program *idl.Idl) {
+				program.Constants = append(program.Constants, idl.IdlConst{Name: "FEE", Type: simple(idl.IdlTypeSimpleU8), Value: "1"})
+			},
+			expected: []string{"[safe] constants[FEE]: constant added"},
+		},
+		{
+			name:     "constant type",
+			mutate:   func(program *idl.Idl) { program.Constants[0].Type = simple(idl.IdlTypeSimpleString) },
+			expected: []string{`[breaking] constants[SEED].type: type changed from "bytes" to "string"`},
+		},
+		{
+			name:     "constant value",
+			mutate:   func(program *idl.Idl) { program.Constants[0].Value = "[2]" },
+			expected: []string{"[breaking] constants[SEED].value: value changed from [1] to [2]"},
+		},
+
+		{
+			name: "changes in the order of the sections",
+			mutate: func(program *idl.Idl) {
+				program.Constants[0].Value = "[2]"
+				program.Events[0].Discriminator = idl.IdlDiscriminator{9}
+				program.Address = "11111111111111111111111111111111"
+			},
+			expected: []string{
+				"[breaking] address: program address changed from Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS to 11111111111111111111111111111111",
+				"[breaking] events[Deposited].discriminator: discriminator changed from [3 3 3 3 3 3 3 3] to [9]",
+				"[breaking] constants[SEED].value: value changed from [1] to [2]",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			old, err := idl.Parse([]byte(baseIdlJson))
+			if err != nil {
+				t.Fatal(err)
+			}
+			new, err := idl.Parse([]byte(baseIdlJson))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(new)
+
+			changes := Compare(old, new)
+			if len(changes) != len(tt.expected) {
+				t.Fatalf("got changes %v, expected %v", changes, tt.expected)
+			}
+			breaking := false
+			for i, change := range changes {
+				if got := change.String(); got != tt.expected[i] {
+					t.Errorf("changes[%d]: got %s, expected %s", i, got, tt.expected[i])
+				}
+				breaking = breaking || change.Impact == ImpactBreaking
+			}
+			if changes.HasBreaking() != breaking {
+				t.Errorf("got HasBreaking %v, expected %v", changes.HasBreaking(), breaking)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package idl
 
 import (
+	"fmt"
 	"slices"
 )
 
@@ -17,6 +18,8 @@ type Idl struct {
 	Errors       []IdlErrorCode   `json:"errors,omitempty"`
 	Types        []IdlTypeDef     `json:"types,omitempty"`
 	Constants    []IdlConst       `json:"constants,omitempty"`
+	// !!! Notice: `Checksum` is not in the original spec, it's the hex SHA-256 of the JSON which the IDL is parsed from.
+	Checksum string `json:"-"`
 }
 
 type IdlMetadata struct {
@@ -46,10 +49,10 @@ type IdlDeployments struct {
 type IdlInstruction struct {
 	Name          string                      `json:"name"`
 	Docs          []string                    `json:"docs,omitempty"`
-	Discriminator IdlDiscriminator            `json:"discriminator"`
+	Discriminator IdlDiscriminator            `json:"discriminator,omitzero"`
 	Accounts      []IdlInstructionAccountItem `json:"accounts"`
 	Args          []IdlField                  `json:"args"`
-	Returns       IdlType                     `json:"returns,omitempty"`
+	Returns       IdlType                     `json:"returns,omitzero"`
 	// !!! Notice: `Discriminant` is not in the original spec.
 	Discriminant *IdlDiscriminant `json:"discriminant,omitempty"`
 }
@@ -59,7 +62,7 @@ type IdlAccount struct {
 	Discriminator IdlDiscriminator `json:"discriminator"`
 	// !!! Notice: `Type` is for the old idl spec.
 	// It is not in the original spec.
-	Type IdlTypeDefTy `json:"type,omitempty"`
+	Type IdlTypeDefTy `json:"type,omitzero"`
 }
 
 type IdlEvent struct {
@@ -82,6 +85,10 @@ type IdlErrorCode struct {
 
 type IdlDiscriminator []byte
 
+func (discriminator IdlDiscriminator) MarshalJSON() ([]byte, error) {
+	return byteArray(discriminator).MarshalJSON()
+}
+
 // !!! Notice: `Discriminant` is not in the original spec.
 type IdlDiscriminant struct {
 	Type  string `json:"type"`
@@ -112,6 +119,8 @@ type instructionAccount struct {
 	Account       *IdlInstructionAccount
 	Parents       []*IdlInstructionAccounts
 	IndexInParent int
+	// Location of the account in the instruction, e.g. `accounts[1].accounts[0]`
+	Path string
 }
 
 func (ins *IdlInstruction) GetAccounts() []*IdlInstructionAccount {
@@ -129,20 +138,21 @@ func (ins *IdlInstruction) GetAccountsWithRelation() []*instructionAccount {
 	}
 
 	accounts := make([]*instructionAccount, 0)
-	for _, item := range ins.Accounts {
-		result := item.flatenAccounts(nil, -1)
+	for i, item := range ins.Accounts {
+		result := item.flatenAccounts(nil, -1, fmt.Sprintf("accounts[%d]", i))
 		accounts = append(accounts, result...)
 	}
 	return accounts
 }
 
-func (accountItem IdlInstructionAccountItem) flatenAccounts(parents []*IdlInstructionAccounts, indexInParent int) []*instructionAccount {
+func (accountItem IdlInstructionAccountItem) flatenAccounts(parents []*IdlInstructionAccounts, indexInParent int, path string) []*instructionAccount {
 	if accountItem.IsAccount() {
 		return []*instructionAccount{
 			{
 				Account:       accountItem.GetAccount(),
 				Parents:       parents,
 				IndexInParent: indexInParent,
+				Path:          path,
 			},
 		}
 	} else if accountItem.IsAccounts() {
@@ -152,7 +162,7 @@ func (accountItem IdlInstructionAccountItem) flatenAccounts(parents []*IdlInstru
 
 		result := make([]*instructionAccount, 0)
 		for i, account := range accounts.Accounts {
-			tmp := account.flatenAccounts(newParents, i)
+			tmp := account.flatenAccounts(newParents, i, fmt.Sprintf("%s.accounts[%d]", path, i))
 			result = append(result, tmp...)
 		}
 		return result
@@ -0,0 +1,232 @@
+package idl
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestIdlTypeJSONRoundTrip(t *testing.T) {
+	tests := []string{
+		`"u128"`,
+		`"i128"`,
+		`"pubkey"`,
+		`{"option":"pubkey"}`,
+		`{"option":{"option":"u128"}}`,
+		`{"coption":"pubkey"}`,
+		`{"vec":{"option":"i128"}}`,
+		`{"array":["pubkey",32]}`,
+		`{"array":["u8",{"generic":"N"}]}`,
+		`{"defined":{"name":"Kind"}}`,
+		`{"defined":{"name":"Wrapper","generics":[{"kind":"type","type":"u128"},{"kind":"const","value":"4"}]}}`,
+		`{"generic":"T"}`,
+		`{"hashMap":["pubkey",{"option":"i128"}]}`,
+		`{"bTreeMap":["string","u64"]}`,
+		`{"hashSet":"pubkey"}`,
+		`{"bTreeSet":"u8"}`,
+	}
+	for _, data := range tests {
+		t.Run(data, func(t *testing.T) {
+			testJSONRoundTrip[IdlType](t, data)
+		})
+	}
+}
+
+func TestIdlTypeDefJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"unit enum", `{"name":"Side","type":{"kind":"enum","variants":[{"name":"Bid"},{"name":"Ask"}]}}`},
+		{"complex enum", `{"name":"Kind","type":{"kind":"enum","variants":[` +
+			`{"name":"Empty"},` +
+			`{"name":"Named","fields":[{"name":"owner","type":"pubkey"},{"name":"amount","type":{"option":"u128"}}]},` +
+			`{"name":"Tuple","fields":["i128",{"option":"pubkey"}]}]}}`},
+		{"struct", `{"name":"Vault","docs":["A vault."],"type":{"kind":"struct","fields":[{"name":"owner","type":"pubkey"},{"name":"total","type":"u128"}]}}`},
+		{"empty struct", `{"name":"Marker","type":{"kind":"struct"}}`},
+		{"tuple struct", `{"name":"Pair","type":{"kind":"struct","fields":["i128","u128"]}}`},
+		{"alias", `{"name":"Amount","type":{"kind":"type","alias":{"option":"u128"}}}`},
+		{"generics", `{"name":"Wrapper","generics":[{"kind":"type","name":"T"},{"kind":"const","name":"N","type":"usize"}],` +
+			`"type":{"kind":"struct","fields":[{"name":"items","type":{"array":[{"generic":"T"},{"generic":"N"}]}}]}}`},
+		{"zero copy", `{"name":"Pool","serialization":"bytemuck","repr":{"kind":"c","packed":true},"type":{"kind":"struct","fields":[{"name":"total","type":"u128"}]}}`},
+		{"rust repr", `{"name":"Pool","repr":{"kind":"rust","align":8},"type":{"kind":"struct"}}`},
+		{"transparent repr", `{"name":"Pool","repr":{"kind":"transparent"},"type":{"kind":"struct"}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testJSONRoundTrip[IdlTypeDef](t, tt.data)
+		})
+	}
+}
+
+func TestIdlInstructionAccountItemJSONRoundTrip(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+	}{
+		{"account", `{"name":"authority","writable":true,"signer":true,"optional":true,"relations":["vault"]}`},
+		{"fixed address", `{"name":"systemProgram","address":"11111111111111111111111111111111"}`},
+		{"pda", `{"name":"vault","pda":{"seeds":[` +
+			`{"kind":"const","value":[118,97,117,108,116]},` +
+			`{"kind":"arg","path":"args.id"},` +
+			`{"kind":"account","path":"config.owner","account":"Config"},` +
+			`{"kind":"account","path":"authority"}],` +
+			`"program":{"kind":"const","value":[1,2,3]}}}`},
+		{"composite", `{"name":"common","accounts":[{"name":"payer","signer":true},{"name":"inner","accounts":[{"name":"mint"}]}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testJSONRoundTrip[IdlInstructionAccountItem](t, tt.data)
+		})
+	}
+}
+
+// The Go values are marshalled and unmarshalled back, without any JSON input.
+func TestIdlValueJSONRoundTrip(t *testing.T) {
+	u128, i128, pubkey := IdlTypeSimpleU128, IdlTypeSimpleI128, IdlTypeSimplePubkey
+	tests := []struct {
+		name     string
+		value    any
+		expected string
+	}{
+		{
+			name:     "option of u128",
+			value:    &IdlType{IdlTypeOption: &IdlTypeOption{Option: IdlType{IdlTypeSimple: &u128}}},
+			expected: `{"option":"u128"}`,
+		},
+		{
+			name:     "coption of pubkey",
+			value:    &IdlType{IdlTypeOption: &IdlTypeOption{Option: IdlType{IdlTypeSimple: &pubkey}, COption: true}},
+			expected: `{"coption":"pubkey"}`,
+		},
+		{
+			name:     "vec of i128",
+			value:    &IdlType{IdlTypeVec: &IdlTypeVec{Vec: IdlType{IdlTypeSimple: &i128}}},
+			expected: `{"vec":"i128"}`,
+		},
+		{
+			name: "enum",
+			value: &IdlTypeDefTy{IdlTypeDefTyEnum: &IdlTypeDefTyEnum{Kind: "enum", Variants: []IdlEnumVariant{
+				{Name: "None"},
+				{Name: "Some", Fields: &IdlDefinedFields{IdlDefinedFieldsTuple: &IdlDefinedFieldsTuple{Types: []IdlType{{IdlTypeSimple: &pubkey}}}}},
+			}}},
+			expected: `{"kind":"enum","variants":[{"name":"None"},{"name":"Some","fields":["pubkey"]}]}`,
+		},
+		{
+			name:     "const seed",
+			value:    &IdlSeed{IdlSeedConst: &IdlSeedConst{Kind: "const", Value: []byte{0, 255}}},
+			expected: `{"kind":"const","value":[0,255]}`,
+		},
+		{
+			name: "instruction",
+			value: &IdlInstruction{
+				Name:          "withdraw",
+				Discriminator: IdlDiscriminator{1, 2, 3, 4, 5, 6, 7, 8},
+				Accounts:      []IdlInstructionAccountItem{{IdlInstructionAccount: &IdlInstructionAccount{Name: "vault", Writable: true}}},
+				Args:          []IdlField{{Name: "amount", Type: IdlType{IdlTypeOption: &IdlTypeOption{Option: IdlType{IdlTypeSimple: &u128}}}}},
+			},
+			expected: `{"name":"withdraw","discriminator":[1,2,3,4,5,6,7,8],"accounts":[{"name":"vault","writable":true}],"args":[{"name":"amount","type":{"option":"u128"}}]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != tt.expected {
+				t.Errorf("got %s, expected %s", data, tt.expected)
+			}
+			decoded := reflect.New(reflect.TypeOf(tt.value).Elem()).Interface()
+			if err := json.Unmarshal(data, decoded); err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(decoded, tt.value) {
+				t.Errorf("got %#v after the round trip, expected %#v", decoded, tt.value)
+			}
+		})
+	}
+}
+
+func TestIdlJSONRoundTrip(t *testing.T) {
+	data := []byte(`{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0", "description": "A vault."},
+  "instructions": [
+    {
+      "name": "deposit",
+      "discriminator": [242, 35, 198, 137, 82, 225, 242, 182],
+      "accounts": [{"name": "vault", "writable": true, "pda": {"seeds": [{"kind": "const", "value": [1]}]}}],
+      "args": [{"name": "amount", "type": "u128"}],
+      "returns": {"option": "i128"}
+    }
+  ],
+  "accounts": [{"name": "Vault", "discriminator": [211, 8, 232, 43, 2, 152, 117, 119]}],
+  "events": [{"name": "Deposited", "discriminator": [111, 141, 26, 45, 161, 35, 100, 57]}],
+  "errors": [{"code": 6000, "name": "Unauthorized", "msg": "Unauthorized"}],
+  "types": [
+    {"name": "Vault", "type": {"kind": "struct", "fields": [{"name": "owner", "type": "pubkey"}, {"name": "kind", "type": {"defined": {"name": "Kind"}}}]}},
+    {"name": "Deposited", "type": {"kind": "struct", "fields": [{"name": "amount", "type": "u128"}]}},
+    {"name": "Kind", "type": {"kind": "enum", "variants": [{"name": "A"}, {"name": "B", "fields": ["i128"]}]}}
+  ],
+  "constants": [{"name": "SEED", "type": "bytes", "value": "[118, 97]"}]
+}`)
+	program, err := Parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	marshalled, err := json.Marshal(program)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, marshalled, data)
+
+	reparsed, err := Parse(marshalled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The checksum is of the JSON, which is formatted differently.
+	reparsed.Checksum = program.Checksum
+	if !reflect.DeepEqual(reparsed, program) {
+		t.Error("the IDL changed after the round trip")
+	}
+}
+
+// testJSONRoundTrip unmarshals the JSON into a T, checks that it's marshalled back
+// into the same JSON and that the unmarshalled value is the same.
+func testJSONRoundTrip[T any](t *testing.T, data string) {
+	t.Helper()
+	var value T
+	if err := json.Unmarshal([]byte(data), &value); err != nil {
+		t.Fatal(err)
+	}
+	marshalled, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertJSONEqual(t, marshalled, []byte(data))
+
+	var reparsed T
+	if err := json.Unmarshal(marshalled, &reparsed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(reparsed, value) {
+		t.Errorf("got %#v after the round trip, expected %#v", reparsed, value)
+	}
+}
+
+// assertJSONEqual compares the JSONs regardless of their formatting.
+func assertJSONEqual(t *testing.T, got, expected []byte) {
+	t.Helper()
+	var gotCompact, expectedCompact bytes.Buffer
+	if err := json.Compact(&gotCompact, got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Compact(&expectedCompact, expected); err != nil {
+		t.Fatal(err)
+	}
+	if gotCompact.String() != expectedCompact.String() {
+		t.Errorf("got JSON %s, expected %s", gotCompact.String(), expectedCompact.String())
+	}
+}
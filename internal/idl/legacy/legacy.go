@@ -0,0 +1,438 @@
+// Package legacy converts the IDLs of Anchor before 0.30 into the current spec.
+// Ref: https://github.com/solana-foundation/anchor/blob/v0.31.1/idl/src/convert.rs
+package legacy
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/alivers/anchor-go/internal/idl"
+	"github.com/alivers/anchor-go/internal/util"
+)
+
+// Spec is the spec version of the converted IDLs.
+const Spec = "0.1.0"
+
+type legacyIdl struct {
+	Version      string              `json:"version"`
+	Name         string              `json:"name"`
+	Docs         []string            `json:"docs,omitempty"`
+	Constants    []legacyConst       `json:"constants,omitempty"`
+	Instructions []legacyInstruction `json:"instructions"`
+	State        *legacyState        `json:"state,omitempty"`
+	Accounts     []legacyTypeDef     `json:"accounts,omitempty"`
+	Types        []legacyTypeDef     `json:"types,omitempty"`
+	Events       []legacyEvent       `json:"events,omitempty"`
+	Errors       []idl.IdlErrorCode  `json:"errors,omitempty"`
+	Metadata     *legacyIdlMetadata  `json:"metadata,omitempty"`
+}
+
+type legacyIdlMetadata struct {
+	Address string `json:"address"`
+}
+
+type legacyConst struct {
+	Name  string          `json:"name"`
+	Type  json.RawMessage `json:"type"`
+	Value string          `json:"value"`
+}
+
+type legacyState struct {
+	Struct  legacyTypeDef       `json:"struct"`
+	Methods []legacyInstruction `json:"methods"`
+}
+
+type legacyInstruction struct {
+	Name     string              `json:"name"`
+	Docs     []string            `json:"docs,omitempty"`
+	Accounts []legacyAccountItem `json:"accounts"`
+	Args     []legacyField       `json:"args"`
+	Returns  json.RawMessage     `json:"returns,omitempty"`
+}
+
+// legacyAccountItem is either an account or a group of accounts (when `Accounts` is set).
+type legacyAccountItem struct {
+	Name       string              `json:"name"`
+	Docs       []string            `json:"docs,omitempty"`
+	IsMut      bool                `json:"isMut"`
+	IsSigner   bool                `json:"isSigner"`
+	IsOptional bool                `json:"isOptional,omitempty"`
+	Relations  []string            `json:"relations,omitempty"`
+	Pda        *legacyPda          `json:"pda,omitempty"`
+	Accounts   []legacyAccountItem `json:"accounts,omitempty"`
+}
+
+type legacyPda struct {
+	Seeds     []legacySeed `json:"seeds"`
+	ProgramID *legacySeed  `json:"programId,omitempty"`
+}
+
+type legacySeed struct {
+	Kind    string          `json:"kind"`
+	Type    json.RawMessage `json:"type"`
+	Value   json.RawMessage `json:"value,omitempty"`
+	Path    string          `json:"path,omitempty"`
+	Account *string         `json:"account,omitempty"`
+}
+
+type legacyField struct {
+	Name string          `json:"name"`
+	Docs []string        `json:"docs,omitempty"`
+	Type json.RawMessage `json:"type"`
+}
+
+type legacyTypeDef struct {
+	Name string          `json:"name"`
+	Docs []string        `json:"docs,omitempty"`
+	Type legacyTypeDefTy `json:"type"`
+}
+
+type legacyTypeDefTy struct {
+	Kind     string          `json:"kind"`
+	Fields   []legacyField   `json:"fields,omitempty"`
+	Variants []legacyVariant `json:"variants,omitempty"`
+	// Aliased type of `alias` kind.
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type legacyVariant struct {
+	Name string `json:"name"`
+	// Either named fields or tuple types.
+	Fields []json.RawMessage `json:"fields,omitempty"`
+}
+
+type legacyEvent struct {
+	Name   string        `json:"name"`
+	Fields []legacyField `json:"fields"`
+}
+
+// IsLegacy reports whether the JSON is an IDL of the legacy format,
+// which has the `name` and `version` at the top level instead of the `metadata`.
+func IsLegacy(data []byte) bool {
+	var objMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objMap); err != nil {
+		return false
+	}
+	if metadata, ok := objMap["metadata"]; ok {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(metadata, &fields); err == nil {
+			if _, ok := fields["spec"]; ok {
+				return false
+			}
+		}
+	}
+	_, hasName := objMap["name"]
+	_, hasInstructions := objMap["instructions"]
+	return hasName && hasInstructions
+}
+
+// Convert converts the JSON of a legacy IDL into the current spec.
+// The discriminators, which are not in the legacy IDL, are computed with the sighash rules of Anchor.
+func Convert(data []byte) (*idl.Idl, error) {
+	var legacy legacyIdl
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return nil, err
+	}
+
+	program := &idl.Idl{
+		Metadata: idl.IdlMetadata{
+			Name:    legacy.Name,
+			Version: legacy.Version,
+			Spec:    Spec,
+		},
+		Docs:   legacy.Docs,
+		Errors: legacy.Errors,
+	}
+	if legacy.Metadata != nil {
+		program.Address = legacy.Metadata.Address
+	}
+
+	var errs []error
+	for i, inst := range legacy.Instructions {
+		converted, err := convertInstruction(inst, "global")
+		if err != nil {
+			errs = append(errs, fmt.Errorf("instructions[%d]: %w", i, err))
+			continue
+		}
+		program.Instructions = append(program.Instructions, converted)
+	}
+
+	if legacy.State != nil {
+		// The state struct is stored in an account, and its methods are in the `state` namespace.
+		typ, err := convertTypeDef(legacy.State.Struct)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("state.struct: %w", err))
+		} else {
+			program.Accounts = append(program.Accounts, idl.IdlAccount{
+				Name:          typ.Name,
+				Discriminator: sighash("account", typ.Name),
+			})
+			program.Types = append(program.Types, typ)
+		}
+		for i, method := range legacy.State.Methods {
+			converted, err := convertInstruction(method, "state")
+			if err != nil {
+				errs = append(errs, fmt.Errorf("state.methods[%d]: %w", i, err))
+				continue
+			}
+			program.Instructions = append(program.Instructions, converted)
+		}
+	}
+
+	for i, acc := range legacy.Accounts {
+		typ, err := convertTypeDef(acc)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("accounts[%d]: %w", i, err))
+			continue
+		}
+		program.Accounts = append(program.Accounts, idl.IdlAccount{
+			Name:          acc.Name,
+			Discriminator: sighash("account", acc.Name),
+		})
+		program.Types = append(program.Types, typ)
+	}
+
+	for i, evt := range legacy.Events {
+		fields, err := convertFields(evt.Fields)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("events[%d]: %w", i, err))
+			continue
+		}
+		program.Events = append(program.Events, idl.IdlEvent{
+			Name:          evt.Name,
+			Discriminator: sighash("event", evt.Name),
+		})
+		program.Types = append(program.Types, idl.IdlTypeDef{
+			Name: evt.Name,
+			Type: structTy(fields),
+		})
+	}
+
+	for i, typ := range legacy.Types {
+		converted, err := convertTypeDef(typ)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("types[%d]: %w", i, err))
+			continue
+		}
+		program.Types = append(program.Types, converted)
+	}
+
+	for i, c := range legacy.Constants {
+		typ, err := convertType(c.Type)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("constants[%d]: %w", i, err))
+			continue
+		}
+		program.Constants = append(program.Constants, idl.IdlConst{
+			Name:  c.Name,
+			Type:  typ,
+			Value: c.Value,
+		})
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf("failed to convert legacy IDL: %w", err)
+	}
+	return program, nil
+}
+
+// sighash returns the first 8 bytes of `sha256("<namespace>:<name>")`.
+func sighash(namespace, name string) idl.IdlDiscriminator {
+	sum := sha256.Sum256([]byte(namespace + ":" + name))
+	return idl.IdlDiscriminator(sum[:8])
+}
+
+func convertInstruction(inst legacyInstruction, namespace string) (idl.IdlInstruction, error) {
+	args, err := convertFields(inst.Args)
+	if err != nil {
+		return idl.IdlInstruction{}, err
+	}
+	accounts, err := convertAccountItems(inst.Accounts, inst.Args)
+	if err != nil {
+		return idl.IdlInstruction{}, err
+	}
+
+	converted := idl.IdlInstruction{
+		Name:          inst.Name,
+		Docs:          inst.Docs,
+		Discriminator: sighash(namespace, util.ToRustSnakeCase(inst.Name)),
+		Accounts:      accounts,
+		Args:          args,
+	}
+	if len(inst.Returns) > 0 && string(inst.Returns) != "null" {
+		if converted.Returns, err = convertType(inst.Returns); err != nil {
+			return idl.IdlInstruction{}, fmt.Errorf("returns: %w", err)
+		}
+	}
+	return converted, nil
+}
+
+func convertAccountItems(items []legacyAccountItem, args []legacyField) ([]idl.IdlInstructionAccountItem, error) {
+	converted := make([]idl.IdlInstructionAccountItem, 0, len(items))
+	for i, item := range items {
+		if item.Accounts != nil {
+			accounts, err := convertAccountItems(item.Accounts, args)
+			if err != nil {
+				return nil, fmt.Errorf("accounts[%d].%w", i, err)
+			}
+			converted = append(converted, idl.IdlInstructionAccountItem{
+				IdlInstructionAccounts: &idl.IdlInstructionAccounts{
+					Name:     item.Name,
+					Accounts: accounts,
+				},
+			})
+			continue
+		}
+
+		account := &idl.IdlInstructionAccount{
+			Name:      item.Name,
+			Docs:      item.Docs,
+			Writable:  item.IsMut,
+			Signer:    item.IsSigner,
+			Optional:  item.IsOptional,
+			Relations: item.Relations,
+		}
+		if item.Pda != nil {
+			pda, err := convertPda(item.Pda)
+			if err != nil {
+				return nil, fmt.Errorf("accounts[%d].pda: %w", i, err)
+			}
+			account.Pda = pda
+		}
+		converted = append(converted, idl.IdlInstructionAccountItem{IdlInstructionAccount: account})
+	}
+	return converted, nil
+}
+
+func convertPda(pda *legacyPda) (*idl.IdlPda, error) {
+	converted := &idl.IdlPda{}
+	for i, seed := range pda.Seeds {
+		s, err := convertSeed(seed)
+		if err != nil {
+			return nil, fmt.Errorf("seeds[%d]: %w", i, err)
+		}
+		converted.Seeds = append(converted.Seeds, s)
+	}
+	if pda.ProgramID != nil {
+		s, err := convertSeed(*pda.ProgramID)
+		if err != nil {
+			return nil, fmt.Errorf("programId: %w", err)
+		}
+		converted.Program = &s
+	}
+	return converted, nil
+}
+
+func convertSeed(seed legacySeed) (idl.IdlSeed, error) {
+	switch seed.Kind {
+	case "const":
+		typ, err := convertType(seed.Type)
+		if err != nil {
+			return idl.IdlSeed{}, err
+		}
+		value, err := constSeedBytes(typ, seed.Value)
+		if err != nil {
+			return idl.IdlSeed{}, err
+		}
+		return idl.IdlSeed{IdlSeedConst: &idl.IdlSeedConst{Kind: "const", Value: value}}, nil
+	case "arg":
+		return idl.IdlSeed{IdlSeedArg: &idl.IdlSeedArg{Kind: "arg", Path: seed.Path}}, nil
+	case "account":
+		return idl.IdlSeed{IdlSeedAccount: &idl.IdlSeedAccount{Kind: "account", Path: seed.Path, Account: seed.Account}}, nil
+	default:
+		return idl.IdlSeed{}, fmt.Errorf("unknown seed kind: %s", seed.Kind)
+	}
+}
+
+func convertFields(fields []legacyField) ([]idl.IdlField, error) {
+	converted := make([]idl.IdlField, 0, len(fields))
+	for i, field := range fields {
+		typ, err := convertType(field.Type)
+		if err != nil {
+			return nil, fmt.Errorf("fields[%d]: %w", i, err)
+		}
+		converted = append(converted, idl.IdlField{
+			Name: field.Name,
+			Docs: field.Docs,
+			Type: typ,
+		})
+	}
+	return converted, nil
+}
+
+func structTy(fields []idl.IdlField) idl.IdlTypeDefTy {
+	ty := &idl.IdlTypeDefTyStruct{Kind: "struct"}
+	if len(fields) > 0 {
+		ty.Fields = &idl.IdlDefinedFields{IdlDefinedFieldsNamed: &idl.IdlDefinedFieldsNamed{Fields: fields}}
+	}
+	return idl.IdlTypeDefTy{IdlTypeDefTyStruct: ty}
+}
+
+func convertTypeDef(typ legacyTypeDef) (idl.IdlTypeDef, error) {
+	converted := idl.IdlTypeDef{
+		Name: typ.Name,
+		Docs: typ.Docs,
+	}
+
+	switch typ.Type.Kind {
+	case "struct":
+		fields, err := convertFields(typ.Type.Fields)
+		if err != nil {
+			return idl.IdlTypeDef{}, err
+		}
+		converted.Type = structTy(fields)
+	case "enum":
+		enum := &idl.IdlTypeDefTyEnum{Kind: "enum"}
+		for i, variant := range typ.Type.Variants {
+			fields, err := convertVariantFields(variant.Fields)
+			if err != nil {
+				return idl.IdlTypeDef{}, fmt.Errorf("variants[%d].%w", i, err)
+			}
+			enum.Variants = append(enum.Variants, idl.IdlEnumVariant{Name: variant.Name, Fields: fields})
+		}
+		converted.Type = idl.IdlTypeDefTy{IdlTypeDefTyEnum: enum}
+	case "alias":
+		alias, err := convertType(typ.Type.Value)
+		if err != nil {
+			return idl.IdlTypeDef{}, err
+		}
+		converted.Type = idl.IdlTypeDefTy{IdlTypeDefTyType: &idl.IdlTypeDefTyType{Kind: "type", Alias: alias}}
+	default:
+		return idl.IdlTypeDef{}, fmt.Errorf("unknown typedef kind: %s", typ.Type.Kind)
+	}
+	return converted, nil
+}
+
+func convertVariantFields(fields []json.RawMessage) (*idl.IdlDefinedFields, error) {
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	// Named fields are objects with `name`, tuple fields are types.
+	var named legacyField
+	if err := json.Unmarshal(fields[0], &named); err == nil && named.Name != "" && len(named.Type) > 0 {
+		legacyFields := make([]legacyField, len(fields))
+		for i, field := range fields {
+			if err := json.Unmarshal(field, &legacyFields[i]); err != nil {
+				return nil, fmt.Errorf("fields[%d]: %w", i, err)
+			}
+		}
+		converted, err := convertFields(legacyFields)
+		if err != nil {
+			return nil, err
+		}
+		return &idl.IdlDefinedFields{IdlDefinedFieldsNamed: &idl.IdlDefinedFieldsNamed{Fields: converted}}, nil
+	}
+
+	types := make([]idl.IdlType, 0, len(fields))
+	for i, field := range fields {
+		typ, err := convertType(field)
+		if err != nil {
+			return nil, fmt.Errorf("fields[%d]: %w", i, err)
+		}
+		types = append(types, typ)
+	}
+	return &idl.IdlDefinedFields{IdlDefinedFieldsTuple: &idl.IdlDefinedFieldsTuple{Types: types}}, nil
+}
@@ -0,0 +1,293 @@
+package legacy
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/idl"
+	"github.com/davecgh/go-spew/spew"
+)
+
+const legacyIdlJson = `{
+  "version": "0.1.0",
+  "name": "vault_prog",
+  "docs": ["A vault."],
+  "constants": [{"name": "SEED", "type": "bytes", "value": "b\"vault\""}],
+  "instructions": [
+    {
+      "name": "initializeVault",
+      "accounts": [
+        {"name": "vault", "isMut": true, "isSigner": false, "pda": {"seeds": [
+          {"kind": "const", "type": "string", "value": "vault"},
+          {"kind": "const", "type": "u64", "value": 1},
+          {"kind": "account", "type": "publicKey", "path": "authority"},
+          {"kind": "arg", "type": "u8", "path": "bump"}
+        ]}},
+        {"name": "authority", "isMut": false, "isSigner": true},
+        {"name": "common", "accounts": [
+          {"name": "systemProgram", "isMut": false, "isSigner": false, "isOptional": true}
+        ]}
+      ],
+      "args": [{"name": "bump", "type": "u8"}]
+    },
+    {
+      "name": "deposit",
+      "accounts": [{"name": "vault", "isMut": true, "isSigner": false, "relations": ["authority"]}],
+      "args": [{"name": "amount", "type": {"option": "u64"}}],
+      "returns": "u128"
+    }
+  ],
+  "state": {
+    "struct": {"name": "ProgramState", "type": {"kind": "struct", "fields": [{"name": "fee", "type": "u16"}]}},
+    "methods": [{"name": "setFee", "accounts": [], "args": [{"name": "fee", "type": "u16"}]}]
+  },
+  "accounts": [
+    {"name": "Vault", "type": {"kind": "struct", "fields": [
+      {"name": "authority", "type": "publicKey"},
+      {"name": "delegate", "type": {"coption": "publicKey"}},
+      {"name": "kind", "type": {"defined": "Kind"}},
+      {"name": "history", "type": {"vec": {"array": ["i64", 4]}}}
+    ]}}
+  ],
+  "events": [{"name": "Deposited", "fields": [{"name": "amount", "type": "u64", "index": false}]}],
+  "types": [
+    {"name": "Kind", "type": {"kind": "enum", "variants": [
+      {"name": "Empty"},
+      {"name": "Named", "fields": [{"name": "value", "type": "i8"}]},
+      {"name": "Tuple", "fields": ["bool", {"defined": "Amount"}]}
+    ]}},
+    {"name": "Amount", "type": {"kind": "alias", "value": "u64"}}
+  ],
+  "errors": [{"code": 6000, "name": "Unauthorized", "msg": "Unauthorized"}],
+  "metadata": {"address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS"}
+}`
+
+// The discriminators are the first 8 bytes of `sha256("<namespace>:<name>")`,
+// e.g. `sha256("global:initialize_vault")` for the `initializeVault` instruction.
+const convertedIdlJson = `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "docs": ["A vault."],
+  "instructions": [
+    {
+      "name": "initializeVault",
+      "discriminator": [48, 191, 163, 44, 71, 129, 63, 164],
+      "accounts": [
+        {"name": "vault", "writable": true, "pda": {"seeds": [
+          {"kind": "const", "value": [118, 97, 117, 108, 116]},
+          {"kind": "const", "value": [1, 0, 0, 0, 0, 0, 0, 0]},
+          {"kind": "account", "path": "authority"},
+          {"kind": "arg", "path": "bump"}
+        ]}},
+        {"name": "authority", "signer": true},
+        {"name": "common", "accounts": [{"name": "systemProgram", "optional": true}]}
+      ],
+      "args": [{"name": "bump", "type": "u8"}]
+    },
+    {
+      "name": "deposit",
+      "discriminator": [242, 35, 198, 137, 82, 225, 242, 182],
+      "accounts": [{"name": "vault", "writable": true, "relations": ["authority"]}],
+      "args": [{"name": "amount", "type": {"option": "u64"}}],
+      "returns": "u128"
+    },
+    {
+      "name": "setFee",
+      "discriminator": [240, 160, 12, 23, 70, 91, 139, 31],
+      "accounts": [],
+      "args": [{"name": "fee", "type": "u16"}]
+    }
+  ],
+  "accounts": [
+    {"name": "ProgramState", "discriminator": [77, 209, 137, 229, 149, 67, 167, 230]},
+    {"name": "Vault", "discriminator": [211, 8, 232, 43, 2, 152, 117, 119]}
+  ],
+  "events": [{"name": "Deposited", "discriminator": [111, 141, 26, 45, 161, 35, 100, 57]}],
+  "errors": [{"code": 6000, "name": "Unauthorized", "msg": "Unauthorized"}],
+  "types": [
+    {"name": "ProgramState", "type": {"kind": "struct", "fields": [{"name": "fee", "type": "u16"}]}},
+    {"name": "Vault", "type": {"kind": "struct", "fields": [
+      {"name": "authority", "type": "pubkey"},
+      {"name": "delegate", "type": {"coption": "pubkey"}},
+      {"name": "kind", "type": {"defined": {"name": "Kind"}}},
+      {"name": "history", "type": {"vec": {"array": ["i64", 4]}}}
+    ]}},
+    {"name": "Deposited", "type": {"kind": "struct", "fields": [{"name": "amount", "type": "u64"}]}},
+    {"name": "Kind", "type": {"kind": "enum", "variants": [
+      {"name": "Empty"},
+      {"name": "Named", "fields": [{"name": "value", "type": "i8"}]},
+      {"name": "Tuple", "fields": ["bool", {"defined": {"name": "Amount"}}]}
+    ]}},
+    {"name": "Amount", "type": {"kind": "type", "alias": "u64"}}
+  ],
+  "constants": [{"name": "SEED", "type": "bytes", "value": "b\"vault\""}]
+}`
+
+func TestIsLegacy(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected bool
+	}{
+		{"legacy", legacyIdlJson, true},
+		{"legacy with metadata address", `{"name": "p", "instructions": [], "metadata": {"address": "x"}}`, true},
+		{"current spec", convertedIdlJson, false},
+		{"no instructions", `{"name": "p"}`, false},
+		{"not an object", `[]`, false},
+		{"invalid JSON", `{`, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsLegacy([]byte(tt.data)); got != tt.expected {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	program, err := Convert([]byte(legacyIdlJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, err := idl.Parse([]byte(convertedIdlJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The checksum is of the JSON which the expected IDL is parsed from.
+	expected.Checksum = program.Checksum
+	if !reflect.DeepEqual(program, expected) {
+		t.Errorf("got:\n%s\nexpected:\n%s", spew.Sdump(program), spew.Sdump(expected))
+	}
+}
+
+func TestConvertErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		data string
+		err  string
+	}{
+		{
+			name: "unknown simple type",
+			data: `{"name": "p", "instructions": [{"name": "a", "accounts": [], "args": [{"name": "x", "type": "u512"}]}]}`,
+			err:  "instructions[0]: fields[0]: unknown simple type: u512",
+		},
+		{
+			name: "unknown seed kind",
+			data: `{"name": "p", "instructions": [{"name": "a", "accounts": [{"name": "b", "pda": {"seeds": [{"kind": "magic"}]}}], "args": []}]}`,
+			err:  "instructions[0]: accounts[0].pda: seeds[0]: unknown seed kind: magic",
+		},
+		{
+			name: "const seed overflow",
+			data: `{"name": "p", "instructions": [{"name": "a", "accounts": [{"name": "b", "pda": {"seeds": [{"kind": "const", "type": "u8", "value": 256}]}}], "args": []}]}`,
+			err:  "instructions[0]: accounts[0].pda: seeds[0]: 256 overflows u8",
+		},
+		{
+			name: "negative unsigned const seed",
+			data: `{"name": "p", "instructions": [{"name": "a", "accounts": [{"name": "b", "pda": {"seeds": [{"kind": "const", "type": "u16", "value": -1}]}}], "args": []}]}`,
+			err:  "instructions[0]: accounts[0].pda: seeds[0]: invalid u16: -1",
+		},
+		{
+			name: "unknown typedef kind",
+			data: `{"name": "p", "instructions": [], "types": [{"name": "T", "type": {"kind": "union"}}]}`,
+			err:  "types[0]: unknown typedef kind: union",
+		},
+		{
+			name: "invalid array",
+			data: `{"name": "p", "instructions": [], "accounts": [{"name": "A", "type": {"kind": "struct", "fields": [{"name": "x", "type": {"array": ["u8"]}}]}}]}`,
+			err:  "accounts[0]: fields[0]: array type must have 2 elements",
+		},
+		{
+			name: "every error",
+			data: `{"name": "p", "instructions": [], "types": [{"name": "T", "type": {"kind": "union"}}], "constants": [{"name": "C", "type": "u512", "value": "1"}]}`,
+			err:  "types[0]: unknown typedef kind: union\nconstants[0]: unknown simple type: u512",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Convert([]byte(tt.data))
+			if err == nil {
+				t.Fatalf("expected error %q", tt.err)
+			}
+			expected := "failed to convert legacy IDL: " + tt.err
+			if err.Error() != expected {
+				t.Errorf("got error %q, expected %q", err, expected)
+			}
+		})
+	}
+}
+
+func TestConstSeedBytes(t *testing.T) {
+	tests := []struct {
+		typ      string
+		value    string
+		expected []byte
+	}{
+		{`"string"`, `"ab"`, []byte("ab")},
+		{`"bytes"`, `"AQI="`, []byte{1, 2}},
+		{`"bool"`, `true`, []byte{1}},
+		{`"bool"`, `false`, []byte{0}},
+		{`"i16"`, `-2`, []byte{0xfe, 0xff}},
+		{`"u32"`, `"258"`, []byte{2, 1, 0, 0}},
+		{`"i128"`, `-1`, bytes.Repeat([]byte{0xff}, 16)},
+		{`"publicKey"`, `"11111111111111111111111111111111"`, make([]byte, 32)},
+		{`{"array": ["u8", 2]}`, `[3, 4]`, []byte{3, 4}},
+		{`{"vec": "u8"}`, `[5]`, []byte{5}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ+" "+tt.value, func(t *testing.T) {
+			typ, err := convertType(json.RawMessage(tt.typ))
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := constSeedBytes(typ, json.RawMessage(tt.value))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.expected) {
+				t.Errorf("got %v, expected %v", got, tt.expected)
+			}
+		})
+	}
+}
+
+// The seeds of the legacy IDLs refer to the accounts of the program and to the accounts of other programs.
+const legacySeedsIdlJson = `{
+  "version": "0.1.0",
+  "name": "staking",
+  "instructions": [
+    {
+      "name": "stake",
+      "accounts": [
+        {"name": "pool", "isMut": false, "isSigner": false},
+        {"name": "tokenAccount", "isMut": false, "isSigner": false},
+        {"name": "stake", "isMut": true, "isSigner": false, "pda": {"seeds": [
+          {"kind": "account", "type": "publicKey", "account": "Pool", "path": "pool.mint"},
+          {"kind": "account", "type": "publicKey", "account": "TokenAccount", "path": "tokenAccount.owner"}
+        ]}}
+      ],
+      "args": []
+    }
+  ],
+  "accounts": [
+    {"name": "Pool", "type": {"kind": "struct", "fields": [{"name": "mint", "type": "publicKey"}]}}
+  ],
+  "metadata": {"address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS"}
+}`
+
+func TestValidateConverted(t *testing.T) {
+	program, err := Convert([]byte(legacySeedsIdlJson))
+	if err != nil {
+		t.Fatal(err)
+	}
+	diags := program.Validate(idl.ValidateOptions{})
+	if diags.HasErrors() {
+		t.Fatalf("the converted IDL is invalid: %v", diags)
+	}
+	expected := "[warning] staking: instructions[0].accounts[2].pda.seeds[1]: account type TokenAccount of path tokenAccount.owner is not declared in the IDL, the address of account tokenAccount is not derived"
+	if len(diags) != 1 || diags[0].String() != expected {
+		t.Errorf("got diagnostics %v, expected %s", diags, expected)
+	}
+}
@@ -0,0 +1,189 @@
+package legacy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/idl"
+	"github.com/gagliardetto/solana-go"
+)
+
+// convertType converts a legacy type, which differs from the current spec in:
+//   - `publicKey` instead of `pubkey`
+//   - `{"defined": "<name>"}` instead of `{"defined": {"name": "<name>"}}`
+//   - `{"coption": <type>}` which is removed from the current spec
+func convertType(data json.RawMessage) (idl.IdlType, error) {
+	var simple string
+	if err := json.Unmarshal(data, &simple); err == nil {
+		if simple == "publicKey" {
+			simple = idl.IdlTypeSimplePubkey.String()
+		}
+		s := idl.IdlTypeSimple(simple)
+		if !s.IsValid() {
+			return idl.IdlType{}, fmt.Errorf("unknown simple type: %s", simple)
+		}
+		return idl.IdlType{IdlTypeSimple: &s}, nil
+	}
+
+	var objMap map[string]json.RawMessage
+	if err := json.Unmarshal(data, &objMap); err != nil {
+		return idl.IdlType{}, fmt.Errorf("invalid type: %s", data)
+	}
+
+	if inner, ok := objMap["option"]; ok {
+		typ, err := convertType(inner)
+		if err != nil {
+			return idl.IdlType{}, err
+		}
+		return idl.IdlType{IdlTypeOption: &idl.IdlTypeOption{Option: typ}}, nil
+	}
+
+	if inner, ok := objMap["coption"]; ok {
+		typ, err := convertType(inner)
+		if err != nil {
+			return idl.IdlType{}, err
+		}
+		return idl.IdlType{IdlTypeOption: &idl.IdlTypeOption{Option: typ, COption: true}}, nil
+	}
+
+	if inner, ok := objMap["vec"]; ok {
+		typ, err := convertType(inner)
+		if err != nil {
+			return idl.IdlType{}, err
+		}
+		return idl.IdlType{IdlTypeVec: &idl.IdlTypeVec{Vec: typ}}, nil
+	}
+
+	if inner, ok := objMap["array"]; ok {
+		var array []json.RawMessage
+		if err := json.Unmarshal(inner, &array); err != nil {
+			return idl.IdlType{}, err
+		}
+		if len(array) != 2 {
+			return idl.IdlType{}, errors.New("array type must have 2 elements")
+		}
+		elem, err := convertType(array[0])
+		if err != nil {
+			return idl.IdlType{}, err
+		}
+		var arrayLen idl.IdlArrayLen
+		if err := json.Unmarshal(array[1], &arrayLen); err != nil {
+			return idl.IdlType{}, err
+		}
+		return idl.IdlType{IdlTypeArray: &idl.IdlTypeArray{Elem: elem, Len: arrayLen}}, nil
+	}
+
+	if inner, ok := objMap["defined"]; ok {
+		var name string
+		if err := json.Unmarshal(inner, &name); err == nil {
+			return idl.IdlType{IdlTypeDefined: &idl.IdlTypeDefined{Name: name}}, nil
+		}
+		// Already in the current spec.
+		var defined idl.IdlTypeDefined
+		if err := json.Unmarshal(inner, &defined); err != nil {
+			return idl.IdlType{}, err
+		}
+		return idl.IdlType{IdlTypeDefined: &defined}, nil
+	}
+
+	// The other types (e.g. `generic`) are the same as the current spec.
+	var typ idl.IdlType
+	if err := json.Unmarshal(data, &typ); err != nil {
+		return idl.IdlType{}, err
+	}
+	return typ, nil
+}
+
+// constSeedBytes encodes the value of a const seed, which is in JSON in the legacy IDL.
+func constSeedBytes(typ idl.IdlType, value json.RawMessage) ([]byte, error) {
+	switch {
+	case typ.IsSimple():
+		switch simple := typ.GetSimple(); simple {
+		case idl.IdlTypeSimpleString:
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, err
+			}
+			return []byte(s), nil
+		case idl.IdlTypeSimpleBytes:
+			var b []byte
+			if err := json.Unmarshal(value, &b); err != nil {
+				return nil, err
+			}
+			return b, nil
+		case idl.IdlTypeSimplePubkey:
+			var s string
+			if err := json.Unmarshal(value, &s); err != nil {
+				return nil, err
+			}
+			key, err := solana.PublicKeyFromBase58(s)
+			if err != nil {
+				return nil, err
+			}
+			return key.Bytes(), nil
+		case idl.IdlTypeSimpleBool:
+			var b bool
+			if err := json.Unmarshal(value, &b); err != nil {
+				return nil, err
+			}
+			if b {
+				return []byte{1}, nil
+			}
+			return []byte{0}, nil
+		default:
+			return intSeedBytes(simple, value)
+		}
+	case typ.IsArray() && typ.GetArray().Elem.IsSimple() && typ.GetArray().Elem.GetSimple() == idl.IdlTypeSimpleU8,
+		typ.IsVec() && typ.GetVec().Vec.IsSimple() && typ.GetVec().Vec.GetSimple() == idl.IdlTypeSimpleU8:
+		var b []byte
+		if err := json.Unmarshal(value, &b); err != nil {
+			return nil, err
+		}
+		return b, nil
+	default:
+		return nil, fmt.Errorf("unsupported type of const seed: %s", value)
+	}
+}
+
+// intSeedBytes encodes the integer in little endian, the value is either a number or a string.
+func intSeedBytes(typ idl.IdlTypeSimple, value json.RawMessage) ([]byte, error) {
+	var size int
+	switch typ {
+	case idl.IdlTypeSimpleU8, idl.IdlTypeSimpleI8:
+		size = 1
+	case idl.IdlTypeSimpleU16, idl.IdlTypeSimpleI16:
+		size = 2
+	case idl.IdlTypeSimpleU32, idl.IdlTypeSimpleI32:
+		size = 4
+	case idl.IdlTypeSimpleU64, idl.IdlTypeSimpleI64:
+		size = 8
+	case idl.IdlTypeSimpleU128, idl.IdlTypeSimpleI128:
+		size = 16
+	default:
+		return nil, fmt.Errorf("unsupported type of const seed: %s", typ)
+	}
+
+	n, ok := new(big.Int).SetString(strings.Trim(string(value), `"`), 10)
+	if !ok {
+		return nil, fmt.Errorf("invalid %s: %s", typ, value)
+	}
+	signed := strings.HasPrefix(typ.String(), "i")
+	if n.Sign() < 0 {
+		if !signed {
+			return nil, fmt.Errorf("invalid %s: %s", typ, value)
+		}
+		// Two's complement.
+		n.Add(n, new(big.Int).Lsh(big.NewInt(1), uint(size*8)))
+	}
+	if n.Sign() < 0 || n.BitLen() > size*8 {
+		return nil, fmt.Errorf("%s overflows %s", value, typ)
+	}
+
+	le := n.FillBytes(make([]byte, size))
+	slices.Reverse(le)
+	return le, nil
+}
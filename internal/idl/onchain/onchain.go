@@ -0,0 +1,128 @@
+// Package onchain decodes the IDL account which Anchor stores on chain.
+// Ref: https://github.com/solana-foundation/anchor/blob/v0.31.1/lang/src/idl.rs
+package onchain
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// IdlSeed is the seed of the IDL account address, derived from the base PDA of the program.
+const IdlSeed = "anchor:idl"
+
+// Discriminator of the `IdlAccount`.
+var Discriminator = func() [8]byte {
+	sum := sha256.Sum256([]byte("account:IdlAccount"))
+	return [8]byte(sum[:8])
+}()
+
+// headerSize is the size of the discriminator, the authority and the length of the data.
+const headerSize = 8 + solana.PublicKeyLength + 4
+
+// IdlAccount is the decoded IDL account.
+type IdlAccount struct {
+	Authority solana.PublicKey
+	// Inflated JSON of the IDL.
+	Data []byte
+}
+
+// IdlAddress returns the address of the IDL account of the program,
+// which is created with the seed `anchor:idl` off the base PDA (found with no seeds) of the program.
+func IdlAddress(programID solana.PublicKey) (solana.PublicKey, error) {
+	base, _, err := solana.FindProgramAddress(nil, programID)
+	if err != nil {
+		return solana.PublicKey{}, err
+	}
+	return solana.CreateWithSeed(base, IdlSeed, programID)
+}
+
+// Decode decodes the IDL account: an 8 bytes discriminator, the authority pubkey, a u32 length and the zlib-compressed JSON.
+// The data is either raw or encoded in base64, as returned by `getAccountInfo`
+// (the JSON-RPC response and its `data` field are accepted too).
+func Decode(data []byte) (*IdlAccount, error) {
+	raw, err := accountData(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(raw) < headerSize {
+		return nil, fmt.Errorf("IDL account is too short: %d bytes", len(raw))
+	}
+	if !bytes.Equal(raw[:8], Discriminator[:]) {
+		return nil, fmt.Errorf("not an IDL account: wrong discriminator %v", raw[:8])
+	}
+
+	account := &IdlAccount{
+		Authority: solana.PublicKeyFromBytes(raw[8 : 8+solana.PublicKeyLength]),
+	}
+	dataLen := binary.LittleEndian.Uint32(raw[headerSize-4 : headerSize])
+	if uint64(len(raw)-headerSize) < uint64(dataLen) {
+		return nil, fmt.Errorf("IDL account is truncated: want %d bytes of data, got %d", dataLen, len(raw)-headerSize)
+	}
+
+	reader, err := zlib.NewReader(bytes.NewReader(raw[headerSize : headerSize+int(dataLen)]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to inflate IDL: %w", err)
+	}
+	defer reader.Close()
+	if account.Data, err = io.ReadAll(reader); err != nil {
+		return nil, fmt.Errorf("failed to inflate IDL: %w", err)
+	}
+	return account, nil
+}
+
+// accountData returns the raw data of the account, which starts with the discriminator if it's an IDL account.
+// The data which is neither raw account data, base64 nor JSON with base64 data is rejected.
+func accountData(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, Discriminator[:]) {
+		return data, nil
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && (trimmed[0] == '{' || trimmed[0] == '[') {
+		var value any
+		if err := json.Unmarshal(trimmed, &value); err != nil {
+			return nil, fmt.Errorf("invalid JSON: %w", err)
+		}
+		encoded, ok := findBase64Data(value)
+		if !ok {
+			return nil, errors.New("no base64 data found in the JSON")
+		}
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, fmt.Errorf("invalid base64 data in the JSON: %w", err)
+		}
+		return decoded, nil
+	}
+	decoded, err := base64.StdEncoding.DecodeString(string(trimmed))
+	if err != nil {
+		return nil, errors.New("not an IDL account: the data is neither raw account data, base64 nor the JSON of an account")
+	}
+	return decoded, nil
+}
+
+// findBase64Data finds the `["<data>", "base64"]` of the account in the JSON.
+func findBase64Data(value any) (string, bool) {
+	switch v := value.(type) {
+	case []any:
+		if len(v) == 2 && v[1] == "base64" {
+			encoded, ok := v[0].(string)
+			return encoded, ok
+		}
+	case map[string]any:
+		for _, key := range []string{"result", "value", "data"} {
+			if inner, ok := v[key]; ok {
+				return findBase64Data(inner)
+			}
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,173 @@
+package onchain
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gagliardetto/solana-go"
+)
+
+// readFixtures returns the getAccountInfo response of the IDL account in testdata, its raw data and the inflated IDL.
+func readFixtures(t *testing.T) (response, raw, idl []byte) {
+	t.Helper()
+	response, err := os.ReadFile(filepath.Join("testdata", "idl_account.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	idl, err = os.ReadFile(filepath.Join("testdata", "idl.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var parsed struct {
+		Result struct {
+			Value struct {
+				Data []string `json:"data"`
+			} `json:"value"`
+		} `json:"result"`
+	}
+	if err := json.Unmarshal(response, &parsed); err != nil {
+		t.Fatal(err)
+	}
+	if raw, err = base64.StdEncoding.DecodeString(parsed.Result.Value.Data[0]); err != nil {
+		t.Fatal(err)
+	}
+	return response, raw, idl
+}
+
+func TestDecode(t *testing.T) {
+	response, raw, idl := readFixtures(t)
+	encoded := base64.StdEncoding.EncodeToString(raw)
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"raw", raw},
+		{"base64", []byte(encoded + "\n")},
+		{"getAccountInfo response", response},
+		{"account", []byte(`{"data": ["` + encoded + `", "base64"], "owner": "11111111111111111111111111111111"}`)},
+		{"data field", []byte(`["` + encoded + `", "base64"]`)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			account, err := Decode(tt.data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expected := solana.MustPublicKeyFromBase58("Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS"); account.Authority != expected {
+				t.Errorf("got authority %s, expected %s", account.Authority, expected)
+			}
+			if !bytes.Equal(account.Data, idl) {
+				t.Errorf("got IDL %s, expected %s", account.Data, idl)
+			}
+		})
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	_, raw, _ := readFixtures(t)
+	withDataLen := func(dataLen uint32) []byte {
+		data := bytes.Clone(raw)
+		binary.LittleEndian.PutUint32(data[headerSize-4:headerSize], dataLen)
+		return data
+	}
+	wrongDiscriminator := bytes.Clone(raw)
+	wrongDiscriminator[0]++
+	corrupted := bytes.Clone(raw)
+	corrupted[headerSize] ^= 0xff
+
+	tests := []struct {
+		name string
+		data []byte
+		err  string
+	}{
+		{
+			name: "truncated length",
+			data: withDataLen(uint32(len(raw))),
+			err:  "IDL account is truncated: want 317 bytes of data, got 273",
+		},
+		{
+			name: "too short",
+			data: raw[:headerSize-1],
+			err:  "IDL account is too short: 43 bytes",
+		},
+		{
+			name: "wrong discriminator",
+			data: []byte(base64.StdEncoding.EncodeToString(wrongDiscriminator)),
+			err:  "not an IDL account: wrong discriminator",
+		},
+		{
+			name: "raw data without discriminator",
+			data: wrongDiscriminator,
+			err:  "not an IDL account: the data is neither raw account data, base64 nor the JSON of an account",
+		},
+		{
+			name: "text",
+			data: []byte("not an account"),
+			err:  "not an IDL account: the data is neither raw account data, base64 nor the JSON of an account",
+		},
+		{
+			name: "invalid JSON",
+			data: []byte(`{"result": `),
+			err:  "invalid JSON",
+		},
+		{
+			name: "JSON without data",
+			data: []byte(`{"result": {"value": null}}`),
+			err:  "no base64 data found in the JSON",
+		},
+		{
+			name: "invalid base64 in the JSON",
+			data: []byte(`{"data": ["!!", "base64"]}`),
+			err:  "invalid base64 data in the JSON",
+		},
+		{
+			name: "not compressed",
+			data: corrupted,
+			err:  "failed to inflate IDL",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decode(tt.data)
+			if err == nil {
+				t.Fatalf("expected error %q", tt.err)
+			}
+			if !strings.HasPrefix(err.Error(), tt.err) {
+				t.Errorf("got error %q, expected %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestIdlAddress(t *testing.T) {
+	programID := solana.MustPublicKeyFromBase58("Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS")
+	address, err := IdlAddress(programID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The base is the PDA found with no seeds, with the highest bump which is off the curve.
+	var base []byte
+	for bump := 255; bump >= 0 && base == nil; bump-- {
+		sum := sha256.Sum256(append(append([]byte{byte(bump)}, programID[:]...), "ProgramDerivedAddress"...))
+		if !solana.IsOnCurve(sum[:]) {
+			base = sum[:]
+		}
+	}
+	// `Pubkey::create_with_seed(&base, "anchor:idl", program_id)`
+	expected := sha256.Sum256(append(append(base, "anchor:idl"...), programID[:]...))
+	if address != solana.PublicKeyFromBytes(expected[:]) {
+		t.Errorf("got address %s, expected %s", address, solana.PublicKeyFromBytes(expected[:]))
+	}
+	// Pinned, so that a change of the derivation doesn't go unnoticed.
+	if address.String() != "9j6oH2BscegWPVpeiP2mbsN35pDbiBSpyZrpi86S8eWF" {
+		t.Errorf("got address %s for the program %s", address, programID)
+	}
+}
@@ -0,0 +1,23 @@
+package idl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// Parse parses the JSON of an IDL.
+func Parse(data []byte) (*Idl, error) {
+	var idl Idl
+	if err := json.Unmarshal(data, &idl); err != nil {
+		return nil, err
+	}
+	idl.Checksum = Checksum(data)
+	return &idl, nil
+}
+
+// Checksum returns the hex SHA-256 of the JSON of an IDL.
+func Checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -37,10 +37,14 @@ type IdlType struct {
 	*IdlTypeDefined
 	*IdlTypeGeneric
 	*IdlTypeHashMap
+	*IdlTypeHashSet
 }
 
 type IdlTypeOption struct {
 	Option IdlType
+	// !!! Notice: `COption` is not in the current spec, it's the `coption` of the legacy IDL.
+	// The presence is encoded as a 4 bytes flag instead of 1 byte.
+	COption bool
 }
 
 type IdlTypeVec struct {
@@ -65,6 +69,15 @@ type IdlTypeGeneric struct {
 type IdlTypeHashMap struct {
 	Key IdlType
 	Val IdlType
+	// !!! Notice: `BTree` is the `bTreeMap` of the legacy IDL, it's encoded the same as `hashMap`.
+	BTree bool
+}
+
+// !!! Notice: `HashSet` is not a standard type in the IDL spec.
+type IdlTypeHashSet struct {
+	Elem IdlType
+	// !!! Notice: `BTree` is the `bTreeSet` of the legacy IDL, it's encoded the same as `hashSet`.
+	BTree bool
 }
 
 type IdlArrayLen struct {
@@ -99,6 +112,20 @@ func (simple IdlTypeSimple) String() string {
 	return string(simple)
 }
 
+func (simple IdlTypeSimple) IsValid() bool {
+	switch simple {
+	case IdlTypeSimpleBool, IdlTypeSimpleU8, IdlTypeSimpleI8,
+		IdlTypeSimpleU16, IdlTypeSimpleI16, IdlTypeSimpleU32,
+		IdlTypeSimpleI32, IdlTypeSimpleF32, IdlTypeSimpleU64,
+		IdlTypeSimpleI64, IdlTypeSimpleF64, IdlTypeSimpleU128,
+		IdlTypeSimpleI128, IdlTypeSimpleU256, IdlTypeSimpleI256,
+		IdlTypeSimpleBytes, IdlTypeSimpleString, IdlTypeSimplePubkey:
+		return true
+	default:
+		return false
+	}
+}
+
 func (idlType *IdlType) IsSimple() bool {
 	return idlType.IdlTypeSimple != nil
 }
@@ -127,6 +154,16 @@ func (idlType *IdlType) IsHashMap() bool {
 	return idlType.IdlTypeHashMap != nil
 }
 
+func (idlType *IdlType) IsHashSet() bool {
+	return idlType.IdlTypeHashSet != nil
+}
+
+// IsEmpty reports whether no type is set, e.g. an instruction without `returns`.
+func (idlType *IdlType) IsEmpty() bool {
+	return !idlType.IsSimple() && !idlType.IsOption() && !idlType.IsVec() && !idlType.IsArray() &&
+		!idlType.IsDefined() && !idlType.IsGeneric() && !idlType.IsHashMap() && !idlType.IsHashSet()
+}
+
 func (idlType *IdlType) GetSimple() IdlTypeSimple {
 	return *idlType.IdlTypeSimple
 }
@@ -155,21 +192,34 @@ func (idlType *IdlType) GetHashMap() *IdlTypeHashMap {
 	return idlType.IdlTypeHashMap
 }
 
+func (idlType *IdlType) GetHashSet() *IdlTypeHashSet {
+	return idlType.IdlTypeHashSet
+}
+
+// Kind returns the key of the map in the JSON, i.e. `hashMap` or `bTreeMap`.
+func (hashMap *IdlTypeHashMap) Kind() string {
+	if hashMap.BTree {
+		return "bTreeMap"
+	}
+	return "hashMap"
+}
+
+// Kind returns the key of the set in the JSON, i.e. `hashSet` or `bTreeSet`.
+func (hashSet *IdlTypeHashSet) Kind() string {
+	if hashSet.BTree {
+		return "bTreeSet"
+	}
+	return "hashSet"
+}
+
 func (idlType *IdlType) UnmarshalJSON(data []byte) error {
 	var s IdlTypeSimple
 	if err := json.Unmarshal(data, &s); err == nil {
-		switch s {
-		case IdlTypeSimpleBool, IdlTypeSimpleU8, IdlTypeSimpleI8,
-			IdlTypeSimpleU16, IdlTypeSimpleI16, IdlTypeSimpleU32,
-			IdlTypeSimpleI32, IdlTypeSimpleF32, IdlTypeSimpleU64,
-			IdlTypeSimpleI64, IdlTypeSimpleF64, IdlTypeSimpleU128,
-			IdlTypeSimpleI128, IdlTypeSimpleU256, IdlTypeSimpleI256,
-			IdlTypeSimpleBytes, IdlTypeSimpleString, IdlTypeSimplePubkey:
-			idlType.IdlTypeSimple = &s
-			return nil
-		default:
+		if !s.IsValid() {
 			return fmt.Errorf("unknown simple type: %s", s)
 		}
+		idlType.IdlTypeSimple = &s
+		return nil
 	}
 
 	var objMap map[string]json.RawMessage
@@ -188,6 +238,16 @@ func (idlType *IdlType) UnmarshalJSON(data []byte) error {
 		return nil
 	}
 
+	// {"coption": "<innerType>"}
+	if opt, ok := objMap["coption"]; ok {
+		var inner IdlType
+		if err := json.Unmarshal(opt, &inner); err != nil {
+			return err
+		}
+		idlType.IdlTypeOption = &IdlTypeOption{Option: inner, COption: true}
+		return nil
+	}
+
 	// {"vec": "<innerType>"}
 	// {"vec": {}}
 	if vec, ok := objMap["vec"]; ok {
@@ -248,14 +308,19 @@ func (idlType *IdlType) UnmarshalJSON(data []byte) error {
 	}
 
 	// {"hashMap": ["<keyType>", "<valType>"]}
-	if hashMap, ok := objMap["hashMap"]; ok {
+	// {"bTreeMap": ["<keyType>", "<valType>"]}
+	for _, kind := range []string{"hashMap", "bTreeMap"} {
+		hashMap, ok := objMap[kind]
+		if !ok {
+			continue
+		}
 		var inner []any
 		if err := json.Unmarshal(hashMap, &inner); err != nil {
 			return err
 		}
 
 		if len(inner) != 2 {
-			return errors.New("hashMap type must have 2 elements")
+			return fmt.Errorf("%s type must have 2 elements", kind)
 		}
 
 		var elemType IdlType
@@ -269,8 +334,27 @@ func (idlType *IdlType) UnmarshalJSON(data []byte) error {
 		}
 
 		idlType.IdlTypeHashMap = &IdlTypeHashMap{
-			Key: elemType,
-			Val: valType,
+			Key:   elemType,
+			Val:   valType,
+			BTree: kind == "bTreeMap",
+		}
+		return nil
+	}
+
+	// {"hashSet": "<elemType>"}
+	// {"bTreeSet": "<elemType>"}
+	for _, kind := range []string{"hashSet", "bTreeSet"} {
+		hashSet, ok := objMap[kind]
+		if !ok {
+			continue
+		}
+		var inner IdlType
+		if err := json.Unmarshal(hashSet, &inner); err != nil {
+			return err
+		}
+		idlType.IdlTypeHashSet = &IdlTypeHashSet{
+			Elem:  inner,
+			BTree: kind == "bTreeSet",
 		}
 		return nil
 	}
@@ -278,6 +362,38 @@ func (idlType *IdlType) UnmarshalJSON(data []byte) error {
 	return errors.New("unable to unmarshal IdlType")
 }
 
+// IsZero reports whether no type is set, so that an optional type is omitted with the `omitzero` option.
+func (idlType IdlType) IsZero() bool {
+	return idlType.IsEmpty()
+}
+
+func (idlType IdlType) MarshalJSON() ([]byte, error) {
+	switch {
+	case idlType.IsSimple():
+		return json.Marshal(idlType.GetSimple().String())
+	case idlType.IsOption():
+		if idlType.GetOption().COption {
+			return json.Marshal(map[string]IdlType{"coption": idlType.GetOption().Option})
+		}
+		return json.Marshal(map[string]IdlType{"option": idlType.GetOption().Option})
+	case idlType.IsVec():
+		return json.Marshal(map[string]IdlType{"vec": idlType.GetVec().Vec})
+	case idlType.IsArray():
+		return json.Marshal(map[string][2]any{"array": {idlType.GetArray().Elem, idlType.GetArray().Len}})
+	case idlType.IsDefined():
+		return json.Marshal(map[string]*IdlTypeDefined{"defined": idlType.GetDefined()})
+	case idlType.IsGeneric():
+		return json.Marshal(map[string]string{"generic": idlType.GetGeneric().Name})
+	case idlType.IsHashMap():
+		hashMap := idlType.GetHashMap()
+		return json.Marshal(map[string][2]IdlType{hashMap.Kind(): {hashMap.Key, hashMap.Val}})
+	case idlType.IsHashSet():
+		return json.Marshal(map[string]IdlType{idlType.GetHashSet().Kind(): idlType.GetHashSet().Elem})
+	default:
+		return nil, errors.New("unable to marshal empty IdlType")
+	}
+}
+
 func (arrayLen *IdlArrayLen) IsGeneric() bool {
 	return arrayLen.IdlArrayLenGeneric != nil
 }
@@ -326,6 +442,17 @@ func (arrayLen *IdlArrayLen) UnmarshalJSON(data []byte) error {
 	return errors.New("unable to unmarshal IdlArrayLen")
 }
 
+func (arrayLen IdlArrayLen) MarshalJSON() ([]byte, error) {
+	switch {
+	case arrayLen.IsValue():
+		return json.Marshal(arrayLen.GetValue().Value)
+	case arrayLen.IsGeneric():
+		return json.Marshal(map[string]string{"generic": arrayLen.GetGeneric().Value})
+	default:
+		return nil, errors.New("unable to marshal empty IdlArrayLen")
+	}
+}
+
 func (arg *IdlGenericArg) IsType() bool {
 	return arg.IdlGenericArgType != nil
 }
@@ -394,3 +521,14 @@ func (arg *IdlGenericArg) UnmarshalJSON(data []byte) error {
 		return fmt.Errorf("unknown generic arg kind: %s", kind)
 	}
 }
+
+func (arg IdlGenericArg) MarshalJSON() ([]byte, error) {
+	switch {
+	case arg.IsType():
+		return json.Marshal(IdlGenericArgType{Kind: "type", Type: arg.GetType().Type})
+	case arg.IsConst():
+		return json.Marshal(IdlGenericArgConst{Kind: "const", Value: arg.GetConst().Value})
+	default:
+		return nil, errors.New("unable to marshal empty IdlGenericArg")
+	}
+}
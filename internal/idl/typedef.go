@@ -95,14 +95,15 @@ type IdlRepr struct {
 	*IdlReprTransparent
 }
 
+// The fields of the modifier are flattened into the repr in JSON, e.g. `{"kind": "c", "packed": true}`.
 type IdlReprRust struct {
 	Kind     string          `json:"kind"`
-	Modifier IdlReprModifier `json:"modifier,omitempty"`
+	Modifier IdlReprModifier `json:"-"`
 }
 
 type IdlReprC struct {
 	Kind     string          `json:"kind"`
-	Modifier IdlReprModifier `json:"modifier,omitempty"`
+	Modifier IdlReprModifier `json:"-"`
 }
 
 type IdlReprTransparent struct {
@@ -193,6 +194,17 @@ func (def *IdlTypeDefGeneric) UnmarshalJSON(data []byte) error {
 	}
 }
 
+func (def IdlTypeDefGeneric) MarshalJSON() ([]byte, error) {
+	switch {
+	case def.IsType():
+		return json.Marshal(IdlTypeDefGenericType{Kind: "type", Name: def.GetType().Name})
+	case def.IsConst():
+		return json.Marshal(IdlTypeDefGenericConst{Kind: "const", Name: def.GetConst().Name, Type: def.GetConst().Type})
+	default:
+		return nil, errors.New("unable to marshal empty IdlTypeDefGeneric")
+	}
+}
+
 // IsUint8Variant checks if the variant is a simple uint8 variant
 // The variant has no fields data, will be encoded as a simple uint8 enum in `rust`
 func (variant *IdlEnumVariant) IsUint8Variant() bool {
@@ -275,6 +287,24 @@ func (defTy *IdlTypeDefTy) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// IsZero reports whether no type is set, so that an optional type is omitted with the `omitzero` option.
+func (defTy IdlTypeDefTy) IsZero() bool {
+	return !defTy.IsStruct() && !defTy.IsEnum() && !defTy.IsType()
+}
+
+func (defTy IdlTypeDefTy) MarshalJSON() ([]byte, error) {
+	switch {
+	case defTy.IsStruct():
+		return json.Marshal(IdlTypeDefTyStruct{Kind: "struct", Fields: defTy.GetStruct().Fields})
+	case defTy.IsEnum():
+		return json.Marshal(IdlTypeDefTyEnum{Kind: "enum", Variants: defTy.GetEnum().Variants})
+	case defTy.IsType():
+		return json.Marshal(IdlTypeDefTyType{Kind: "type", Alias: defTy.GetType().Alias})
+	default:
+		return nil, errors.New("unable to marshal empty IdlTypeDefTy")
+	}
+}
+
 func (def *IdlDefinedFields) IsNamed() bool {
 	return def != nil && def.IdlDefinedFieldsNamed != nil
 }
@@ -307,6 +337,23 @@ func (def *IdlDefinedFields) UnmarshalJSON(data []byte) error {
 	return errors.New("unable to unmarshal defined fields")
 }
 
+func (def IdlDefinedFields) MarshalJSON() ([]byte, error) {
+	switch {
+	case def.IsNamed():
+		if def.GetNamed().Fields == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(def.GetNamed().Fields)
+	case def.IsTuple():
+		if def.GetTuple().Types == nil {
+			return []byte("[]"), nil
+		}
+		return json.Marshal(def.GetTuple().Types)
+	default:
+		return nil, errors.New("unable to marshal empty IdlDefinedFields")
+	}
+}
+
 func (idlRepr *IdlRepr) IsRust() bool {
 	return idlRepr.IdlReprRust != nil
 }
@@ -352,12 +399,18 @@ func (idlRepr *IdlRepr) UnmarshalJSON(data []byte) error {
 		if err := json.Unmarshal(data, &repr); err != nil {
 			return err
 		}
+		if err := json.Unmarshal(data, &repr.Modifier); err != nil {
+			return err
+		}
 		idlRepr.IdlReprRust = &repr
 	case "c":
 		var repr IdlReprC
 		if err := json.Unmarshal(data, &repr); err != nil {
 			return err
 		}
+		if err := json.Unmarshal(data, &repr.Modifier); err != nil {
+			return err
+		}
 		idlRepr.IdlReprC = &repr
 	case "transparent":
 		var repr IdlReprTransparent
@@ -371,3 +424,20 @@ func (idlRepr *IdlRepr) UnmarshalJSON(data []byte) error {
 
 	return nil
 }
+
+func (idlRepr IdlRepr) MarshalJSON() ([]byte, error) {
+	type flattened struct {
+		Kind string `json:"kind"`
+		IdlReprModifier
+	}
+	switch {
+	case idlRepr.IsRust():
+		return json.Marshal(flattened{Kind: "rust", IdlReprModifier: idlRepr.GetRust().Modifier})
+	case idlRepr.IsC():
+		return json.Marshal(flattened{Kind: "c", IdlReprModifier: idlRepr.GetC().Modifier})
+	case idlRepr.IsTransparent():
+		return json.Marshal(IdlReprTransparent{Kind: "transparent"})
+	default:
+		return nil, errors.New("unable to marshal empty IdlRepr")
+	}
+}
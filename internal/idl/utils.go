@@ -3,6 +3,7 @@ package idl
 import (
 	"encoding/json"
 	"fmt"
+	"strconv"
 )
 
 // transcodeJSON converts the input to json, and then unmarshals it into the
@@ -19,3 +20,21 @@ func transcodeJSON(input any, destinationPointer any) error {
 	}
 	return nil
 }
+
+// byteArray is marshalled as an array of numbers like the IDL spec, rather than a base64 string.
+type byteArray []byte
+
+func (b byteArray) MarshalJSON() ([]byte, error) {
+	if b == nil {
+		return []byte("null"), nil
+	}
+	out := make([]byte, 0, 2+4*len(b))
+	out = append(out, '[')
+	for i, v := range b {
+		if i > 0 {
+			out = append(out, ',')
+		}
+		out = strconv.AppendUint(out, uint64(v), 10)
+	}
+	return append(out, ']'), nil
+}
@@ -0,0 +1,314 @@
+package idl
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alivers/anchor-go/internal/diagnostic"
+)
+
+// ErrorCodeOffset is the first error code of the custom errors, the codes below are reserved by Anchor.
+// Ref: https://github.com/solana-foundation/anchor/blob/v0.31.1/lang/src/error.rs#L10
+const ErrorCodeOffset = 6000
+
+type ValidateOptions struct {
+	// IsKnownType reports whether a defined type which is not declared in the IDL is provided elsewhere,
+	// e.g. by a dependency or by the user. It's optional.
+	IsKnownType func(name string) bool
+}
+
+// Validate checks the semantic problems of the IDL which can't be caught when unmarshalling,
+// so the code generators can rely on a consistent IDL.
+func (idl *Idl) Validate(opts ValidateOptions) diagnostic.Diagnostics {
+	v := &validator{idl: idl, opts: opts}
+	v.checkDuplicateNames()
+	v.checkDiscriminators()
+	v.checkDefinedTypes()
+	v.checkAccountsAndEvents()
+	v.checkErrorCodes()
+	v.checkPdaSeeds()
+	return v.diags
+}
+
+type validator struct {
+	idl   *Idl
+	opts  ValidateOptions
+	diags diagnostic.Diagnostics
+}
+
+func (v *validator) errorf(path string, format string, args ...any) {
+	v.diags.Errorf(v.idl.Metadata.Name, path, format, args...)
+}
+
+func (v *validator) warnf(path string, format string, args ...any) {
+	v.diags.Warnf(v.idl.Metadata.Name, path, format, args...)
+}
+
+// findTypeDef returns the definition of the type, or of the account type of the old IDL spec which is declared by the account.
+func (v *validator) findTypeDef(name string) *IdlTypeDefTy {
+	if def := v.idl.FindTypeByName(name); def != nil {
+		return &def.Type
+	}
+	for i := range v.idl.Accounts {
+		if acc := &v.idl.Accounts[i]; acc.Name == name && (acc.Type.IsStruct() || acc.Type.IsEnum() || acc.Type.IsType()) {
+			return &acc.Type
+		}
+	}
+	return nil
+}
+
+func (v *validator) isKnownType(name string) bool {
+	if v.idl.FindTypeByName(name) != nil {
+		return true
+	}
+	return v.opts.IsKnownType != nil && v.opts.IsKnownType(name)
+}
+
+type namedItem struct {
+	path string
+	name string
+}
+
+// checkDuplicates reports the items which have the same name as a previous one.
+func (v *validator) checkDuplicates(kind string, items []namedItem) {
+	seen := map[string]string{}
+	for _, item := range items {
+		if previous, ok := seen[item.name]; ok {
+			v.errorf(item.path, "duplicate %s name %s, already declared at %s", kind, item.name, previous)
+			continue
+		}
+		seen[item.name] = item.path
+	}
+}
+
+func (v *validator) checkDuplicateNames() {
+	items := func(n int, prefix string, name func(i int) string) []namedItem {
+		result := make([]namedItem, n)
+		for i := range n {
+			result[i] = namedItem{path: fmt.Sprintf("%s[%d].name", prefix, i), name: name(i)}
+		}
+		return result
+	}
+	v.checkDuplicates("instruction", items(len(v.idl.Instructions), "instructions", func(i int) string { return v.idl.Instructions[i].Name }))
+	v.checkDuplicates("account", items(len(v.idl.Accounts), "accounts", func(i int) string { return v.idl.Accounts[i].Name }))
+	v.checkDuplicates("type", items(len(v.idl.Types), "types", func(i int) string { return v.idl.Types[i].Name }))
+	v.checkDuplicates("event", items(len(v.idl.Events), "events", func(i int) string { return v.idl.Events[i].Name }))
+	v.checkDuplicates("error", items(len(v.idl.Errors), "errors", func(i int) string { return v.idl.Errors[i].Name }))
+	v.checkDuplicates("constant", items(len(v.idl.Constants), "constants", func(i int) string { return v.idl.Constants[i].Name }))
+}
+
+type discriminatorItem struct {
+	path          string
+	name          string
+	discriminator IdlDiscriminator
+}
+
+// checkDiscriminators reports the discriminators which are ambiguous when decoding, i.e. equal to another one of the same kind.
+// A discriminator may be a prefix of another one (e.g. a 1-byte custom discriminator and the 8-byte ones of Anchor),
+// the longest one which prefixes the data is matched when decoding.
+func (v *validator) checkDiscriminators() {
+	check := func(kind string, items []discriminatorItem) {
+		for i, item := range items {
+			if item.discriminator == nil {
+				continue
+			}
+			if len(item.discriminator) == 0 {
+				v.errorf(item.path, "empty discriminator of %s %s", kind, item.name)
+				continue
+			}
+			for _, previous := range items[:i] {
+				switch {
+				case previous.discriminator == nil:
+				case bytes.Equal(previous.discriminator, item.discriminator):
+					v.errorf(item.path, "duplicate discriminator of %s %s, same as %s", kind, item.name, previous.name)
+				}
+			}
+		}
+	}
+
+	instructions := make([]discriminatorItem, len(v.idl.Instructions))
+	for i, inst := range v.idl.Instructions {
+		instructions[i] = discriminatorItem{fmt.Sprintf("instructions[%d].discriminator", i), inst.Name, inst.Discriminator}
+	}
+	check("instruction", instructions)
+
+	accounts := make([]discriminatorItem, len(v.idl.Accounts))
+	for i, acc := range v.idl.Accounts {
+		accounts[i] = discriminatorItem{fmt.Sprintf("accounts[%d].discriminator", i), acc.Name, acc.Discriminator}
+	}
+	check("account", accounts)
+
+	events := make([]discriminatorItem, len(v.idl.Events))
+	for i, evt := range v.idl.Events {
+		events[i] = discriminatorItem{fmt.Sprintf("events[%d].discriminator", i), evt.Name, evt.Discriminator}
+	}
+	check("event", events)
+}
+
+func (v *validator) checkDefinedTypes() {
+	v.idl.VisitTypes(func(path string, typ *IdlType) {
+		if typ.IsDefined() && !v.isKnownType(typ.GetDefined().Name) {
+			v.errorf(path, "undefined type %s", typ.GetDefined().Name)
+		}
+	})
+}
+
+func (v *validator) checkAccountsAndEvents() {
+	for i, acc := range v.idl.Accounts {
+		// The type of the account is inline in the old IDL spec.
+		if acc.Type.IsStruct() || acc.Type.IsEnum() || acc.Type.IsType() {
+			continue
+		}
+		if v.idl.FindTypeByName(acc.Name) == nil {
+			v.errorf(fmt.Sprintf("accounts[%d]", i), "account %s has no matching entry in types", acc.Name)
+		}
+	}
+	for i, evt := range v.idl.Events {
+		if v.idl.FindTypeByName(evt.Name) == nil {
+			v.errorf(fmt.Sprintf("events[%d]", i), "event %s has no matching entry in types", evt.Name)
+		}
+	}
+}
+
+func (v *validator) checkErrorCodes() {
+	seen := map[int]string{}
+	for i, item := range v.idl.Errors {
+		path := fmt.Sprintf("errors[%d].code", i)
+		if item.Code < ErrorCodeOffset {
+			v.errorf(path, "error code %d of %s collides with the range reserved by Anchor (< %d)", item.Code, item.Name, ErrorCodeOffset)
+		}
+		if previous, ok := seen[item.Code]; ok {
+			v.errorf(path, "duplicate error code %d of %s, same as %s", item.Code, item.Name, previous)
+			continue
+		}
+		seen[item.Code] = item.Name
+	}
+}
+
+func (v *validator) checkPdaSeeds() {
+	for i := range v.idl.Instructions {
+		inst := &v.idl.Instructions[i]
+		accounts := inst.GetAccountsWithRelation()
+		for _, account := range accounts {
+			if account.Account.Pda == nil {
+				continue
+			}
+			pdaPath := fmt.Sprintf("instructions[%d].%s.pda", i, account.Path)
+			for j := range account.Account.Pda.Seeds {
+				v.checkSeed(fmt.Sprintf("%s.seeds[%d]", pdaPath, j), &account.Account.Pda.Seeds[j], inst, accounts)
+			}
+			if account.Account.Pda.Program != nil {
+				v.checkSeed(pdaPath+".program", account.Account.Pda.Program, inst, accounts)
+			}
+		}
+	}
+}
+
+func (v *validator) checkSeed(path string, seed *IdlSeed, inst *IdlInstruction, accounts []*instructionAccount) {
+	switch {
+	case seed.IsConst():
+		if seed.GetConst().Value == nil {
+			v.errorf(path, "missing value of const seed")
+		}
+	case seed.IsArg():
+		segments := strings.Split(seed.GetArg().Path, ".")
+		var arg *IdlField
+		for j := range inst.Args {
+			if inst.Args[j].Name == segments[0] {
+				arg = &inst.Args[j]
+				break
+			}
+		}
+		if arg == nil {
+			v.errorf(path, "path %s doesn't resolve to an argument of instruction %s", seed.GetArg().Path, inst.Name)
+			return
+		}
+		if _, ok := v.resolveFieldPath(arg.Type, segments[1:]); !ok {
+			v.errorf(path, "path %s doesn't resolve to a field of argument %s", seed.GetArg().Path, arg.Name)
+		}
+	case seed.IsAccount():
+		accountSeed := seed.GetAccount()
+		segments := strings.Split(accountSeed.Path, ".")
+		found := false
+		for _, account := range accounts {
+			if account.Account.Name == segments[0] {
+				found = true
+				break
+			}
+		}
+		if !found {
+			v.errorf(path, "path %s doesn't resolve to an account of instruction %s", accountSeed.Path, inst.Name)
+			return
+		}
+		if len(segments) == 1 {
+			return
+		}
+		if accountSeed.Account == nil {
+			v.errorf(path, "missing account type of path %s", accountSeed.Path)
+			return
+		}
+		if v.findTypeDef(*accountSeed.Account) == nil {
+			if !v.isKnownType(*accountSeed.Account) {
+				// e.g. the accounts of other programs like `TokenAccount`, which the legacy IDLs refer to.
+				v.warnf(path, "account type %s of path %s is not declared in the IDL, the address of account %s is not derived", *accountSeed.Account, accountSeed.Path, segments[0])
+			}
+			return
+		}
+		accountType := IdlType{IdlTypeDefined: &IdlTypeDefined{Name: *accountSeed.Account}}
+		if _, ok := v.resolveFieldPath(accountType, segments[1:]); !ok {
+			v.errorf(path, "path %s doesn't resolve to a field of account type %s", accountSeed.Path, *accountSeed.Account)
+		}
+	default:
+		v.errorf(path, "unknown seed kind")
+	}
+}
+
+// resolveFieldPath resolves the type of the field at the path (field names or tuple indexes) of the type.
+// The types which are not declared in the IDL can't be resolved.
+func (v *validator) resolveFieldPath(typ IdlType, segments []string) (*IdlType, bool) {
+	current := &typ
+	for _, segment := range segments {
+		if !current.IsDefined() {
+			return nil, false
+		}
+		def := v.findTypeDef(current.GetDefined().Name)
+		if def == nil {
+			return nil, false
+		}
+		// Follow the aliases, a cycle ends up with a type which isn't a struct.
+		for range len(v.idl.Types) {
+			if !def.IsType() || !def.GetType().Alias.IsDefined() {
+				break
+			}
+			if def = v.findTypeDef(def.GetType().Alias.GetDefined().Name); def == nil {
+				return nil, false
+			}
+		}
+		if !def.IsStruct() {
+			return nil, false
+		}
+
+		fields := def.GetStruct().Fields
+		var next *IdlType
+		switch {
+		case fields.IsNamed():
+			for i := range fields.GetNamed().Fields {
+				if fields.GetNamed().Fields[i].Name == segment {
+					next = &fields.GetNamed().Fields[i].Type
+					break
+				}
+			}
+		case fields.IsTuple():
+			if index, err := strconv.Atoi(segment); err == nil && index >= 0 && index < len(fields.GetTuple().Types) {
+				next = &fields.GetTuple().Types[index]
+			}
+		}
+		if next == nil {
+			return nil, false
+		}
+		current = next
+	}
+	return current, true
+}
@@ -0,0 +1,310 @@
+package idl
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/alivers/anchor-go/internal/diagnostic"
+)
+
+const validIdlJson = `{
+  "address": "Fg6PaFpoGXkYsidMpWTK6W2BeZ7FEfcYkg476zPFsLnS",
+  "metadata": {"name": "vault_prog", "version": "0.1.0", "spec": "0.1.0"},
+  "instructions": [
+    {
+      "name": "deposit",
+      "discriminator": [1, 1, 1, 1, 1, 1, 1, 1],
+      "accounts": [
+        {"name": "config"},
+        {"name": "vault", "writable": true, "pda": {"seeds": [
+          {"kind": "const", "value": [118, 97, 117, 108, 116]},
+          {"kind": "arg", "path": "params.pair.0"},
+          {"kind": "account", "path": "config.owner", "account": "Config"},
+          {"kind": "account", "path": "authority"}
+        ]}},
+        {"name": "common", "accounts": [{"name": "authority", "signer": true}]}
+      ],
+      "args": [{"name": "params", "type": {"defined": {"name": "ParamsAlias"}}}]
+    },
+    {
+      "name": "withdraw",
+      "discriminator": [2, 2, 2, 2, 2, 2, 2, 2],
+      "accounts": [],
+      "args": []
+    }
+  ],
+  "accounts": [{"name": "Config", "discriminator": [3, 3, 3, 3, 3, 3, 3, 3]}],
+  "events": [{"name": "Deposited", "discriminator": [4, 4, 4, 4, 4, 4, 4, 4]}],
+  "errors": [
+    {"code": 6000, "name": "Unauthorized"},
+    {"code": 6001, "name": "Overflow"}
+  ],
+  "types": [
+    {"name": "Config", "type": {"kind": "struct", "fields": [{"name": "owner", "type": "pubkey"}]}},
+    {"name": "Deposited", "type": {"kind": "struct", "fields": [{"name": "amount", "type": "u64"}]}},
+    {"name": "Pair", "type": {"kind": "struct", "fields": ["u8", "u16"]}},
+    {"name": "Params", "type": {"kind": "struct", "fields": [{"name": "pair", "type": {"defined": {"name": "Pair"}}}]}},
+    {"name": "ParamsAlias", "type": {"kind": "type", "alias": {"defined": {"name": "Params"}}}}
+  ],
+  "constants": [{"name": "SEED", "type": "bytes", "value": "[118, 97, 117, 108, 116]"}]
+}`
+
+func TestValidate(t *testing.T) {
+	seeds := func(program *Idl) []IdlSeed {
+		return program.Instructions[0].Accounts[1].IdlInstructionAccount.Pda.Seeds
+	}
+	defined := func(name string) IdlType {
+		return IdlType{IdlTypeDefined: &IdlTypeDefined{Name: name}}
+	}
+	str := func(s string) *string { return &s }
+
+	tests := []struct {
+		name   string
+		opts   ValidateOptions
+		mutate func(program *Idl)
+		// The expected diagnostics as `<path>: <message>`, all of the error severity unless warning is set.
+		expected []string
+		warning  bool
+	}{
+		{
+			name:   "valid",
+			mutate: func(program *Idl) {},
+		},
+		{
+			name:     "duplicate instruction name",
+			mutate:   func(program *Idl) { program.Instructions[1].Name = "deposit" },
+			expected: []string{"instructions[1].name: duplicate instruction name deposit, already declared at instructions[0].name"},
+		},
+		{
+			name: "duplicate account name",
+			mutate: func(program *Idl) {
+				program.Accounts = append(program.Accounts, IdlAccount{Name: "Config", Discriminator: IdlDiscriminator{5}})
+			},
+			expected: []string{"accounts[1].name: duplicate account name Config, already declared at accounts[0].name"},
+		},
+		{
+			name:     "duplicate type name",
+			mutate:   func(program *Idl) { program.Types = append(program.Types, program.Types[0]) },
+			expected: []string{"types[5].name: duplicate type name Config, already declared at types[0].name"},
+		},
+		{
+			name: "duplicate event name",
+			mutate: func(program *Idl) {
+				program.Events = append(program.Events, IdlEvent{Name: "Deposited", Discriminator: IdlDiscriminator{5}})
+			},
+			expected: []string{"events[1].name: duplicate event name Deposited, already declared at events[0].name"},
+		},
+		{
+			name:     "duplicate error name",
+			mutate:   func(program *Idl) { program.Errors[1].Name = "Unauthorized" },
+			expected: []string{"errors[1].name: duplicate error name Unauthorized, already declared at errors[0].name"},
+		},
+		{
+			name:     "duplicate constant name",
+			mutate:   func(program *Idl) { program.Constants = append(program.Constants, program.Constants[0]) },
+			expected: []string{"constants[1].name: duplicate constant name SEED, already declared at constants[0].name"},
+		},
+		{
+			name:     "empty discriminator",
+			mutate:   func(program *Idl) { program.Instructions[1].Discriminator = IdlDiscriminator{} },
+			expected: []string{"instructions[1].discriminator: empty discriminator of instruction withdraw"},
+		},
+		{
+			name:   "missing discriminator",
+			mutate: func(program *Idl) { program.Instructions[1].Discriminator = nil },
+		},
+		{
+			name:     "duplicate discriminator",
+			mutate:   func(program *Idl) { program.Instructions[1].Discriminator = IdlDiscriminator{1, 1, 1, 1, 1, 1, 1, 1} },
+			expected: []string{"instructions[1].discriminator: duplicate discriminator of instruction withdraw, same as deposit"},
+		},
+		{
+			name:   "discriminator prefix of another one",
+			mutate: func(program *Idl) { program.Instructions[1].Discriminator = IdlDiscriminator{1} },
+		},
+		{
+			name: "discriminators of different kinds",
+			mutate: func(program *Idl) {
+				program.Accounts[0].Discriminator = IdlDiscriminator{1, 1, 1, 1, 1, 1, 1, 1}
+				program.Events[0].Discriminator = IdlDiscriminator{1, 1, 1, 1, 1, 1, 1, 1}
+			},
+		},
+		{
+			name: "duplicate account discriminator",
+			mutate: func(program *Idl) {
+				program.Accounts = append(program.Accounts, IdlAccount{Name: "Deposited", Discriminator: IdlDiscriminator{3, 3, 3, 3, 3, 3, 3, 3}})
+			},
+			expected: []string{"accounts[1].discriminator: duplicate discriminator of account Deposited, same as Config"},
+		},
+		{
+			name: "event discriminator prefixed by another one",
+			mutate: func(program *Idl) {
+				program.Events = append(program.Events, IdlEvent{Name: "Config", Discriminator: IdlDiscriminator{4, 4, 4, 4, 4, 4, 4, 4, 4}})
+			},
+		},
+		{
+			name: "undefined type",
+			mutate: func(program *Idl) {
+				program.Types[1].Type.GetStruct().Fields.GetNamed().Fields[0].Type = defined("Missing")
+			},
+			expected: []string{"types[1].type.fields[0].type: undefined type Missing"},
+		},
+		{
+			name: "undefined nested type",
+			mutate: func(program *Idl) {
+				program.Instructions[1].Returns = IdlType{IdlTypeVec: &IdlTypeVec{Vec: defined("Missing")}}
+			},
+			expected: []string{"instructions[1].returns.vec: undefined type Missing"},
+		},
+		{
+			name: "type provided elsewhere",
+			opts: ValidateOptions{IsKnownType: func(name string) bool { return name == "Missing" }},
+			mutate: func(program *Idl) {
+				program.Types[1].Type.GetStruct().Fields.GetNamed().Fields[0].Type = defined("Missing")
+			},
+		},
+		{
+			name:     "account without type",
+			mutate:   func(program *Idl) { program.Accounts[0].Name = "Settings" },
+			expected: []string{"accounts[0]: account Settings has no matching entry in types"},
+		},
+		{
+			name: "account with inline type",
+			mutate: func(program *Idl) {
+				program.Accounts = append(program.Accounts, IdlAccount{
+					Name:          "Legacy",
+					Discriminator: IdlDiscriminator{5},
+					Type:          IdlTypeDefTy{IdlTypeDefTyStruct: &IdlTypeDefTyStruct{Kind: "struct"}},
+				})
+			},
+		},
+		{
+			name:     "event without type",
+			mutate:   func(program *Idl) { program.Types[1].Name = "Withdrawn" },
+			expected: []string{"events[0]: event Deposited has no matching entry in types"},
+		},
+		{
+			name:     "reserved error code",
+			mutate:   func(program *Idl) { program.Errors[0].Code = 100 },
+			expected: []string{"errors[0].code: error code 100 of Unauthorized collides with the range reserved by Anchor (< 6000)"},
+		},
+		{
+			name:     "duplicate error code",
+			mutate:   func(program *Idl) { program.Errors[1].Code = 6000 },
+			expected: []string{"errors[1].code: duplicate error code 6000 of Overflow, same as Unauthorized"},
+		},
+		{
+			name:     "const seed without value",
+			mutate:   func(program *Idl) { seeds(program)[0].GetConst().Value = nil },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[0]: missing value of const seed"},
+		},
+		{
+			name:     "arg seed of unknown argument",
+			mutate:   func(program *Idl) { seeds(program)[1].GetArg().Path = "amount" },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[1]: path amount doesn't resolve to an argument of instruction deposit"},
+		},
+		{
+			name:     "arg seed of unknown field",
+			mutate:   func(program *Idl) { seeds(program)[1].GetArg().Path = "params.pair.2" },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[1]: path params.pair.2 doesn't resolve to a field of argument params"},
+		},
+		{
+			name:     "arg seed of a field of a simple type",
+			mutate:   func(program *Idl) { seeds(program)[1].GetArg().Path = "params.pair.0.value" },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[1]: path params.pair.0.value doesn't resolve to a field of argument params"},
+		},
+		{
+			name:     "account seed of unknown account",
+			mutate:   func(program *Idl) { seeds(program)[3].GetAccount().Path = "payer" },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[3]: path payer doesn't resolve to an account of instruction deposit"},
+		},
+		{
+			name:     "account seed without account type",
+			mutate:   func(program *Idl) { seeds(program)[2].GetAccount().Account = nil },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[2]: missing account type of path config.owner"},
+		},
+		{
+			name:     "account seed of undefined account type",
+			mutate:   func(program *Idl) { seeds(program)[2].GetAccount().Account = str("Settings") },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[2]: account type Settings of path config.owner is not declared in the IDL, the address of account config is not derived"},
+			warning:  true,
+		},
+		{
+			name: "account seed of account type of the old spec",
+			mutate: func(program *Idl) {
+				var settings IdlAccount
+				if err := json.Unmarshal([]byte(`{"name": "Settings", "discriminator": [6], "type": {"kind": "struct", "fields": [{"name": "owner", "type": "pubkey"}]}}`), &settings); err != nil {
+					panic(err)
+				}
+				program.Accounts = append(program.Accounts, settings)
+				seeds(program)[2].GetAccount().Account = str("Settings")
+			},
+		},
+		{
+			name:   "account seed of account type provided elsewhere",
+			opts:   ValidateOptions{IsKnownType: func(name string) bool { return name == "Settings" }},
+			mutate: func(program *Idl) { seeds(program)[2].GetAccount().Account = str("Settings") },
+		},
+		{
+			name:     "account seed of unknown field",
+			mutate:   func(program *Idl) { seeds(program)[2].GetAccount().Path = "config.admin" },
+			expected: []string{"instructions[0].accounts[1].pda.seeds[2]: path config.admin doesn't resolve to a field of account type Config"},
+		},
+		{
+			name: "program seed",
+			mutate: func(program *Idl) {
+				program.Instructions[0].Accounts[1].IdlInstructionAccount.Pda.Program = &IdlSeed{IdlSeedArg: &IdlSeedArg{Kind: "arg", Path: "program"}}
+			},
+			expected: []string{"instructions[0].accounts[1].pda.program: path program doesn't resolve to an argument of instruction deposit"},
+		},
+		{
+			name: "seed of nested account",
+			mutate: func(program *Idl) {
+				program.Instructions[0].Accounts[2].IdlInstructionAccounts.Accounts[0].IdlInstructionAccount.Pda = &IdlPda{
+					Seeds: []IdlSeed{{}},
+				}
+			},
+			expected: []string{"instructions[0].accounts[2].accounts[0].pda.seeds[0]: unknown seed kind"},
+		},
+		{
+			name: "every problem",
+			mutate: func(program *Idl) {
+				program.Instructions[1].Name = "deposit"
+				program.Errors[1].Code = 6000
+			},
+			expected: []string{
+				"instructions[1].name: duplicate instruction name deposit, already declared at instructions[0].name",
+				"errors[1].code: duplicate error code 6000 of Overflow, same as Unauthorized",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, err := Parse([]byte(validIdlJson))
+			if err != nil {
+				t.Fatal(err)
+			}
+			tt.mutate(program)
+
+			diags := program.Validate(tt.opts)
+			if len(diags) != len(tt.expected) {
+				t.Fatalf("got diagnostics %v, expected %v", diags, tt.expected)
+			}
+			for i, diag := range diags {
+				expectedSeverity := diagnostic.SeverityError
+				if tt.warning {
+					expectedSeverity = diagnostic.SeverityWarning
+				}
+				if diag.Severity != expectedSeverity {
+					t.Errorf("diagnostics[%d]: got severity %s, expected %s", i, diag.Severity, expectedSeverity)
+				}
+				if diag.Program != "vault_prog" {
+					t.Errorf("diagnostics[%d]: got program %s, expected vault_prog", i, diag.Program)
+				}
+				if got := diag.Path + ": " + diag.Message; got != tt.expected[i] {
+					t.Errorf("diagnostics[%d]: got %s, expected %s", i, got, tt.expected[i])
+				}
+			}
+		})
+	}
+}
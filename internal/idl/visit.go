@@ -0,0 +1,96 @@
+package idl
+
+import (
+	"fmt"
+)
+
+// VisitTypes calls fn for every IdlType used by the program (including the nested ones),
+// together with its location in the IDL, e.g. `types[2].type.fields[1].type`.
+func (idl *Idl) VisitTypes(fn func(path string, typ *IdlType)) {
+	for i := range idl.Instructions {
+		inst := &idl.Instructions[i]
+		for j := range inst.Args {
+			visitType(fmt.Sprintf("instructions[%d].args[%d].type", i, j), &inst.Args[j].Type, fn)
+		}
+		if !inst.Returns.IsEmpty() {
+			visitType(fmt.Sprintf("instructions[%d].returns", i), &inst.Returns, fn)
+		}
+	}
+	for i := range idl.Accounts {
+		idl.Accounts[i].Type.VisitTypes(fmt.Sprintf("accounts[%d].type", i), fn)
+	}
+	for i := range idl.Types {
+		idl.Types[i].Type.VisitTypes(fmt.Sprintf("types[%d].type", i), fn)
+	}
+	for i := range idl.Constants {
+		visitType(fmt.Sprintf("constants[%d].type", i), &idl.Constants[i].Type, fn)
+	}
+}
+
+// ReferencedTypeNames returns the names of all the defined types which are used by the program.
+func (idl *Idl) ReferencedTypeNames() map[string]struct{} {
+	names := map[string]struct{}{}
+	idl.VisitTypes(func(_ string, typ *IdlType) {
+		if typ.IsDefined() {
+			names[typ.GetDefined().Name] = struct{}{}
+		}
+	})
+	return names
+}
+
+// VisitTypes calls fn for every IdlType used by the type definition (including the nested ones).
+func (defTy *IdlTypeDefTy) VisitTypes(path string, fn func(path string, typ *IdlType)) {
+	switch {
+	case defTy.IsStruct():
+		defTy.GetStruct().Fields.visitTypes(path+".fields", fn)
+	case defTy.IsEnum():
+		for i := range defTy.GetEnum().Variants {
+			defTy.GetEnum().Variants[i].Fields.visitTypes(fmt.Sprintf("%s.variants[%d].fields", path, i), fn)
+		}
+	case defTy.IsType():
+		visitType(path+".alias", &defTy.GetType().Alias, fn)
+	}
+}
+
+func (def *IdlDefinedFields) visitTypes(path string, fn func(path string, typ *IdlType)) {
+	switch {
+	case def.IsNamed():
+		for i := range def.GetNamed().Fields {
+			visitType(fmt.Sprintf("%s[%d].type", path, i), &def.GetNamed().Fields[i].Type, fn)
+		}
+	case def.IsTuple():
+		for i := range def.GetTuple().Types {
+			visitType(fmt.Sprintf("%s[%d]", path, i), &def.GetTuple().Types[i], fn)
+		}
+	}
+}
+
+// VisitTypes calls fn for the type and its nested types.
+func (idlType *IdlType) VisitTypes(path string, fn func(path string, typ *IdlType)) {
+	visitType(path, idlType, fn)
+}
+
+func visitType(path string, typ *IdlType, fn func(path string, typ *IdlType)) {
+	fn(path, typ)
+	switch {
+	case typ.IsOption():
+		visitType(path+".option", &typ.GetOption().Option, fn)
+	case typ.IsVec():
+		visitType(path+".vec", &typ.GetVec().Vec, fn)
+	case typ.IsArray():
+		visitType(path+".array[0]", &typ.GetArray().Elem, fn)
+	case typ.IsDefined():
+		for i := range typ.GetDefined().Generics {
+			arg := &typ.GetDefined().Generics[i]
+			if arg.IsType() {
+				visitType(fmt.Sprintf("%s.defined.generics[%d].type", path, i), &arg.GetType().Type, fn)
+			}
+		}
+	case typ.IsHashMap():
+		kind := typ.GetHashMap().Kind()
+		visitType(path+"."+kind+"[0]", &typ.GetHashMap().Key, fn)
+		visitType(path+"."+kind+"[1]", &typ.GetHashMap().Val, fn)
+	case typ.IsHashSet():
+		visitType(path+"."+typ.GetHashSet().Kind(), &typ.GetHashSet().Elem, fn)
+	}
+}
@@ -0,0 +1,23 @@
+package util
+
+import "testing"
+
+func TestToRustSnakeCase(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"initializeVault", "initialize_vault"},
+		{"InitializeVault", "initialize_vault"},
+		{"set_fee", "set_fee"},
+		{"XMLHttpRequest", "xml_http_request"},
+		{"swapV2", "swap_v2"},
+		{"__private", "private"},
+		{"", ""},
+	}
+	for _, tt := range tests {
+		if got := ToRustSnakeCase(tt.input); got != tt.expected {
+			t.Errorf("ToRustSnakeCase(%q): got %q, expected %q", tt.input, got, tt.expected)
+		}
+	}
+}